@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestChangeOwnEmail(t *testing.T) {
+	ta := newTestApp(t)
+
+	user, token := ta.newUser()
+
+	newEmail := "new-" + user.Email
+
+	res := ta.doRequest(http.MethodPatch, "/v1/users/me", bearer(token), fmt.Sprintf(`{"email": %q}`, newEmail), nil)
+	require.Equal(t, http.StatusOK, res.StatusCode)
+
+	// The address only changes once the token sent to the new address is confirmed.
+	stored, err := ta.models.User.Get(context.Background(), user.ID)
+	require.NoError(t, err)
+	require.Equal(t, user.Email, stored.Email)
+	require.Equal(t, newEmail, stored.PendingEmail)
+
+	confirmation := ta.emailToken(newEmail, "email_change")
+
+	res = ta.doRequest(http.MethodPut, "/v1/users/email", bearer(token), fmt.Sprintf(`{"token": %q}`, confirmation), nil)
+	require.Equal(t, http.StatusOK, res.StatusCode)
+
+	stored, err = ta.models.User.Get(context.Background(), user.ID)
+	require.NoError(t, err)
+	require.Equal(t, newEmail, stored.Email)
+	require.Empty(t, stored.PendingEmail)
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/shynggys9219/greenlight/internal/data"
+	"github.com/shynggys9219/greenlight/internal/validator"
+)
+
+// listUsersHandler lists users for "GET /v1/admin/users", e.g.
+// "?activated=false&created_after=2023-01-01&email=example.com".
+func (app *application) listUsersHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		data.UserFilter
+		data.Filters
+	}
+
+	v := validator.New()
+
+	qs := r.URL.Query()
+
+	input.Activated = app.readBool(qs, "activated", v)
+	input.CreatedAfter = app.readTime(qs, "created_after", v)
+	input.CreatedBefore = app.readTime(qs, "created_before", v)
+	input.Email = app.readString(qs, "email", "")
+
+	input.Filters.Page = app.readInt(qs, "page", 1)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20)
+	input.Filters.Sort = app.readString(qs, "sort", "id")
+
+	input.Filters.SortSpec = data.UserSort
+
+	data.ValidateUserFilter(v, input.UserFilter)
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	users, err := app.models.User.GetAll(r.Context(), input.UserFilter, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"users": users}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) showUserHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := app.readUser(w, r)
+	if !ok {
+		return
+	}
+
+	err := app.writeJSON(w, http.StatusOK, envelope{"user": user}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// forceActivateUserHandler activates an account for "POST /v1/admin/users/:id/activate",
+// without the activation token.
+func (app *application) forceActivateUserHandler(w http.ResponseWriter, r *http.Request) {
+	app.changeUser(w, r, func(user *data.User) {
+		user.Activated = true
+	})
+}
+
+// lockUserHandler handles "POST /v1/admin/users/:id/lock". A locked user can't get new
+// tokens and the ones already issued stop working.
+func (app *application) lockUserHandler(w http.ResponseWriter, r *http.Request) {
+	app.changeUser(w, r, func(user *data.User) {
+		user.Locked = true
+	})
+}
+
+func (app *application) unlockUserHandler(w http.ResponseWriter, r *http.Request) {
+	app.changeUser(w, r, func(user *data.User) {
+		user.Locked = false
+	})
+}
+
+// deleteUserHandler handles "DELETE /v1/admin/users/:id". The user's tokens and roles
+// are deleted with it.
+func (app *application) deleteUserHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := app.readUser(w, r)
+	if !ok {
+		return
+	}
+
+	err := app.audited(r).User.Delete(r.Context(), user)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.users.forget(user.ID)
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "user successfully deleted"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// changeUser loads the user from the :id parameter, applies change and saves it. The
+// update only goes through if nobody changed the user in between.
+func (app *application) changeUser(w http.ResponseWriter, r *http.Request, change func(user *data.User)) {
+	user, ok := app.readUser(w, r)
+	if !ok {
+		return
+	}
+
+	change(user)
+
+	err := app.audited(r).User.Update(r.Context(), user)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.users.forget(user.ID)
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"user": user}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// readUser fetches the user from the :id parameter and writes the error response
+// itself when that fails.
+func (app *application) readUser(w http.ResponseWriter, r *http.Request) (*data.User, bool) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return nil, false
+	}
+
+	user, err := app.models.User.Get(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return nil, false
+	}
+
+	return user, true
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/shynggys9219/greenlight/internal/data"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdminLockUser(t *testing.T) {
+	ta := newTestApp(t)
+
+	admin, adminToken := ta.newAdmin()
+	user, userToken := ta.newUser()
+
+	// Only admins may manage users.
+	res := ta.doRequest(http.MethodPost, fmt.Sprintf("/v1/admin/users/%d/lock", admin.ID), bearer(userToken), "", nil)
+	require.Equal(t, http.StatusForbidden, res.StatusCode)
+
+	res = ta.doRequest(http.MethodPost, fmt.Sprintf("/v1/admin/users/%d/lock", user.ID), bearer(adminToken), "", nil)
+	require.Equal(t, http.StatusOK, res.StatusCode)
+
+	// The user's existing token stops working as soon as the account is locked.
+	res = ta.doRequest(http.MethodGet, "/v1/healthcheck", bearer(userToken), "", nil)
+	require.Equal(t, http.StatusForbidden, res.StatusCode)
+
+	res = ta.doRequest(http.MethodPost, fmt.Sprintf("/v1/admin/users/%d/unlock", user.ID), bearer(adminToken), "", nil)
+	require.Equal(t, http.StatusOK, res.StatusCode)
+
+	res = ta.doRequest(http.MethodGet, "/v1/healthcheck", bearer(userToken), "", nil)
+	require.Equal(t, http.StatusOK, res.StatusCode)
+
+	res = ta.doRequest(http.MethodDelete, fmt.Sprintf("/v1/admin/users/%d", user.ID), bearer(adminToken), "", nil)
+	require.Equal(t, http.StatusOK, res.StatusCode)
+
+	_, err := ta.models.User.GetForToken(context.Background(), data.ScopeAuthentication, userToken)
+	require.ErrorIs(t, err, data.ErrRecordNotFound)
+}
+
+func TestListUsersValidation(t *testing.T) {
+	ta := newTestApp(t)
+
+	_, adminToken := ta.newAdmin()
+
+	tests := []struct {
+		query string
+		field string
+	}{
+		{"?page=0", "page"},
+		{"?page=-1", "page"},
+		{"?page_size=0", "page_size"},
+		{"?page_size=101", "page_size"},
+		{"?sort=title", "sort"},
+	}
+
+	for _, tt := range tests {
+		var envelope struct {
+			Error map[string]string `json:"error"`
+		}
+		res := ta.doRequest(http.MethodGet, "/v1/admin/users"+tt.query, bearer(adminToken), "", &envelope)
+		require.Equal(t, http.StatusUnprocessableEntity, res.StatusCode, tt.query)
+		require.Contains(t, envelope.Error, tt.field, tt.query)
+	}
+}
+
+func TestCreateRoleRequiresAdmin(t *testing.T) {
+	ta := newTestApp(t)
+
+	_, adminToken := ta.newAdmin()
+	user, userToken := ta.newUser()
+
+	body := fmt.Sprintf(`{"role_name": %q, "user_id": %d}`, data.RoleAdmin, user.ID)
+
+	res := ta.doRequest(http.MethodPost, "/v1/roles", nil, body, nil)
+	require.Equal(t, http.StatusUnauthorized, res.StatusCode)
+
+	// A user can't make themselves an admin.
+	res = ta.doRequest(http.MethodPost, "/v1/roles", bearer(userToken), body, nil)
+	require.Equal(t, http.StatusForbidden, res.StatusCode)
+
+	res = ta.doRequest(http.MethodPost, "/v1/roles", bearer(adminToken), "{", nil)
+	require.Equal(t, http.StatusBadRequest, res.StatusCode)
+
+	res = ta.doRequest(http.MethodPost, "/v1/roles", bearer(adminToken), body, nil)
+	require.Equal(t, http.StatusCreated, res.StatusCode)
+}
+
+func TestCreateRoleValidation(t *testing.T) {
+	ta, _ := newMemstoreTestApp(t)
+
+	_, adminToken := ta.newAdmin()
+	user, _ := ta.newUser()
+
+	tests := []struct {
+		name  string
+		body  string
+		field string
+	}{
+		{"no role", fmt.Sprintf(`{"user_id": %d}`, user.ID), "role_name"},
+		{"unknown role", fmt.Sprintf(`{"role_name": "superuser", "user_id": %d}`, user.ID), "role_name"},
+		{"no user", `{"role_name": "admin"}`, "user_id"},
+		{"unknown user", fmt.Sprintf(`{"role_name": "admin", "user_id": %d}`, user.ID+1000), "user_id"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var envelope struct {
+				Error map[string]string `json:"error"`
+			}
+			res := ta.doRequest(http.MethodPost, "/v1/roles", bearer(adminToken), tt.body, &envelope)
+			require.Equal(t, http.StatusUnprocessableEntity, res.StatusCode)
+			require.Contains(t, envelope.Error, tt.field)
+		})
+	}
+
+	roles, err := ta.models.Role.GetAllForUser(context.Background(), user.ID)
+	require.NoError(t, err)
+	require.Empty(t, roles)
+}
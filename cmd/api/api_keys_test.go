@@ -0,0 +1,37 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPIKeyAuthentication(t *testing.T) {
+	ta := newTestApp(t)
+
+	_, token := ta.newUser()
+
+	var created struct {
+		APIKey struct {
+			ID  int64  `json:"id"`
+			Key string `json:"key"`
+		} `json:"api_key"`
+	}
+	res := ta.doRequest(http.MethodPost, "/v1/api-keys", bearer(token), `{"name": "ingest", "permissions": ["read"]}`, &created)
+	require.Equal(t, http.StatusCreated, res.StatusCode)
+
+	apiKey := http.Header{"Authorization": {"ApiKey " + created.APIKey.Key}}
+
+	require.Equal(t, http.StatusOK, ta.doRequest(http.MethodGet, "/v1/users/me", apiKey, "", nil).StatusCode)
+
+	// A read-only key can't change anything, and no key can manage the account.
+	require.Equal(t, http.StatusForbidden, ta.doRequest(http.MethodDelete, "/v1/movies/1", apiKey, "", nil).StatusCode)
+	require.Equal(t, http.StatusForbidden, ta.doRequest(http.MethodGet, "/v1/api-keys", apiKey, "", nil).StatusCode)
+
+	path := fmt.Sprintf("/v1/api-keys/%d", created.APIKey.ID)
+	require.Equal(t, http.StatusOK, ta.doRequest(http.MethodDelete, path, bearer(token), "", nil).StatusCode)
+
+	require.Equal(t, http.StatusUnauthorized, ta.doRequest(http.MethodGet, "/v1/users/me", apiKey, "", nil).StatusCode)
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/shynggys9219/greenlight/internal/data"
+	"github.com/shynggys9219/greenlight/internal/validator"
+)
+
+// listAuditEventsHandler lists audit events for "GET /v1/admin/audit", newest first,
+// e.g. "?resource_type=movie&resource_id=3" or "?actor_id=1&created_after=2023-01-01".
+func (app *application) listAuditEventsHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		data.AuditFilter
+		data.Filters
+	}
+
+	v := validator.New()
+
+	qs := r.URL.Query()
+
+	input.ActorID = int64(app.readInt(qs, "actor_id", 0))
+	input.Action = app.readString(qs, "action", "")
+	input.ResourceType = app.readString(qs, "resource_type", "")
+	input.ResourceID = int64(app.readInt(qs, "resource_id", 0))
+	input.CreatedAfter = app.readTime(qs, "created_after", v)
+	input.CreatedBefore = app.readTime(qs, "created_before", v)
+
+	input.Filters.Page = app.readInt(qs, "page", 1)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20)
+
+	v.Check(input.Filters.Page > 0, "page", "must be greater than zero")
+	v.Check(input.Filters.PageSize > 0 && input.Filters.PageSize <= 100, "page_size", "must be between 1 and 100")
+
+	if data.ValidateAuditFilter(v, input.AuditFilter); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	events, err := app.models.Audit.GetAll(r.Context(), input.AuditFilter, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"events": events}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
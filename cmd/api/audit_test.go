@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/shynggys9219/greenlight/internal/data"
+	"github.com/shynggys9219/greenlight/internal/testdb"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuditLog(t *testing.T) {
+	ta := newTestApp(t)
+
+	admin, adminToken := ta.newAdmin()
+	user, _ := ta.newUser()
+
+	header := bearer(adminToken)
+	header.Set("X-Request-ID", fmt.Sprintf("audit-%d", user.ID))
+
+	res := ta.doRequest(http.MethodPost, fmt.Sprintf("/v1/admin/users/%d/lock", user.ID), header, "", nil)
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	require.Equal(t, fmt.Sprintf("audit-%d", user.ID), res.Header.Get("X-Request-ID"))
+
+	var body struct {
+		Events []data.AuditEvent `json:"events"`
+	}
+	res = ta.doRequest(http.MethodGet, fmt.Sprintf("/v1/admin/audit?resource_type=user&resource_id=%d", user.ID), bearer(adminToken), "", &body)
+	require.Equal(t, http.StatusOK, res.StatusCode)
+
+	// Newest first: the lock by the admin, then the creation by the system.
+	require.Len(t, body.Events, 2)
+
+	lock := body.Events[0]
+	require.Equal(t, "user.update", lock.Action)
+	require.Equal(t, admin.ID, lock.ActorID)
+	require.Equal(t, fmt.Sprintf("audit-%d", user.ID), lock.RequestID)
+	require.Equal(t, data.Change{Before: false, After: true}, lock.Changes["locked"])
+	require.NotContains(t, lock.Changes, "name")
+
+	create := body.Events[1]
+	require.Equal(t, "user.create", create.Action)
+	require.Zero(t, create.ActorID)
+	require.Equal(t, user.Email, create.Changes["email"].After)
+}
+
+func TestAuditMovieUpdate(t *testing.T) {
+	ta := newTestApp(t)
+
+	admin, adminToken := ta.newAdmin()
+	movie := testdb.Movie(t, ta.models)
+
+	res := ta.doRequest(http.MethodPatch, fmt.Sprintf("/v1/movies/%d", movie.ID), bearer(adminToken), `{"title": "Renamed"}`, nil)
+	require.Equal(t, http.StatusOK, res.StatusCode)
+
+	var body struct {
+		Events []data.AuditEvent `json:"events"`
+	}
+	res = ta.doRequest(http.MethodGet, fmt.Sprintf("/v1/admin/audit?resource_type=movie&resource_id=%d", movie.ID), bearer(adminToken), "", &body)
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	require.NotEmpty(t, body.Events)
+
+	update := body.Events[0]
+	require.Equal(t, "movie.update", update.Action)
+	require.Equal(t, admin.ID, update.ActorID)
+	require.Equal(t, data.Change{Before: movie.Title, After: "Renamed"}, update.Changes["title"])
+	require.NotContains(t, update.Changes, "year")
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/shynggys9219/greenlight/internal/data"
+)
+
+// Custom type for the request context keys, so they can't collide with keys set by
+// other packages.
+type contextKey string
+
+const (
+	userContextKey      = contextKey("user")
+	apiKeyContextKey    = contextKey("apiKey")
+	sessionContextKey   = contextKey("session")
+	requestIDContextKey = contextKey("requestID")
+)
+
+// contextSetUser returns a copy of the request with the user added to its context.
+func (app *application) contextSetUser(r *http.Request, user *data.User) *http.Request {
+	ctx := context.WithValue(r.Context(), userContextKey, user)
+	return r.WithContext(ctx)
+}
+
+// contextGetUser is only called where the authenticate middleware has run, so a
+// missing user is a programming error and we panic.
+func (app *application) contextGetUser(r *http.Request) *data.User {
+	user, ok := r.Context().Value(userContextKey).(*data.User)
+	if !ok {
+		panic("missing user value in request context")
+	}
+
+	return user
+}
+
+// contextSetAPIKey records that the request was authenticated with an API key.
+func (app *application) contextSetAPIKey(r *http.Request, key *data.APIKey) *http.Request {
+	ctx := context.WithValue(r.Context(), apiKeyContextKey, key)
+	return r.WithContext(ctx)
+}
+
+// contextGetAPIKey returns the API key the request was authenticated with, nil for
+// bearer tokens and anonymous requests.
+func (app *application) contextGetAPIKey(r *http.Request) *data.APIKey {
+	key, _ := r.Context().Value(apiKeyContextKey).(*data.APIKey)
+	return key
+}
+
+// contextSetSession records the session of the request's authentication token.
+func (app *application) contextSetSession(r *http.Request, session *data.Session) *http.Request {
+	ctx := context.WithValue(r.Context(), sessionContextKey, session)
+	return r.WithContext(ctx)
+}
+
+// contextGetSession returns the session the request belongs to, nil for API keys,
+// anonymous requests and tokens issued outside a session.
+func (app *application) contextGetSession(r *http.Request) *data.Session {
+	session, _ := r.Context().Value(sessionContextKey).(*data.Session)
+	return session
+}
+
+func (app *application) contextSetRequestID(r *http.Request, id string) *http.Request {
+	ctx := context.WithValue(r.Context(), requestIDContextKey, id)
+	return r.WithContext(ctx)
+}
+
+// contextGetRequestID returns the ID the requestID middleware gave the request, ""
+// outside of it.
+func (app *application) contextGetRequestID(r *http.Request) string {
+	id, _ := r.Context().Value(requestIDContextKey).(string)
+	return id
+}
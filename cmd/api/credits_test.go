@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/shynggys9219/greenlight/internal/data"
+	"github.com/shynggys9219/greenlight/internal/testdb"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMovieCredits(t *testing.T) {
+	ta := newTestApp(t)
+
+	alien := testdb.Movie(t, ta.models, func(m *data.Movie) { m.Title = "Alien"; m.Year = 1979 })
+	aliens := testdb.Movie(t, ta.models, func(m *data.Movie) { m.Title = "Aliens"; m.Year = 1986 })
+
+	_, adminToken := ta.newAdmin()
+	admin := bearer(adminToken)
+
+	// Only admins change people and credits.
+	_, userToken := ta.newUser()
+	res := ta.doRequest(http.MethodPost, "/v1/people", nil, `{"name": "Sigourney Weaver"}`, nil)
+	require.Equal(t, http.StatusUnauthorized, res.StatusCode)
+	res = ta.doRequest(http.MethodPost, "/v1/people", bearer(userToken), `{"name": "Sigourney Weaver"}`, nil)
+	require.Equal(t, http.StatusForbidden, res.StatusCode)
+	res = ta.doRequest(http.MethodPost, fmt.Sprintf("/v1/movies/%d/credits", alien.ID), bearer(userToken), `{"person_id": 1, "role": "writer"}`, nil)
+	require.Equal(t, http.StatusForbidden, res.StatusCode)
+
+	var personEnvelope struct {
+		Person      *data.Person             `json:"person"`
+		Filmography []*data.FilmographyEntry `json:"filmography"`
+	}
+	res = ta.doRequest(http.MethodPost, "/v1/people", admin, `{"name": "Sigourney Weaver"}`, &personEnvelope)
+	require.Equal(t, http.StatusCreated, res.StatusCode)
+	weaver := personEnvelope.Person
+
+	credit := func(movieID int64, body string) (int, *data.Credit, map[string]string) {
+		var envelope struct {
+			Credit *data.Credit      `json:"credit"`
+			Error  map[string]string `json:"error"`
+		}
+		res := ta.doRequest(http.MethodPost, fmt.Sprintf("/v1/movies/%d/credits", movieID), admin, body, &envelope)
+		return res.StatusCode, envelope.Credit, envelope.Error
+	}
+
+	body := fmt.Sprintf(`{"person_id": %d, "role": "actor", "character_name": "Ripley", "billing_order": 1}`, weaver.ID)
+
+	status, ripley, _ := credit(alien.ID, body)
+	require.Equal(t, http.StatusCreated, status)
+	require.Equal(t, weaver.ID, ripley.PersonID)
+
+	status, _, _ = credit(aliens.ID, body)
+	require.Equal(t, http.StatusCreated, status)
+
+	status, _, errs := credit(alien.ID, body)
+	require.Equal(t, http.StatusUnprocessableEntity, status)
+	require.Equal(t, "is already credited in this role", errs["person_id"])
+
+	status, _, errs = credit(alien.ID, `{"person_id": 999999, "role": "writer"}`)
+	require.Equal(t, http.StatusUnprocessableEntity, status)
+	require.Equal(t, "does not exist", errs["person_id"])
+
+	status, _, errs = credit(alien.ID, fmt.Sprintf(`{"person_id": %d, "role": "writer", "character_name": "Ripley"}`, weaver.ID))
+	require.Equal(t, http.StatusUnprocessableEntity, status)
+	require.Contains(t, errs, "character_name")
+
+	status, _, _ = credit(999999, body)
+	require.Equal(t, http.StatusNotFound, status)
+
+	var credits struct {
+		Credits []*data.Credit `json:"credits"`
+	}
+	res = ta.doRequest(http.MethodGet, fmt.Sprintf("/v1/movies/%d/credits", alien.ID), nil, "", &credits)
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	require.Len(t, credits.Credits, 1)
+	require.Equal(t, "Sigourney Weaver", credits.Credits[0].PersonName)
+	require.Equal(t, "Ripley", credits.Credits[0].CharacterName)
+
+	// The filmography lists the newest movie first.
+	res = ta.doRequest(http.MethodGet, fmt.Sprintf("/v1/people/%d", weaver.ID), nil, "", &personEnvelope)
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	require.Len(t, personEnvelope.Filmography, 2)
+	require.Equal(t, "Aliens", personEnvelope.Filmography[0].Title)
+	require.Equal(t, "Alien", personEnvelope.Filmography[1].Title)
+
+	var people struct {
+		People []*data.Person `json:"people"`
+	}
+	res = ta.doRequest(http.MethodGet, "/v1/people?name=weaver", nil, "", &people)
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	require.Len(t, people.People, 1)
+	require.Equal(t, weaver.ID, people.People[0].ID)
+
+	res = ta.doRequest(http.MethodPatch, fmt.Sprintf("/v1/movies/%d/credits/%d", aliens.ID, ripley.ID), admin, `{"billing_order": 2}`, nil)
+	require.Equal(t, http.StatusNotFound, res.StatusCode, "a credit is only reachable through its own movie")
+
+	res = ta.doRequest(http.MethodDelete, fmt.Sprintf("/v1/movies/%d/credits/%d", alien.ID, ripley.ID), admin, "", nil)
+	require.Equal(t, http.StatusOK, res.StatusCode)
+
+	res = ta.doRequest(http.MethodGet, fmt.Sprintf("/v1/movies/%d/credits", alien.ID), nil, "", &credits)
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	require.Empty(t, credits.Credits)
+}
+
+func TestListPeopleValidation(t *testing.T) {
+	ta, _ := newMemstoreTestApp(t)
+
+	for _, query := range []string{"?page=0", "?page_size=101", "?sort=title", "?sort=name,name"} {
+		res := ta.doRequest(http.MethodGet, "/v1/people"+query, nil, "", nil)
+		require.Equal(t, http.StatusUnprocessableEntity, res.StatusCode, query)
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/shynggys9219/greenlight/internal/mailer"
+)
+
+// mailPreviewData is the sample data each email template is rendered with by the
+// preview endpoint. Add an entry whenever a new template is added.
+var mailPreviewData = map[string]map[string]any{
+	"user_welcome": {
+		"userID":          123,
+		"activationToken": "Y3QMGX3PJ3WLRL2YRTQGQ6KRHU",
+	},
+	"email_change": {
+		"name":             "Alice",
+		"emailChangeToken": "P4B3URJZJ2NO5NRPZLT6WD2K3Q",
+	},
+	"account_locked": {
+		"name":        "Alice",
+		"unlockToken": "P4B3URJZJ2NO5NRPZLT6WD2K3Q",
+		"lockedUntil": "Mon, 02 Jan 2006 15:04:05 UTC",
+	},
+}
+
+// previewMailHandler renders an email template with sample data for
+// "GET /debug/mail/preview/:template". The locale comes from ?lang= or the
+// Accept-Language header; "?part=html" or "?part=plain" returns just that body so it
+// can be looked at in a browser. Only registered in the development environment.
+func (app *application) previewMailHandler(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimSuffix(httprouter.ParamsFromContext(r.Context()).ByName("template"), ".tmpl")
+
+	sample, ok := mailPreviewData[name]
+	if !ok {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	locale := mailer.DefaultLocale
+	if languages := app.requestLanguages(r); len(languages) > 0 {
+		locale = languages[0]
+	}
+
+	msg, err := mailer.Render(name, locale, sample)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	switch r.URL.Query().Get("part") {
+	case "html":
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(msg.HTMLBody))
+	case "plain":
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write([]byte(msg.PlainBody))
+	default:
+		err = app.writeJSON(w, http.StatusOK, envelope{"template": name, "email": msg}, nil)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/shynggys9219/greenlight/internal/mailer"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPreviewMail(t *testing.T) {
+	ta, _ := newMemstoreTestApp(t, func(app *application) { app.config.Env = "development" })
+
+	for _, name := range mailer.Templates() {
+		require.Contains(t, mailPreviewData, name, "no preview data for %s", name)
+
+		for _, locale := range []string{"en", "ru"} {
+			var envelope struct {
+				Template string         `json:"template"`
+				Email    mailer.Message `json:"email"`
+			}
+			res := ta.doRequest(http.MethodGet, "/debug/mail/preview/"+name+"?lang="+locale, nil, "", &envelope)
+			require.Equal(t, http.StatusOK, res.StatusCode, name)
+			require.Equal(t, name, envelope.Template)
+			require.Equal(t, locale, envelope.Email.Locale)
+			require.NotEmpty(t, envelope.Email.Subject)
+		}
+	}
+
+	var envelope struct {
+		Email mailer.Message `json:"email"`
+	}
+	res := ta.doRequest(http.MethodGet, "/debug/mail/preview/user_welcome", http.Header{"Accept-Language": {"de-AT, ru;q=0.5"}}, "", &envelope)
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	require.Equal(t, mailer.DefaultLocale, envelope.Email.Locale)
+
+	res = ta.doRequest(http.MethodGet, "/debug/mail/preview/user_welcome?part=plain", nil, "", nil)
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	require.Equal(t, "text/plain; charset=utf-8", res.Header.Get("Content-Type"))
+
+	res = ta.doRequest(http.MethodGet, "/debug/mail/preview/user_welcome?part=html", nil, "", nil)
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	require.Equal(t, "text/html; charset=utf-8", res.Header.Get("Content-Type"))
+
+	res = ta.doRequest(http.MethodGet, "/debug/mail/preview/no_such_email", nil, "", nil)
+	require.Equal(t, http.StatusNotFound, res.StatusCode)
+}
+
+func TestPreviewMailOnlyInDevelopment(t *testing.T) {
+	ta, _ := newMemstoreTestApp(t, func(app *application) { app.config.Env = "production" })
+
+	res := ta.doRequest(http.MethodGet, "/debug/mail/preview/user_welcome", nil, "", nil)
+	require.Equal(t, http.StatusNotFound, res.StatusCode)
+}
@@ -6,6 +6,7 @@ import (
 	"net/http"
 
 	"github.com/shynggys9219/greenlight/internal/data"
+	"github.com/shynggys9219/greenlight/internal/validator"
 )
 
 func (app *application) createDirectorHandler(w http.ResponseWriter, r *http.Request) {
@@ -27,7 +28,7 @@ func (app *application) createDirectorHandler(w http.ResponseWriter, r *http.Req
 	}
 
 	// err = app.models.Director.Insert(director)
-	err = app.models.Directors.Insert(director)
+	err = app.audited(r).Directors.Insert(r.Context(), director)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 		return
@@ -90,7 +91,13 @@ func (app *application) searchByName(w http.ResponseWriter, r *http.Request) {
 	input.Filters.PageSize = app.readInt(qs, "page_size", 20)
 	input.Filters.Sort = app.readString(qs, "sort", "id")
 
-	input.Filters.SortSafelist = []string{"id", "name", "surname", "awards", "-id", "-name", "-surname", "-awards"}
+	input.Filters.SortSpec = data.DirectorSort
+
+	v := validator.New()
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
 
 	// directors, err := app.models.Director.GetOneByName(input.Name, input.Filters)
 	// if err != nil {
@@ -98,7 +105,7 @@ func (app *application) searchByName(w http.ResponseWriter, r *http.Request) {
 	// 	return
 	// }
 	// directors, err := app.models.Directors.GetAll(input.Name, input.Awards, input.Filters)
-	directors, err := app.models.Directors.GetOneByName(input.Name, input.Filters)
+	directors, err := app.models.Directors.GetOneByName(r.Context(), input.Name, input.Filters)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 		return
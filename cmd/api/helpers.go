@@ -5,12 +5,16 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"net/url"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/julienschmidt/httprouter"
+	"github.com/shynggys9219/greenlight/internal/data"
+	"github.com/shynggys9219/greenlight/internal/validator"
 )
 
 // again, in the book you have "any" type, but if you use go 1.17 and lower
@@ -20,10 +24,16 @@ type envelope map[string]interface{}
 // Retrieve the "id" URL parameter from the current request context, then convert it to
 // an integer and return it. If the operation isn't successful, return 0 and an error.
 func (app *application) readIDParam(r *http.Request) (int64, error) {
+	return app.readNamedIDParam(r, "id")
+}
+
+// readNamedIDParam works like readIDParam for routes with more than one id in the URL,
+// e.g. "/v1/movies/:id/credits/:credit_id".
+func (app *application) readNamedIDParam(r *http.Request, name string) (int64, error) {
 	params := httprouter.ParamsFromContext(r.Context())
-	id, err := strconv.ParseInt(params.ByName("id"), 10, 64)
+	id, err := strconv.ParseInt(params.ByName(name), 10, 64)
 	if err != nil || id < 1 {
-		return 0, errors.New("invalid id parameter")
+		return 0, fmt.Errorf("invalid %s parameter", name)
 	}
 	return id, nil
 }
@@ -118,6 +128,63 @@ func (app *application) readInt(qs url.Values, key string, defaultValue int) int
 	return i
 }
 
+// readInt32 is for filters on int32 columns. Unlike readInt it reports values that
+// aren't integers or don't fit in an int32 instead of ignoring them; a missing key
+// gives 0.
+func (app *application) readInt32(qs url.Values, key string, v *validator.Validator) int32 {
+	s := qs.Get(key)
+
+	if s == "" {
+		return 0
+	}
+
+	i, err := strconv.ParseInt(s, 10, 32)
+	if err != nil {
+		v.AddError(key, "must be an integer between -2147483648 and 2147483647")
+		return 0
+	}
+
+	return int32(i)
+}
+
+// readBool returns nil when the key is missing, so callers can tell "false" from
+// "not given".
+func (app *application) readBool(qs url.Values, key string, v *validator.Validator) *bool {
+	s := qs.Get(key)
+
+	if s == "" {
+		return nil
+	}
+
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		v.AddError(key, "must be true or false")
+		return nil
+	}
+
+	return &b
+}
+
+// readTime accepts RFC 3339 timestamps ("2023-04-01T00:00:00Z") and plain dates
+// ("2023-04-01", midnight UTC).
+func (app *application) readTime(qs url.Values, key string, v *validator.Validator) time.Time {
+	s := qs.Get(key)
+
+	if s == "" {
+		return time.Time{}
+	}
+
+	for _, layout := range []string{time.RFC3339, "2006-01-02"} {
+		t, err := time.Parse(layout, s)
+		if err == nil {
+			return t
+		}
+	}
+
+	v.AddError(key, "must be a date (2006-01-02) or an RFC 3339 timestamp")
+	return time.Time{}
+}
+
 func (app *application) readNameParam(r *http.Request) (string, error) {
 	params := httprouter.ParamsFromContext(r.Context())
 	name := params.ByName("name")
@@ -127,6 +194,36 @@ func (app *application) readNameParam(r *http.Request) (string, error) {
 	return name, nil
 }
 
+// clientIP returns the address of the client without the port. The API isn't meant to
+// sit behind a proxy, so forwarding headers, which any client can set, are ignored.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// actor is who the changes made for r are attributed to in the audit log.
+func (app *application) actor(r *http.Request) data.Actor {
+	actor := data.Actor{
+		IP:        clientIP(r),
+		RequestID: app.contextGetRequestID(r),
+	}
+
+	if user, ok := r.Context().Value(userContextKey).(*data.User); ok && !user.IsAnonymous() {
+		actor.UserID = user.ID
+	}
+
+	return actor
+}
+
+// audited returns the models with the actor of r set, for changes that go into the
+// audit log.
+func (app *application) audited(r *http.Request) data.Models {
+	return app.models.As(app.actor(r))
+}
+
 func (app *application) background(fn func()) {
 	app.wg.Add(1)
 
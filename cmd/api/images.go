@@ -0,0 +1,334 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+
+	// Registering the decoders for the formats we accept.
+	_ "image/gif"
+	_ "image/png"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/shynggys9219/greenlight/internal/data"
+	"github.com/shynggys9219/greenlight/internal/imaging"
+	"github.com/shynggys9219/greenlight/internal/storage"
+	"github.com/shynggys9219/greenlight/internal/validator"
+)
+
+// imageExtensions lists the sniffed content types we accept and the file extension
+// the original is stored with.
+var imageExtensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+}
+
+// uploadMovieImageHandler handles "POST /v1/movies/:id/images". The request is a
+// multipart form with the file in the "image" field and an optional "kind" field
+// (poster or still, defaults to poster).
+func (app *application) uploadMovieImageHandler(w http.ResponseWriter, r *http.Request) {
+	movieID, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, app.config.Uploads.MaxBytes+1<<20)
+
+	err = r.ParseMultipartForm(1 << 20)
+	if err != nil {
+		var maxBytesError *http.MaxBytesError
+		switch {
+		case errors.As(err, &maxBytesError):
+			app.errorResponse(w, r, http.StatusRequestEntityTooLarge, fmt.Sprintf("image must not be larger than %d bytes", app.config.Uploads.MaxBytes))
+		default:
+			app.badRequestResponse(w, r, err)
+		}
+		return
+	}
+	defer r.MultipartForm.RemoveAll()
+
+	file, header, err := r.FormFile("image")
+	if err != nil {
+		app.badRequestResponse(w, r, errors.New("body must contain an \"image\" file field"))
+		return
+	}
+	defer file.Close()
+
+	kind := r.FormValue("kind")
+	if kind == "" {
+		kind = data.ImagePoster
+	}
+
+	v := validator.New()
+	v.Check(validator.PermittedValue(kind, data.ImagePoster, data.ImageStill), "kind", "must be poster or still")
+	v.Check(header.Size <= app.config.Uploads.MaxBytes, "image", fmt.Sprintf("must not be larger than %d bytes", app.config.Uploads.MaxBytes))
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	original, err := io.ReadAll(file)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	// Never trust the client supplied Content-Type, sniff the bytes instead.
+	contentType := http.DetectContentType(original)
+	extension, ok := imageExtensions[contentType]
+	if !ok {
+		v.AddError("image", "must be a JPEG, PNG or GIF image")
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	// Check the dimensions from the header before decoding the whole image, so a
+	// small file claiming a huge canvas can't exhaust memory.
+	config, _, err := image.DecodeConfig(bytes.NewReader(original))
+	if err != nil {
+		v.AddError("image", "could not be decoded")
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	minimum, maximum := app.config.Uploads.MinDimension, app.config.Uploads.MaxDimension
+	v.Check(config.Width >= minimum && config.Height >= minimum, "image", fmt.Sprintf("must be at least %dx%d pixels", minimum, minimum))
+	v.Check(config.Width <= maximum && config.Height <= maximum, "image", fmt.Sprintf("must not be larger than %dx%d pixels", maximum, maximum))
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	// Decoding takes width*height*4 bytes and thumbnailing about as much again, so only
+	// -upload-max-decodes uploads get that far at once; the others wait for a slot.
+	select {
+	case app.decodes <- struct{}{}:
+		defer func() { <-app.decodes }()
+	case <-r.Context().Done():
+		return
+	}
+
+	decoded, _, err := image.Decode(bytes.NewReader(original))
+	if err != nil {
+		v.AddError("image", "could not be decoded")
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	name := make([]byte, 16)
+	_, err = rand.Read(name)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	img := &data.Image{
+		MovieID:     movieID,
+		Kind:        kind,
+		Key:         fmt.Sprintf("movies/%d/%s%s", movieID, hex.EncodeToString(name), extension),
+		ContentType: contentType,
+		Width:       int32(config.Width),
+		Height:      int32(config.Height),
+		Size:        int64(len(original)),
+	}
+
+	err = app.storeImage(img, original, decoded)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.models.Images.Insert(r.Context(), img)
+	if err != nil {
+		app.deleteStoredImage(img)
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.setImageURLs(img)
+
+	headers := make(http.Header)
+	headers.Set("Location", img.URL)
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"image": img}, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// storeImage writes the original and one JPEG thumbnail per imaging.ThumbnailWidths
+// entry. Each thumbnail is scaled from the previous (larger) one to keep it cheap.
+// On error everything written so far is removed again.
+func (app *application) storeImage(img *data.Image, original []byte, decoded image.Image) error {
+	err := app.storage.Put(img.Key, bytes.NewReader(original))
+	if err != nil {
+		return err
+	}
+
+	source := decoded
+	for _, width := range imaging.ThumbnailWidths {
+		if width >= decoded.Bounds().Dx() {
+			continue
+		}
+
+		thumbnail := imaging.Resize(source, width)
+
+		var buf bytes.Buffer
+		err = jpeg.Encode(&buf, thumbnail, &jpeg.Options{Quality: 85})
+		if err == nil {
+			img.ThumbnailWidths = append(img.ThumbnailWidths, int64(width))
+			err = app.storage.Put(img.ThumbnailKey(int64(width)), &buf)
+		}
+		if err != nil {
+			app.deleteStoredImage(img)
+			return err
+		}
+
+		source = thumbnail
+	}
+
+	return nil
+}
+
+func (app *application) deleteStoredImage(img *data.Image) {
+	keys := []string{img.Key}
+	for _, width := range img.ThumbnailWidths {
+		keys = append(keys, img.ThumbnailKey(width))
+	}
+
+	for _, key := range keys {
+		err := app.storage.Delete(key)
+		if err != nil && !errors.Is(err, storage.ErrNotFound) {
+			app.logger.Print(err)
+		}
+	}
+}
+
+func (app *application) setImageURLs(img *data.Image) {
+	img.URL = app.storage.URL(img.Key)
+	img.Thumbnails = make(map[string]string, len(img.ThumbnailWidths))
+	for _, width := range img.ThumbnailWidths {
+		img.Thumbnails["w"+strconv.FormatInt(width, 10)] = app.storage.URL(img.ThumbnailKey(width))
+	}
+}
+
+// attachImages embeds the images, with their URLs, into each movie of a response.
+func (app *application) attachImages(ctx context.Context, movies ...*data.Movie) error {
+	if len(movies) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, 0, len(movies))
+	for _, movie := range movies {
+		ids = append(ids, movie.ID)
+	}
+
+	images, err := app.models.Images.GetAllForMovies(ctx, ids)
+	if err != nil {
+		return err
+	}
+
+	for _, movie := range movies {
+		for _, img := range images[movie.ID] {
+			app.setImageURLs(img)
+		}
+		movie.Images = images[movie.ID]
+	}
+
+	return nil
+}
+
+func (app *application) deleteMovieImageHandler(w http.ResponseWriter, r *http.Request) {
+	movieID, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	imageID, err := app.readNamedIDParam(r, "image_id")
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	img, err := app.models.Images.Get(r.Context(), movieID, imageID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.models.Images.Delete(r.Context(), movieID, imageID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.deleteStoredImage(img)
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "image successfully deleted"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// serveImageHandler streams a stored blob for "GET /v1/images/*key". Keys are random
+// and never reused, so the response can be cached forever.
+func (app *application) serveImageHandler(w http.ResponseWriter, r *http.Request) {
+	key := httprouter.ParamsFromContext(r.Context()).ByName("key")
+	if len(key) > 0 && key[0] == '/' {
+		key = key[1:]
+	}
+
+	f, err := app.storage.Open(key)
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrNotFound), errors.Is(err, storage.ErrInvalidKey):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	defer f.Close()
+
+	contentType := mime.TypeByExtension(path.Ext(key))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+
+	_, err = io.Copy(w, f)
+	if err != nil {
+		app.logError(r, err)
+	}
+}
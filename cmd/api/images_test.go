@@ -0,0 +1,243 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/shynggys9219/greenlight/internal/data"
+	"github.com/shynggys9219/greenlight/internal/storage"
+	"github.com/shynggys9219/greenlight/internal/testdb"
+	"github.com/stretchr/testify/require"
+)
+
+// pngImage returns an encoded PNG of the given size.
+func pngImage(t *testing.T, width, height int) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, width, height))))
+
+	return buf.Bytes()
+}
+
+// multipartImage returns a multipart body with the file in the "image" field and,
+// unless empty, the kind, along with the matching Content-Type header.
+func multipartImage(t *testing.T, file []byte, kind string) (string, http.Header) {
+	t.Helper()
+
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+
+	part, err := w.CreateFormFile("image", "upload.png")
+	require.NoError(t, err)
+	_, err = part.Write(file)
+	require.NoError(t, err)
+
+	if kind != "" {
+		require.NoError(t, w.WriteField("kind", kind))
+	}
+	require.NoError(t, w.Close())
+
+	return body.String(), http.Header{"Content-Type": {w.FormDataContentType()}}
+}
+
+func TestMovieImages(t *testing.T) {
+	dir := t.TempDir()
+	store, err := storage.NewFilesystem(filepath.Join(dir, "uploads"), "/v1/images")
+	require.NoError(t, err)
+
+	ta := newTestApp(t, func(app *application) {
+		app.storage = store
+		app.config.Uploads.MaxBytes = 64 << 10
+		app.config.Uploads.MaxDimension = 1000
+	})
+
+	movie := testdb.Movie(t, ta.models)
+	path := fmt.Sprintf("/v1/movies/%d/images", movie.ID)
+
+	_, adminToken := ta.newAdmin()
+
+	upload := func(file []byte, kind string) (int, *data.Image, map[string]string) {
+		var envelope struct {
+			Image *data.Image       `json:"image"`
+			Error map[string]string `json:"error"`
+		}
+		body, header := multipartImage(t, file, kind)
+		header.Set("Authorization", "Bearer "+adminToken)
+		res := ta.doRequest(http.MethodPost, path, header, body, &envelope)
+		return res.StatusCode, envelope.Image, envelope.Error
+	}
+
+	status, img, _ := upload(pngImage(t, 600, 300), "")
+	require.Equal(t, http.StatusCreated, status)
+	require.Equal(t, data.ImagePoster, img.Kind)
+	require.Equal(t, "image/png", img.ContentType)
+	require.Equal(t, int32(600), img.Width)
+	require.Equal(t, int32(300), img.Height)
+	require.Len(t, img.Thumbnails, 3)
+
+	serve := func(url string) (int, string, []byte) {
+		res, err := http.Get(ta.server.URL + url)
+		require.NoError(t, err)
+		defer res.Body.Close()
+
+		content, err := io.ReadAll(res.Body)
+		require.NoError(t, err)
+
+		return res.StatusCode, res.Header.Get("Content-Type"), content
+	}
+
+	status, contentType, _ := serve(img.URL)
+	require.Equal(t, http.StatusOK, status)
+	require.Equal(t, "image/png", contentType)
+
+	for _, width := range []int{500, 185, 92} {
+		status, contentType, content := serve(img.Thumbnails[fmt.Sprintf("w%d", width)])
+		require.Equal(t, http.StatusOK, status)
+		require.Equal(t, "image/jpeg", contentType)
+
+		config, _, err := image.DecodeConfig(bytes.NewReader(content))
+		require.NoError(t, err)
+		require.Equal(t, width, config.Width)
+		require.InDelta(t, width/2, config.Height, 1)
+	}
+
+	// Only thumbnails narrower than the original are generated.
+	status, still, _ := upload(pngImage(t, 200, 300), data.ImageStill)
+	require.Equal(t, http.StatusCreated, status)
+	require.Equal(t, data.ImageStill, still.Kind)
+	require.Len(t, still.Thumbnails, 2)
+
+	var show struct {
+		Movie *data.Movie `json:"movie"`
+	}
+	res := ta.doRequest(http.MethodGet, fmt.Sprintf("/v1/movies/%d", movie.ID), nil, "", &show)
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	require.Len(t, show.Movie.Images, 2)
+
+	// Only admins change images.
+	_, userToken := ta.newUser()
+	res = ta.doRequest(http.MethodDelete, fmt.Sprintf("%s/%d", path, img.ID), nil, "", nil)
+	require.Equal(t, http.StatusUnauthorized, res.StatusCode)
+	res = ta.doRequest(http.MethodDelete, fmt.Sprintf("%s/%d", path, img.ID), bearer(userToken), "", nil)
+	require.Equal(t, http.StatusForbidden, res.StatusCode)
+
+	res = ta.doRequest(http.MethodDelete, fmt.Sprintf("%s/%d", path, img.ID), bearer(adminToken), "", nil)
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	status, _, _ = serve(img.URL)
+	require.Equal(t, http.StatusNotFound, status)
+	status, _, _ = serve(img.Thumbnails["w92"])
+	require.Equal(t, http.StatusNotFound, status)
+
+	res = ta.doRequest(http.MethodDelete, fmt.Sprintf("%s/%d", path, img.ID), bearer(adminToken), "", nil)
+	require.Equal(t, http.StatusNotFound, res.StatusCode)
+
+	body, header := multipartImage(t, pngImage(t, 600, 300), "")
+	res = ta.doRequest(http.MethodPost, path, header, body, nil)
+	require.Equal(t, http.StatusUnauthorized, res.StatusCode)
+	header.Set("Authorization", "Bearer "+userToken)
+	res = ta.doRequest(http.MethodPost, path, header, body, nil)
+	require.Equal(t, http.StatusForbidden, res.StatusCode)
+
+	header.Set("Authorization", "Bearer "+adminToken)
+	res = ta.doRequest(http.MethodPost, "/v1/movies/999999/images", header, body, nil)
+	require.Equal(t, http.StatusNotFound, res.StatusCode)
+}
+
+func TestMovieImageLimits(t *testing.T) {
+	dir := t.TempDir()
+	store, err := storage.NewFilesystem(filepath.Join(dir, "uploads"), "/v1/images")
+	require.NoError(t, err)
+
+	ta := newTestApp(t, func(app *application) {
+		app.storage = store
+		app.config.Uploads.MaxBytes = 64 << 10
+		app.config.Uploads.MinDimension = 100
+		app.config.Uploads.MaxDimension = 1000
+	})
+
+	movie := testdb.Movie(t, ta.models)
+	path := fmt.Sprintf("/v1/movies/%d/images", movie.ID)
+
+	_, adminToken := ta.newAdmin()
+
+	noise := make([]byte, 128<<10)
+	for i := range noise {
+		noise[i] = byte(i * 7)
+	}
+
+	tests := []struct {
+		name   string
+		file   []byte
+		kind   string
+		status int
+		error  string
+	}{
+		{"too narrow", pngImage(t, 99, 300), "", http.StatusUnprocessableEntity, "image"},
+		{"too wide", pngImage(t, 1001, 300), "", http.StatusUnprocessableEntity, "image"},
+		{"not an image", []byte("definitely not a picture"), "", http.StatusUnprocessableEntity, "image"},
+		{"truncated", pngImage(t, 300, 300)[:40], "", http.StatusUnprocessableEntity, "image"},
+		{"unknown kind", pngImage(t, 300, 300), "banner", http.StatusUnprocessableEntity, "kind"},
+		{"too many bytes", noise, "", http.StatusUnprocessableEntity, "image"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var envelope struct {
+				Error map[string]string `json:"error"`
+			}
+			body, header := multipartImage(t, tt.file, tt.kind)
+			header.Set("Authorization", "Bearer "+adminToken)
+			res := ta.doRequest(http.MethodPost, path, header, body, &envelope)
+			require.Equal(t, tt.status, res.StatusCode)
+			require.Contains(t, envelope.Error, tt.error)
+		})
+	}
+
+	body, header := multipartImage(t, make([]byte, 2<<20), "")
+	header.Set("Authorization", "Bearer "+adminToken)
+	res := ta.doRequest(http.MethodPost, path, header, body, nil)
+	require.Equal(t, http.StatusRequestEntityTooLarge, res.StatusCode)
+
+	res = ta.doRequest(http.MethodPost, path, bearer(adminToken), "not a form", nil)
+	require.Equal(t, http.StatusBadRequest, res.StatusCode)
+
+	// Nothing was stored for the rejected uploads.
+	entries, err := os.ReadDir(filepath.Join(dir, "uploads"))
+	require.NoError(t, err)
+	require.Empty(t, entries)
+}
+
+func TestServeImageRejectsTraversal(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "secret"), []byte("secret"), 0o600))
+
+	store, err := storage.NewFilesystem(filepath.Join(dir, "uploads"), "/v1/images")
+	require.NoError(t, err)
+
+	ta, _ := newMemstoreTestApp(t, func(app *application) {
+		app.storage = store
+	})
+
+	require.NoError(t, store.Put("movies/1/poster.jpg", bytes.NewReader([]byte("poster"))))
+	res := ta.doRequest(http.MethodGet, "/v1/images/movies/1/poster.jpg", nil, "", nil)
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	require.Equal(t, "image/jpeg", res.Header.Get("Content-Type"))
+
+	for _, path := range []string{
+		"/v1/images/movies/1/missing.jpg",
+		"/v1/images/%2E%2E/secret",
+		"/v1/images/movies/%2E%2E/%2E%2E/secret",
+	} {
+		res = ta.doRequest(http.MethodGet, path, nil, "", nil)
+		require.Equal(t, http.StatusNotFound, res.StatusCode, path)
+	}
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -11,11 +12,11 @@ import (
 	"net/http/httptest"
 	"os"
 	"reflect"
-	"strings"
 	"testing"
 
 	"github.com/shynggys9219/greenlight/internal/data"
 	"github.com/shynggys9219/greenlight/internal/mailer"
+	"github.com/shynggys9219/greenlight/internal/testdb"
 	"github.com/stretchr/testify/require"
 )
 
@@ -33,7 +34,7 @@ func makeRequest(method, url string, requestBody interface{}, headers http.Heade
 		config: cfg,
 		logger: logger,
 		models: data.NewModels(db), // data.NewModels() function to initialize a Models struct
-		mailer: mailer.New(cfg.smtp.host, cfg.smtp.port, cfg.smtp.username, cfg.smtp.password, cfg.smtp.sender),
+		mailer: mailer.New(mailer.NewMemory(), cfg.SMTP.Sender),
 	}
 
 	var reqBody []byte
@@ -63,17 +64,13 @@ func TestCreateMovieHandler(t *testing.T) {
 	Start()
 	logger := log.New(os.Stdout, "", log.Ldate|log.Ltime)
 
-	db, err := OpenDB(cfg)
-	if err != nil {
-		t.Fatal(err)
-	}
-	defer db.Close()
+	db := testdb.Open(t)
 
 	app := &application{
 		config: cfg,
 		logger: logger,
 		models: data.NewModels(db),
-		mailer: mailer.New(cfg.smtp.host, cfg.smtp.port, cfg.smtp.username, cfg.smtp.password, cfg.smtp.sender),
+		mailer: mailer.New(mailer.NewMemory(), cfg.SMTP.Sender),
 	}
 
 	server := httptest.NewServer(app.routes())
@@ -117,17 +114,13 @@ func TestCreateMovieHandler(t *testing.T) {
 func TestDeleteMovieHandler(t *testing.T) {
 	logger := log.New(os.Stdout, "", log.Ldate|log.Ltime)
 
-	db, err := OpenDB(cfg)
-	if err != nil {
-		t.Fatal(err)
-	}
-	defer db.Close()
+	db := testdb.Open(t)
 
 	app := &application{
 		config: cfg,
 		logger: logger,
 		models: data.NewModels(db),
-		mailer: mailer.New(cfg.smtp.host, cfg.smtp.port, cfg.smtp.username, cfg.smtp.password, cfg.smtp.sender),
+		mailer: mailer.New(mailer.NewMemory(), cfg.SMTP.Sender),
 	}
 
 	// Create a movie to be deleted
@@ -137,7 +130,7 @@ func TestDeleteMovieHandler(t *testing.T) {
 		Runtime: 64,
 		Genres:  []string{"Drama", "Sci-Fi"},
 	}
-	err = app.models.Movies.Insert(movie)
+	err := app.models.Movies.Insert(context.Background(), movie)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -176,7 +169,7 @@ func TestDeleteMovieHandler(t *testing.T) {
 	}
 
 	// Check that the movie was deleted from the database
-	_, err = app.models.Movies.Get(movie.ID)
+	_, err = app.models.Movies.Get(context.Background(), movie.ID)
 	if err == nil || !errors.Is(err, data.ErrRecordNotFound) {
 		t.Errorf("expected ErrRecordNotFound but got %v", err)
 	}
@@ -185,17 +178,13 @@ func TestDeleteMovieHandler(t *testing.T) {
 func TestShowMovieHandler(t *testing.T) {
 	logger := log.New(os.Stdout, "", log.Ldate|log.Ltime)
 
-	db, err := OpenDB(cfg)
-	if err != nil {
-		t.Fatal(err)
-	}
-	defer db.Close()
+	db := testdb.Open(t)
 
 	app := &application{
 		config: cfg,
 		logger: logger,
 		models: data.NewModels(db),
-		mailer: mailer.New(cfg.smtp.host, cfg.smtp.port, cfg.smtp.username, cfg.smtp.password, cfg.smtp.sender),
+		mailer: mailer.New(mailer.NewMemory(), cfg.SMTP.Sender),
 	}
 
 	server := httptest.NewServer(app.routes())
@@ -209,7 +198,7 @@ func TestShowMovieHandler(t *testing.T) {
 		Genres:  []string{"Drama", "Sci-Fi"},
 	}
 
-	err = app.models.Movies.Insert(movie)
+	err := app.models.Movies.Insert(context.Background(), movie)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -258,17 +247,13 @@ func TestServe(t *testing.T) {
 	// app := newApplication(cfg)
 	logger := log.New(os.Stdout, "", log.Ldate|log.Ltime)
 
-	db, err := OpenDB(cfg)
-	if err != nil {
-		t.Fatal(err)
-	}
-	defer db.Close()
+	db := testdb.Open(t)
 
 	app := &application{
 		config: cfg,
 		logger: logger,
 		models: data.NewModels(db),
-		mailer: mailer.New(cfg.smtp.host, cfg.smtp.port, cfg.smtp.username, cfg.smtp.password, cfg.smtp.sender),
+		mailer: mailer.New(mailer.NewMemory(), cfg.SMTP.Sender),
 	}
 
 	server := httptest.NewServer(app.routes())
@@ -331,47 +316,42 @@ func TestMethodNotAllowedResponse(t *testing.T) {
 func TestApplication_editConflictResponse(t *testing.T) {
 	logger := log.New(os.Stdout, "", log.Ldate|log.Ltime)
 
-	db, err := OpenDB(cfg)
-	if err != nil {
-		t.Fatal(err)
-	}
-	defer db.Close()
+	db := testdb.Open(t)
 
 	app := &application{
 		config: cfg,
 		logger: logger,
 		models: data.NewModels(db),
-		mailer: mailer.New(cfg.smtp.host, cfg.smtp.port, cfg.smtp.username, cfg.smtp.password, cfg.smtp.sender),
+		mailer: mailer.New(mailer.NewMemory(), cfg.SMTP.Sender),
 	}
 
-	server := httptest.NewServer(app.routes())
-	defer server.Close()
-
 	movie := &data.Movie{
 		Title:   "Test Movie",
 		Year:    2022,
 		Runtime: 90,
 		Genres:  []string{"Action", "Adventure"},
 	}
-	err = app.models.Movies.Insert(movie)
+	err := app.models.Movies.Insert(context.Background(), movie)
 	require.NoError(t, err)
 
 	// Simulate another user updating the movie
-	movie.Version++
-	err = app.models.Movies.Update(movie)
+	other := *movie
+	other.Title = "Other Title"
+	err = app.models.Movies.Update(context.Background(), &other)
 	require.NoError(t, err)
 
-	// Attempt to update the movie again and expect an edit conflict response
-	reqBody := `{"title": "New Title", "year": 2023, "runtime": 100, "genres": ["Action", "Drama"]}`
-	req := httptest.NewRequest(http.MethodPut, "/v1/movies/1", strings.NewReader(reqBody))
-	req.Header.Set("Content-Type", "application/json")
+	// Attempt to update the movie again and expect an edit conflict
+	movie.Title = "New Title"
+	err = app.models.Movies.Update(context.Background(), movie)
+	require.ErrorIs(t, err, data.ErrEditConflict)
+
+	req := httptest.NewRequest(http.MethodPatch, fmt.Sprintf("/v1/movies/%d", movie.ID), nil)
 	rr := httptest.NewRecorder()
 
-	// app.srv.Handler.ServeHTTP(rr, req)
-	server.Client().Do(req)
+	app.editConflictResponse(rr, req)
 
 	// Expect a 409 Conflict response with the expected error message
-	expectedResponse := envelope{"error": "unable to update the record due to an edit conflict, please try again"}
+	expectedResponse := map[string]interface{}{"error": "unable to update the record due to an edit conflict, please try again"}
 	assertResponse(t, rr, http.StatusConflict, expectedResponse)
 }
 
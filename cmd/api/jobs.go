@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/shynggys9219/greenlight/internal/data"
+	"github.com/shynggys9219/greenlight/internal/validator"
+)
+
+// job is a task the scheduler runs every Jobs.Interval. run returns the number of
+// rows it affected, which goes into the job's run history. Jobs have to be safe to
+// run concurrently, because every API instance with -jobs-enabled runs them, and
+// should stop when ctx is cancelled at shutdown.
+type job struct {
+	name string
+	run  func(ctx context.Context) (int64, error)
+}
+
+func (app *application) jobs() []job {
+	return []job{
+		{
+			name: "purge_expired_tokens",
+			run: func(ctx context.Context) (int64, error) {
+				tokens, err := app.models.Token.DeleteExpired(ctx)
+				if err != nil {
+					return 0, err
+				}
+
+				sessions, err := app.models.Sessions.DeleteExpired(ctx)
+				return tokens + sessions, err
+			},
+		},
+		{
+			name: "delete_unactivated_users",
+			run: func(ctx context.Context) (int64, error) {
+				return app.models.User.DeleteUnactivated(ctx, time.Now().Add(-app.config.Jobs.UnactivatedUserGrace))
+			},
+		},
+		{
+			name: "refresh_movie_similarities",
+			run: func(ctx context.Context) (int64, error) {
+				return app.models.Similar.RefreshAll(ctx)
+			},
+		},
+	}
+}
+
+// startScheduler runs every job once right away and then every Jobs.Interval, each in
+// its own goroutine. They stop when ctx is cancelled and are tracked by app.wg, so
+// shutdown waits for a run in progress.
+func (app *application) startScheduler(ctx context.Context) {
+	if !app.config.Jobs.Enabled {
+		return
+	}
+
+	for _, j := range app.jobs() {
+		j := j
+
+		app.wg.Add(1)
+
+		go func() {
+			defer app.wg.Done()
+
+			ticker := time.NewTicker(app.config.Jobs.Interval)
+			defer ticker.Stop()
+
+			for {
+				app.runJob(ctx, j)
+
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+				}
+			}
+		}()
+	}
+}
+
+// runJob runs j once and records the run. A panicking job is recorded as failed
+// instead of taking the server down.
+func (app *application) runJob(ctx context.Context, j job) *data.JobRun {
+	run := &data.JobRun{
+		Job:       j.name,
+		StartedAt: time.Now(),
+	}
+
+	func() {
+		defer func() {
+			if err := recover(); err != nil {
+				run.Error = fmt.Sprintf("panic: %s", err)
+			}
+		}()
+
+		affected, err := j.run(ctx)
+		run.Affected = affected
+		if err != nil {
+			run.Error = err.Error()
+		}
+	}()
+
+	run.FinishedAt = time.Now()
+
+	if run.Error != "" {
+		app.logger.Printf("job %s failed: %s", j.name, run.Error)
+	}
+
+	// Runs cut short by shutdown are recorded too, so not with ctx.
+	err := app.models.JobRuns.Insert(context.Background(), run)
+	if err != nil {
+		app.logger.Print(fmt.Errorf("job %s: %w", j.name, err))
+	}
+
+	return run
+}
+
+// listJobRunsHandler shows the run history for "GET /v1/admin/jobs/runs", newest
+// first, e.g. "?job=purge_expired_tokens".
+func (app *application) listJobRunsHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Job string
+		data.Filters
+	}
+
+	qs := r.URL.Query()
+
+	input.Job = app.readString(qs, "job", "")
+	input.Filters.Page = app.readInt(qs, "page", 1)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20)
+
+	v := validator.New()
+
+	names := []string{""}
+	for _, j := range app.jobs() {
+		names = append(names, j.name)
+	}
+
+	v.Check(validator.PermittedValue(input.Job, names...), "job", "must be the name of a job")
+	v.Check(input.Filters.Page > 0, "page", "must be greater than zero")
+	v.Check(input.Filters.PageSize > 0 && input.Filters.PageSize <= 100, "page_size", "must be between 1 and 100")
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	runs, err := app.models.JobRuns.GetAll(r.Context(), input.Job, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"runs": runs}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/shynggys9219/greenlight/internal/data"
+	"github.com/shynggys9219/greenlight/internal/testdb"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPurgeExpiredTokensJob(t *testing.T) {
+	ta := newTestApp(t)
+
+	user := testdb.User(t, ta.models, func(user *data.User) { user.Activated = false })
+
+	expired, err := ta.models.Token.New(context.Background(), user.ID, -time.Hour, data.ScopeActivation)
+	require.NoError(t, err)
+
+	run := ta.runJob(context.Background(), ta.job("purge_expired_tokens"))
+	require.Empty(t, run.Error)
+	require.GreaterOrEqual(t, run.Affected, int64(1))
+
+	var count int
+	require.NoError(t, ta.db.QueryRow(`SELECT count(*) FROM tokens WHERE hash = $1`, expired.Hash).Scan(&count))
+	require.Zero(t, count)
+
+	runs, err := ta.models.JobRuns.GetAll(context.Background(), "purge_expired_tokens", data.Filters{Page: 1, PageSize: 1})
+	require.NoError(t, err)
+	require.Equal(t, run.ID, runs[0].ID)
+}
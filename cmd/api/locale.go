@@ -0,0 +1,93 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/shynggys9219/greenlight/internal/data"
+	"github.com/shynggys9219/greenlight/internal/validator"
+)
+
+// requestLanguages returns the languages the client asked for, most preferred first.
+// An explicit ?lang= query parameter wins over the Accept-Language header.
+func (app *application) requestLanguages(r *http.Request) []string {
+	if lang := r.URL.Query().Get("lang"); lang != "" && validator.Matches(lang, validator.LanguageRX) {
+		return []string{lang}
+	}
+
+	return parseAcceptLanguage(r.Header.Get("Accept-Language"))
+}
+
+// parseAcceptLanguage parses a header such as "ru-RU,ru;q=0.9,en;q=0.8" into
+// ["ru-RU", "ru", "en"]. Wildcards and malformed entries are skipped.
+func parseAcceptLanguage(header string) []string {
+	type weighted struct {
+		tag string
+		q   float64
+	}
+
+	var tags []weighted
+
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		tag := strings.TrimSpace(fields[0])
+		if !validator.Matches(tag, validator.LanguageRX) {
+			continue
+		}
+
+		q := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				value, err := strconv.ParseFloat(strings.TrimPrefix(param, "q="), 64)
+				if err == nil {
+					q = value
+				}
+			}
+		}
+
+		if q > 0 {
+			tags = append(tags, weighted{tag: tag, q: q})
+		}
+	}
+
+	sort.SliceStable(tags, func(i, j int) bool {
+		return tags[i].q > tags[j].q
+	})
+
+	languages := make([]string, 0, len(tags))
+	for _, t := range tags {
+		languages = append(languages, t.tag)
+	}
+
+	return languages
+}
+
+// localizeMovies swaps in the localized title of every movie for the languages of
+// the request. Movies without a matching title keep their original title.
+func (app *application) localizeMovies(w http.ResponseWriter, r *http.Request, movies ...*data.Movie) error {
+	w.Header().Add("Vary", "Accept-Language")
+
+	languages := app.requestLanguages(r)
+	if len(languages) == 0 || len(movies) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, 0, len(movies))
+	for _, movie := range movies {
+		ids = append(ids, movie.ID)
+	}
+
+	titles, err := app.models.Titles.GetAllForMovies(r.Context(), ids)
+	if err != nil {
+		return err
+	}
+
+	for _, movie := range movies {
+		movie.Localize(titles[movie.ID], languages)
+	}
+
+	return nil
+}
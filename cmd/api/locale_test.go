@@ -0,0 +1,58 @@
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseAcceptLanguage(t *testing.T) {
+	tests := []struct {
+		header string
+		want   []string
+	}{
+		{"", []string{}},
+		{"en", []string{"en"}},
+		{"ru-RU,ru;q=0.9,en;q=0.8", []string{"ru-RU", "ru", "en"}},
+		{"en;q=0.5, ru", []string{"ru", "en"}},
+		{"de;q=0.7,fr;q=0.7,en", []string{"en", "de", "fr"}},
+		{"*, en;q=0.5", []string{"en"}},
+		{"en, ru;q=0", []string{"en"}},
+		{"en;q=oops, ru;q=0.5", []string{"en", "ru"}},
+		{"english, ru_RU, pt-BR", []string{"pt-BR"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.header, func(t *testing.T) {
+			require.Equal(t, tt.want, parseAcceptLanguage(tt.header))
+		})
+	}
+}
+
+func TestRequestLanguages(t *testing.T) {
+	app := &application{}
+
+	tests := []struct {
+		name   string
+		target string
+		header string
+		want   []string
+	}{
+		{"header only", "/v1/movies/1", "ru,en;q=0.5", []string{"ru", "en"}},
+		{"query wins over header", "/v1/movies/1?lang=pt-BR", "ru,en;q=0.5", []string{"pt-BR"}},
+		{"invalid query falls back to header", "/v1/movies/1?lang=not_a_lang", "ru", []string{"ru"}},
+		{"empty query falls back to header", "/v1/movies/1?lang=", "en", []string{"en"}},
+		{"nothing requested", "/v1/movies/1", "", []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", tt.target, nil)
+			if tt.header != "" {
+				r.Header.Set("Accept-Language", tt.header)
+			}
+			require.Equal(t, tt.want, app.requestLanguages(r))
+		})
+	}
+}
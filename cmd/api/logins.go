@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/shynggys9219/greenlight/internal/data"
+	"github.com/shynggys9219/greenlight/internal/validator"
+)
+
+// loginWait returns how long the client has to wait before its next login attempt for
+// email may be checked, zero if it may go ahead. An address with too many failures is
+// blocked for Login.Lockout; an account's attempts are spaced out by a delay that
+// doubles with each failure, up to Login.MaxDelay.
+func (app *application) loginWait(ctx context.Context, email, ip string) (time.Duration, error) {
+	cfg := app.config.Login
+	since := time.Now().Add(-cfg.Window)
+
+	failures, err := app.models.Logins.FailuresForIP(ctx, ip, since)
+	if err != nil {
+		return 0, err
+	}
+
+	if failures.Count >= cfg.IPMaxFailures {
+		if wait := time.Until(failures.Last.Add(cfg.Lockout)); wait > 0 {
+			return wait, nil
+		}
+	}
+
+	failures, err = app.models.Logins.FailuresForEmail(ctx, email, since)
+	if err != nil {
+		return 0, err
+	}
+
+	if failures.Count == 0 {
+		return 0, nil
+	}
+
+	delay := cfg.Delay
+	for i := 1; i < failures.Count && delay < cfg.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > cfg.MaxDelay {
+		delay = cfg.MaxDelay
+	}
+
+	return time.Until(failures.Last.Add(delay)), nil
+}
+
+// loginFailed records a failed login and writes the response. user is nil when no
+// account has the email. Once an account reaches Login.MaxFailures it is locked out
+// and its owner is mailed an unlock token. The response is always the same, so it
+// doesn't tell whether the email has an account or whether it is locked out.
+func (app *application) loginFailed(w http.ResponseWriter, r *http.Request, user *data.User, email, ip string) {
+	err := app.models.Logins.Insert(r.Context(), &data.LoginAttempt{Email: email, IP: ip})
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if user == nil || user.IsLockedOut() {
+		app.invalidCredentialsResponse(w, r)
+		return
+	}
+
+	cfg := app.config.Login
+
+	failures, err := app.models.Logins.FailuresForEmail(r.Context(), email, time.Now().Add(-cfg.Window))
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if failures.Count < cfg.MaxFailures {
+		app.invalidCredentialsResponse(w, r)
+		return
+	}
+
+	_, err = app.audited(r).User.LockOut(r.Context(), user, time.Now().Add(cfg.Lockout), 24*time.Hour, "account_locked")
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.logger.Printf("user %d locked out after %d failed logins", user.ID, failures.Count)
+
+	app.invalidCredentialsResponse(w, r)
+}
+
+// unlockAccountHandler handles "PUT /v1/users/unlocked" with the token from the
+// lockout email, ending the lockout early.
+func (app *application) unlockAccountHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		TokenPlaintext string `json:"token"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	if data.ValidateTokenPlaintext(v, input.TokenPlaintext); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	user, err := app.models.User.GetForToken(r.Context(), data.ScopeUnlock, input.TokenPlaintext)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			v.AddError("token", "invalid or expired unlock token")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.audited(r).WithTx(r.Context(), func(m data.Models) error {
+		err := clearLockout(r.Context(), m, user)
+		if err != nil {
+			return err
+		}
+
+		return m.Token.DeleteAllForUser(r.Context(), data.ScopeUnlock, user.ID)
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"user": user}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// clearUserLockoutHandler handles "DELETE /v1/admin/users/:id/lockout".
+func (app *application) clearUserLockoutHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := app.readUser(w, r)
+	if !ok {
+		return
+	}
+
+	err := app.audited(r).WithTx(r.Context(), func(m data.Models) error {
+		return clearLockout(r.Context(), m, user)
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"user": user}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// clearIPLockoutHandler handles "DELETE /v1/admin/ips/:ip/lockout" and forgets the
+// failed logins from that address.
+func (app *application) clearIPLockoutHandler(w http.ResponseWriter, r *http.Request) {
+	ip := httprouter.ParamsFromContext(r.Context()).ByName("ip")
+	if net.ParseIP(ip) == nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	err := app.models.Logins.ClearIP(r.Context(), ip)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "lockout successfully cleared"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// clearLockout ends the user's lockout and forgets the failed logins that led to it.
+// Callers run it in a transaction with data.Models.WithTx.
+func clearLockout(ctx context.Context, m data.Models, user *data.User) error {
+	user.LockedUntil = nil
+
+	err := m.User.Update(ctx, user)
+	if err != nil {
+		return err
+	}
+
+	return m.Logins.ClearEmail(ctx, user.Email)
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/shynggys9219/greenlight/internal/testdb"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoginLockout(t *testing.T) {
+	ta, _ := newMemstoreTestApp(t, func(app *application) {
+		app.config.Login.MaxFailures = 3
+	})
+
+	user := testdb.User(t, ta.models)
+
+	login := func(password string) *http.Response {
+		body := fmt.Sprintf(`{"email": %q, "password": %q}`, user.Email, password)
+		return ta.doRequest(http.MethodPost, "/v1/tokens/authentication", nil, body, nil)
+	}
+
+	for i := 0; i < ta.config.Login.MaxFailures; i++ {
+		require.Equal(t, http.StatusUnauthorized, login("wrong-password").StatusCode)
+	}
+
+	// Only the right password learns about the lockout, and is refused during it.
+	// A wrong one gets the same answer as an email without an account.
+	require.Equal(t, http.StatusUnauthorized, login("wrong-password").StatusCode)
+
+	res := ta.doRequest(http.MethodPost, "/v1/tokens/authentication", nil, `{"email": "nobody@example.com", "password": "wrong-password"}`, nil)
+	require.Equal(t, http.StatusUnauthorized, res.StatusCode)
+
+	res = login(testdb.FixturePassword)
+	require.Equal(t, http.StatusForbidden, res.StatusCode)
+	require.NotEmpty(t, res.Header.Get("Retry-After"))
+
+	unlock := ta.emailToken(user.Email, "account_locked")
+
+	res = ta.doRequest(http.MethodPut, "/v1/users/unlocked", nil, fmt.Sprintf(`{"token": %q}`, unlock), nil)
+	require.Equal(t, http.StatusOK, res.StatusCode)
+
+	require.Equal(t, http.StatusCreated, login(testdb.FixturePassword).StatusCode)
+}
@@ -0,0 +1,303 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/shynggys9219/greenlight/internal/data"
+	"github.com/shynggys9219/greenlight/internal/validator"
+)
+
+// recoverPanic turns a panic in a handler into a 500 response instead of a dropped
+// connection.
+func (app *application) recoverPanic(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			if err := recover(); err != nil {
+				w.Header().Set("Connection", "close")
+				app.serverErrorResponse(w, r, fmt.Errorf("%s", err))
+			}
+		}()
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requestIDRX matches the request IDs accepted from clients; anything else is replaced.
+var requestIDRX = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// requestID gives every request an ID, echoed in the X-Request-ID response header and
+// recorded with audit events. An ID sent by the client, e.g. a gateway, is kept so
+// requests can be traced across services.
+func (app *application) requestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get("X-Request-ID")
+
+		if !requestIDRX.MatchString(id) {
+			random := make([]byte, 16)
+
+			_, err := rand.Read(random)
+			if err != nil {
+				app.serverErrorResponse(w, r, err)
+				return
+			}
+
+			id = hex.EncodeToString(random)
+		}
+
+		w.Header().Set("X-Request-ID", id)
+		r = app.contextSetRequestID(r, id)
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// authenticate looks up the user of an "Authorization: Bearer <token>" or
+// "Authorization: ApiKey <key>" header and stores it in the request context. Requests
+// without the header get the AnonymousUser; requests with an invalid token or key are
+// rejected. Bearer tokens are checked as -auth-mode says: signed JWTs in stateless
+// mode, tokens from the tokens table in stateful mode. Switching modes invalidates
+// the access tokens of the other one; clients get new ones with their refresh token.
+func (app *application) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Authorization")
+
+		authorizationHeader := r.Header.Get("Authorization")
+		if authorizationHeader == "" {
+			r = app.contextSetUser(r, data.AnonymousUser)
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		headerParts := strings.Split(authorizationHeader, " ")
+		if len(headerParts) != 2 {
+			app.invalidAuthenticationTokenResponse(w, r)
+			return
+		}
+
+		var user *data.User
+		var err error
+
+		switch headerParts[0] {
+		case "Bearer":
+			var session *data.Session
+			if app.config.Auth.Mode == "stateless" {
+				readOnly := methodPermission(r.Method) == data.PermissionRead
+				user, session, err = app.userForJWT(r.Context(), headerParts[1], readOnly)
+				if err == nil && !readOnly {
+					// The request may change the account, so later reads load it again.
+					defer app.users.forget(user.ID)
+				}
+			} else {
+				user, session, err = app.userForToken(r.Context(), headerParts[1])
+			}
+			if err == nil && session != nil {
+				r = app.contextSetSession(r, session)
+			}
+		case "ApiKey":
+			var key *data.APIKey
+			key, user, err = app.userForAPIKey(r.Context(), headerParts[1])
+			if err == nil {
+				if !key.Permits(methodPermission(r.Method)) {
+					app.notPermittedResponse(w, r)
+					return
+				}
+				r = app.contextSetAPIKey(r, key)
+			}
+		default:
+			err = data.ErrRecordNotFound
+		}
+		if err != nil {
+			switch {
+			case errors.Is(err, data.ErrRecordNotFound):
+				app.invalidAuthenticationTokenResponse(w, r)
+			default:
+				app.serverErrorResponse(w, r, err)
+			}
+			return
+		}
+
+		// Locking an account takes effect immediately, even for tokens issued before;
+		// for reads with a signed token, see userCache.
+		if user.Locked {
+			app.lockedAccountResponse(w, r)
+			return
+		}
+
+		r = app.contextSetUser(r, user)
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// userForToken returns the user of a bearer token and the session it belongs to,
+// ErrRecordNotFound if the token is malformed, unknown or expired. The session is nil
+// for tokens issued outside a session.
+func (app *application) userForToken(ctx context.Context, token string) (*data.User, *data.Session, error) {
+	v := validator.New()
+	if data.ValidateTokenPlaintext(v, token); !v.Valid() {
+		return nil, nil, data.ErrRecordNotFound
+	}
+
+	user, err := app.models.User.GetForToken(ctx, data.ScopeAuthentication, token)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	session, err := app.models.Sessions.GetForToken(ctx, token)
+	if err != nil && !errors.Is(err, data.ErrRecordNotFound) {
+		return nil, nil, err
+	}
+
+	return user, session, nil
+}
+
+// userForJWT returns the user and session of a signed access token, ErrRecordNotFound
+// if the token is invalid or expired. Only the user is read from the database, so
+// a revoked session's tokens keep working until they expire; keep -session-access-ttl
+// short in stateless mode. With cached set the user may come from app.users, which
+// read requests use to skip the database.
+func (app *application) userForJWT(ctx context.Context, token string, cached bool) (*data.User, *data.Session, error) {
+	claims, err := app.signer.Verify(token)
+	if err != nil {
+		return nil, nil, data.ErrRecordNotFound
+	}
+
+	id, err := strconv.ParseInt(claims.Subject, 10, 64)
+	if err != nil {
+		return nil, nil, data.ErrRecordNotFound
+	}
+
+	var user *data.User
+	var ok bool
+
+	if cached {
+		user, ok = app.users.get(id)
+	}
+
+	if !ok {
+		user, err = app.models.User.Get(ctx, id)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		app.users.put(user)
+	}
+
+	var session *data.Session
+	if claims.SessionID != 0 {
+		session = &data.Session{ID: claims.SessionID, UserID: user.ID}
+	}
+
+	return user, session, nil
+}
+
+// userForAPIKey returns an API key and its owner, ErrRecordNotFound if the key is
+// malformed, unknown or expired.
+func (app *application) userForAPIKey(ctx context.Context, plaintext string) (*data.APIKey, *data.User, error) {
+	v := validator.New()
+	if data.ValidateAPIKeyPlaintext(v, plaintext); !v.Valid() {
+		return nil, nil, data.ErrRecordNotFound
+	}
+
+	key, err := app.models.APIKeys.GetForPlaintext(ctx, plaintext)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	user, err := app.models.User.Get(ctx, key.UserID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return key, user, nil
+}
+
+// methodPermission is the API key permission a request method needs.
+func methodPermission(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return data.PermissionRead
+	default:
+		return data.PermissionWrite
+	}
+}
+
+func (app *application) requireAuthenticatedUser(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user := app.contextGetUser(r)
+
+		if user.IsAnonymous() {
+			app.authenticationRequiredResponse(w, r)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	}
+}
+
+func (app *application) requireActivatedUser(next http.HandlerFunc) http.HandlerFunc {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		user := app.contextGetUser(r)
+
+		if !user.Activated {
+			app.inactiveAccountResponse(w, r)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	}
+
+	return app.requireAuthenticatedUser(fn)
+}
+
+// requireSession is requireAuthenticatedUser for routes that manage the account
+// itself, like changing the password or creating API keys. API keys can't be used
+// there, whatever their permissions.
+func (app *application) requireSession(next http.HandlerFunc) http.HandlerFunc {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		if app.contextGetAPIKey(r) != nil {
+			app.apiKeyNotPermittedResponse(w, r)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	}
+
+	return app.requireAuthenticatedUser(fn)
+}
+
+// requireRole only lets activated users through that were granted the role in the
+// roles table. Requests made with an API key also need the key's admin permission.
+func (app *application) requireRole(role string, next http.HandlerFunc) http.HandlerFunc {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		user := app.contextGetUser(r)
+
+		if key := app.contextGetAPIKey(r); key != nil && !key.Permits(data.PermissionAdmin) {
+			app.notPermittedResponse(w, r)
+			return
+		}
+
+		roles, err := app.models.Role.GetAllForUser(r.Context(), user.ID)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		if !validator.PermittedValue(role, roles...) {
+			app.notPermittedResponse(w, r)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	}
+
+	return app.requireActivatedUser(fn)
+}
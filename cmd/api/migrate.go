@@ -0,0 +1,77 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+
+	"github.com/shynggys9219/greenlight/internal/migrate"
+	"github.com/shynggys9219/greenlight/migrations"
+)
+
+const migrateUsage = `usage: api [flags] migrate <command>
+
+commands:
+  up           apply all pending migrations
+  down [N]     revert the last N migrations (default 1)
+  goto V       migrate up or down to version V (0 reverts everything)
+  version      print the current version
+  force V      record V as the current version without running anything`
+
+var errMigrateUsage = errors.New(migrateUsage)
+
+// runMigrate handles the "migrate" subcommand, e.g. "api -db-dsn=... migrate up".
+func runMigrate(db *sql.DB, logger *log.Logger, args []string) error {
+	if len(args) == 0 {
+		return errMigrateUsage
+	}
+
+	m := migrate.New(db, migrations.FS, logger)
+
+	switch args[0] {
+	case "up":
+		if len(args) != 1 {
+			return errMigrateUsage
+		}
+		return m.Up()
+	case "down":
+		n := 1
+		if len(args) == 2 {
+			var err error
+			n, err = strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				return errMigrateUsage
+			}
+		} else if len(args) > 2 {
+			return errMigrateUsage
+		}
+		return m.Down(n)
+	case "goto", "force":
+		if len(args) != 2 {
+			return errMigrateUsage
+		}
+		version, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil || version < 0 {
+			return errMigrateUsage
+		}
+		if args[0] == "goto" {
+			return m.Goto(version)
+		}
+		return m.Force(version)
+	case "version":
+		version, dirty, err := m.Version()
+		if err != nil {
+			return err
+		}
+		if dirty {
+			fmt.Printf("%d (dirty)\n", version)
+		} else {
+			fmt.Println(version)
+		}
+		return nil
+	default:
+		return errMigrateUsage
+	}
+}
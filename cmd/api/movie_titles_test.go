@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/shynggys9219/greenlight/internal/data"
+	"github.com/shynggys9219/greenlight/internal/testdb"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMovieTitles(t *testing.T) {
+	ta := newTestApp(t)
+
+	movie := testdb.Movie(t, ta.models, func(m *data.Movie) { m.Title = "Alien"; m.Year = 1979 })
+
+	_, adminToken := ta.newAdmin()
+	admin := bearer(adminToken)
+
+	put := func(lang, body string) (int, map[string]string) {
+		var envelope struct {
+			Error map[string]string `json:"error"`
+		}
+		res := ta.doRequest(http.MethodPut, fmt.Sprintf("/v1/movies/%d/titles/%s", movie.ID, lang), admin, body, &envelope)
+		return res.StatusCode, envelope.Error
+	}
+
+	status, _ := put("en", `{"title": "Alien", "is_original": true}`)
+	require.Equal(t, http.StatusOK, status)
+	status, _ = put("ru", `{"title": "Чужой"}`)
+	require.Equal(t, http.StatusOK, status)
+
+	status, errs := put("fr", `{"title": "Alien, le huitième passager", "is_original": true}`)
+	require.Equal(t, http.StatusUnprocessableEntity, status)
+	require.Contains(t, errs, "is_original")
+
+	status, errs = put("english", `{"title": "Alien"}`)
+	require.Equal(t, http.StatusUnprocessableEntity, status)
+	require.Contains(t, errs, "language")
+
+	var list struct {
+		Titles []*data.MovieTitle `json:"titles"`
+	}
+	res := ta.doRequest(http.MethodGet, fmt.Sprintf("/v1/movies/%d/titles", movie.ID), nil, "", &list)
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	require.Len(t, list.Titles, 2)
+
+	show := func(query string, header http.Header) *data.Movie {
+		var envelope struct {
+			Movie *data.Movie `json:"movie"`
+		}
+		res := ta.doRequest(http.MethodGet, fmt.Sprintf("/v1/movies/%d%s", movie.ID, query), header, "", &envelope)
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		require.Contains(t, res.Header.Values("Vary"), "Accept-Language")
+		return envelope.Movie
+	}
+
+	localized := show("", http.Header{"Accept-Language": {"ru-RU,ru;q=0.9,en;q=0.8"}})
+	require.Equal(t, "Чужой", localized.Title)
+	require.Equal(t, "Alien", localized.OriginalTitle)
+	require.Equal(t, "ru", localized.Language)
+
+	localized = show("?lang=en", http.Header{"Accept-Language": {"ru"}})
+	require.Equal(t, "Alien", localized.Title)
+	require.Empty(t, localized.OriginalTitle)
+	require.Equal(t, "en", localized.Language)
+
+	localized = show("", http.Header{"Accept-Language": {"de"}})
+	require.Equal(t, "Alien", localized.Title)
+	require.Equal(t, "en", localized.Language)
+
+	// Localized titles are searched with the text search configuration of their language.
+	var search struct {
+		Movies []*data.Movie `json:"movies"`
+	}
+	res = ta.doRequest(http.MethodGet, "/v1/movies?title=%D1%87%D1%83%D0%B6%D0%BE%D0%B9", nil, "", &search)
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	require.Len(t, search.Movies, 1)
+	require.Equal(t, movie.ID, search.Movies[0].ID)
+
+	// Only admins change titles.
+	_, userToken := ta.newUser()
+	res = ta.doRequest(http.MethodPut, fmt.Sprintf("/v1/movies/%d/titles/de", movie.ID), nil, `{"title": "Alien"}`, nil)
+	require.Equal(t, http.StatusUnauthorized, res.StatusCode)
+	res = ta.doRequest(http.MethodDelete, fmt.Sprintf("/v1/movies/%d/titles/ru", movie.ID), bearer(userToken), "", nil)
+	require.Equal(t, http.StatusForbidden, res.StatusCode)
+
+	res = ta.doRequest(http.MethodDelete, fmt.Sprintf("/v1/movies/%d/titles/ru", movie.ID), admin, "", nil)
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	res = ta.doRequest(http.MethodDelete, fmt.Sprintf("/v1/movies/%d/titles/ru", movie.ID), admin, "", nil)
+	require.Equal(t, http.StatusNotFound, res.StatusCode)
+
+	res = ta.doRequest(http.MethodPut, "/v1/movies/999999/titles/ru", admin, `{"title": "Чужой"}`, nil)
+	require.Equal(t, http.StatusNotFound, res.StatusCode)
+}
@@ -6,6 +6,7 @@ import (
 	"net/http"
 
 	"github.com/shynggys9219/greenlight/internal/data"
+	"github.com/shynggys9219/greenlight/internal/validator"
 )
 
 // Add a createMovieHandler for the "POST /v1/movies" endpoint.
@@ -35,7 +36,7 @@ func (app *application) createMovieHandler(w http.ResponseWriter, r *http.Reques
 		Genres:  input.Genres,
 	}
 
-	err = app.models.Movies.Insert(movie)
+	err = app.audited(r).Movies.Insert(r.Context(), movie)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 		return
@@ -60,7 +61,7 @@ func (app *application) showMovieHandler(w http.ResponseWriter, r *http.Request)
 		app.notFoundResponse(w, r)
 	}
 
-	movie, err := app.models.Movies.Get(id)
+	movie, err := app.models.Movies.Get(r.Context(), id)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
@@ -70,6 +71,18 @@ func (app *application) showMovieHandler(w http.ResponseWriter, r *http.Request)
 		}
 		return
 	}
+
+	err = app.localizeMovies(w, r, movie)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.attachImages(r.Context(), movie)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
 	// Encode the struct to JSON and send it as the HTTP response.
 	// using envelope
 	err = app.writeJSON(w, http.StatusOK, envelope{"movie": movie}, nil)
@@ -86,7 +99,7 @@ func (app *application) deleteMovieHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	err = app.models.Movies.Delete(id)
+	err = app.audited(r).Movies.Delete(r.Context(), id)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
@@ -112,7 +125,7 @@ func (app *application) updateMovieHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	movie, err := app.models.Movies.Get(id)
+	movie, err := app.models.Movies.Get(r.Context(), id)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
@@ -157,7 +170,7 @@ func (app *application) updateMovieHandler(w http.ResponseWriter, r *http.Reques
 	// movie.Runtime = input.Runtime
 	// movie.Genres = input.Genres
 
-	err = app.models.Movies.Update(movie)
+	err = app.audited(r).Movies.Update(r.Context(), movie)
 	if err != nil {
 		// app.serverErrorResponse(w, r, err)
 		// return
@@ -187,15 +200,24 @@ func (app *application) listMoviesHandler(w http.ResponseWriter, r *http.Request
 	// }
 
 	var input struct {
-		Title  string
-		Genres []string
+		data.MovieFilter
 		data.Filters
 	}
 
+	v := validator.New()
+
 	qs := r.URL.Query()
 
 	input.Title = app.readString(qs, "title", "")
 	input.Genres = app.readCSV(qs, "genres", []string{})
+	input.GenresMode = app.readString(qs, "genres_mode", data.GenresAll)
+	input.ExcludeGenres = app.readCSV(qs, "exclude_genres", []string{})
+	input.YearMin = app.readInt32(qs, "year_min", v)
+	input.YearMax = app.readInt32(qs, "year_max", v)
+	input.RuntimeMin = app.readInt32(qs, "runtime_min", v)
+	input.RuntimeMax = app.readInt32(qs, "runtime_max", v)
+	input.CreatedAfter = app.readTime(qs, "created_after", v)
+	input.CreatedBefore = app.readTime(qs, "created_before", v)
 	// input.Page = app.readInt(qs, "page", 1)
 	// input.PageSize = app.readInt(qs, "page_size", 20)
 	input.Filters.Page = app.readInt(qs, "page", 1)
@@ -210,10 +232,31 @@ func (app *application) listMoviesHandler(w http.ResponseWriter, r *http.Request
 	// default:
 	// 	input.Filters.Sort = "id"
 	// }
+	input.Filters.Sort = app.readString(qs, "sort", "id")
+
+	input.Filters.SortSpec = data.MovieSort
+
+	data.ValidateMovieFilter(v, input.MovieFilter)
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
 
 	// fmt.Fprintf(w, "%+v\n", input)
 
-	movies, err := app.models.Movies.GetAll(input.Title, input.Genres, input.Filters)
+	movies, err := app.models.Movies.GetAll(r.Context(), input.MovieFilter, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.localizeMovies(w, r, movies...)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.attachImages(r.Context(), movies...)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 		return
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/shynggys9219/greenlight/internal/data"
+	"github.com/shynggys9219/greenlight/internal/testdb"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMovieHandlersWithMemstore(t *testing.T) {
+	ta, _ := newMemstoreTestApp(t)
+
+	var envelope struct {
+		Movie *data.Movie `json:"movie"`
+	}
+	res := ta.doRequest(http.MethodPost, "/v1/movies", nil, `{"title": "Moana", "year": 2016, "runtime": 107, "genres": ["animation"]}`, &envelope)
+	require.Equal(t, http.StatusCreated, res.StatusCode)
+	require.Equal(t, int32(1), envelope.Movie.Version)
+
+	// Reading a movie also reads its localized titles and images.
+	res = ta.doRequest(http.MethodGet, fmt.Sprintf("/v1/movies/%d", envelope.Movie.ID), nil, "", nil)
+	require.Equal(t, http.StatusOK, res.StatusCode)
+
+	var list struct {
+		Movies []*data.Movie `json:"movies"`
+	}
+	res = ta.doRequest(http.MethodGet, "/v1/movies?title=moana", nil, "", &list)
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	require.Len(t, list.Movies, 1)
+
+	movies, err := ta.models.Movies.GetAll(context.Background(), data.MovieFilter{Title: "moana", Genres: []string{"animation"}}, data.Filters{
+		Page:     1,
+		PageSize: 20,
+		Sort:     "-year",
+		SortSpec: data.MovieSort,
+	})
+	require.NoError(t, err)
+	require.Len(t, movies, 1)
+
+	for _, want := range []int{http.StatusOK, http.StatusNotFound} {
+		res := ta.doRequest(http.MethodDelete, fmt.Sprintf("/v1/movies/%d", envelope.Movie.ID), nil, "", nil)
+		require.Equal(t, want, res.StatusCode)
+	}
+}
+
+func TestUpdateMovieWithMemstore(t *testing.T) {
+	ta, _ := newMemstoreTestApp(t)
+
+	movie := testdb.Movie(t, ta.models)
+	stale := *movie
+
+	var envelope struct {
+		Movie *data.Movie `json:"movie"`
+	}
+	res := ta.doRequest(http.MethodPatch, fmt.Sprintf("/v1/movies/%d", movie.ID), nil, `{"title": "Renamed", "runtime": 95}`, &envelope)
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	require.Equal(t, "Renamed", envelope.Movie.Title)
+	require.Equal(t, int32(95), envelope.Movie.Runtime)
+	require.Equal(t, movie.Year, envelope.Movie.Year)
+	require.Equal(t, movie.Version+1, envelope.Movie.Version)
+
+	// A writer still holding the old version loses.
+	stale.Title = "Lost update"
+	err := ta.models.Movies.Update(context.Background(), &stale)
+	require.ErrorIs(t, err, data.ErrEditConflict)
+
+	stored, err := ta.models.Movies.Get(context.Background(), movie.ID)
+	require.NoError(t, err)
+	require.Equal(t, "Renamed", stored.Title)
+}
+
+func TestListMoviesValidation(t *testing.T) {
+	ta, _ := newMemstoreTestApp(t)
+
+	for _, query := range []string{
+		"?sort=version",
+		"?sort=year,-year",
+		"?genres_mode=some",
+		"?year_min=2000&year_max=1990",
+		"?runtime_min=-1",
+		"?year_min=abc",
+		"?year_max=1999.5",
+		"?runtime_max=99999999999",
+		"?created_after=yesterday",
+		"?page=0",
+		"?page_size=101",
+	} {
+		res := ta.doRequest(http.MethodGet, "/v1/movies"+query, nil, "", nil)
+		require.Equal(t, http.StatusUnprocessableEntity, res.StatusCode, query)
+	}
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"net/http"
+)
+
+// openAPISpec is the OpenAPI 3 description of every route registered in routes.go.
+// TestOpenAPICoversRoutes fails when a route is added without documenting it here.
+//
+//go:embed "openapi.json"
+var openAPISpec []byte
+
+// openAPIHandler serves the OpenAPI document for "GET /v1/openapi.json". The
+// document's info.version is the version of the running API.
+func (app *application) openAPIHandler(w http.ResponseWriter, r *http.Request) {
+	var spec map[string]any
+
+	err := json.Unmarshal(openAPISpec, &spec)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if info, ok := spec["info"].(map[string]any); ok {
+		info["version"] = version
+	}
+
+	err = app.writeJSON(w, http.StatusOK, spec, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenAPICoversRoutes(t *testing.T) {
+	app := &application{
+		logger: log.New(io.Discard, "", 0),
+	}
+	// Development also registers the debug routes, so they have to be documented too.
+	app.config.Env = "development"
+
+	var spec struct {
+		Paths map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	err := json.Unmarshal(openAPISpec, &spec)
+	require.NoError(t, err)
+
+	// httprouter's ":id" and "*key" are "{id}" and "{key}" in OpenAPI.
+	param := regexp.MustCompile(`[:*](\w+)`)
+
+	for _, route := range app.router().routes {
+		path := param.ReplaceAllString(route.path, "{$1}")
+		if _, ok := spec.Paths[path][strings.ToLower(route.method)]; !ok {
+			t.Errorf("%s %s is not described in openapi.json", route.method, path)
+		}
+	}
+
+	rr := httptest.NewRecorder()
+	app.openAPIHandler(rr, httptest.NewRequest(http.MethodGet, "/v1/openapi.json", nil))
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	var served struct {
+		Info struct {
+			Version string `json:"version"`
+		} `json:"info"`
+	}
+	err = json.NewDecoder(rr.Body).Decode(&served)
+	require.NoError(t, err)
+	require.Equal(t, version, served.Info.Version)
+}
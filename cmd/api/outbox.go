@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"time"
+
+	"github.com/shynggys9219/greenlight/internal/data"
+	"github.com/shynggys9219/greenlight/internal/validator"
+)
+
+// outboxLease is how long a claimed email stays reserved for one worker. It has to be
+// comfortably longer than an SMTP send (the dialer times out after 5 seconds).
+const outboxLease = time.Minute
+
+// startOutboxWorkers starts the configured number of outbox workers. They stop when
+// ctx is cancelled and are tracked by app.wg, so shutdown waits for in-flight sends.
+func (app *application) startOutboxWorkers(ctx context.Context) {
+	for i := 0; i < app.config.Outbox.Workers; i++ {
+		app.wg.Add(1)
+
+		go func() {
+			defer app.wg.Done()
+
+			ticker := time.NewTicker(app.config.Outbox.PollInterval)
+			defer ticker.Stop()
+
+			for {
+				// Keep draining while there is work, then wait for the next tick. A
+				// claimed batch is finished even during shutdown, so it doesn't get ctx.
+				if app.processOutbox(context.Background()) > 0 && ctx.Err() == nil {
+					continue
+				}
+
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+				}
+			}
+		}()
+	}
+}
+
+// processOutbox claims one batch of due emails, sends them and records the outcome.
+// It returns the number of emails it claimed.
+func (app *application) processOutbox(ctx context.Context) int {
+	defer func() {
+		if err := recover(); err != nil {
+			app.logger.Print(fmt.Errorf("outbox worker: %s", err))
+		}
+	}()
+
+	emails, err := app.models.Outbox.Claim(ctx, app.config.Outbox.BatchSize, outboxLease)
+	if err != nil {
+		app.logger.Print(fmt.Errorf("outbox worker: %w", err))
+		return 0
+	}
+
+	for _, email := range emails {
+		err = app.mailer.Send(email.Recipient, email.Locale, email.Template, email.Data)
+		if err != nil {
+			err = app.models.Outbox.MarkFailed(ctx, email, err, time.Now().Add(outboxBackoff(email.Attempts)))
+			if err == nil && email.Status == data.OutboxDead {
+				app.logger.Printf("outbox email %d to %s is dead after %d attempts: %s", email.ID, email.Recipient, email.Attempts, email.LastError)
+			}
+		} else {
+			err = app.models.Outbox.MarkSent(ctx, email)
+		}
+
+		if err != nil {
+			app.logger.Print(fmt.Errorf("outbox worker: email %d: %w", email.ID, err))
+		}
+	}
+
+	return len(emails)
+}
+
+// outboxBackoff returns the delay before the next attempt: 30s, 1m, 2m, 4m, ... capped
+// at 6 hours, with up to 20% jitter so failed emails don't retry in lockstep.
+func outboxBackoff(attempts int32) time.Duration {
+	delay := 30 * time.Second
+	for i := int32(1); i < attempts && delay < 6*time.Hour; i++ {
+		delay *= 2
+	}
+
+	if delay > 6*time.Hour {
+		delay = 6 * time.Hour
+	}
+
+	return delay + time.Duration(rand.Int63n(int64(delay/5)+1))
+}
+
+// listOutboxEmailsHandler lists queued emails for "GET /v1/admin/emails", e.g.
+// "?status=dead" to see everything that gave up.
+func (app *application) listOutboxEmailsHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Status string
+		data.Filters
+	}
+
+	qs := r.URL.Query()
+
+	input.Status = app.readString(qs, "status", "")
+	input.Filters.Page = app.readInt(qs, "page", 1)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20)
+	input.Filters.Sort = app.readString(qs, "sort", "id")
+
+	input.Filters.SortSpec = data.OutboxSort
+
+	v := validator.New()
+	data.ValidateOutboxStatus(v, input.Status)
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	emails, err := app.models.Outbox.GetAll(r.Context(), input.Status, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"emails": emails}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) showOutboxEmailHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	email, err := app.models.Outbox.Get(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"email": email}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// requeueOutboxEmailHandler resets a failed email for "POST /v1/admin/emails/:id/requeue"
+// so the workers pick it up again with a fresh set of attempts.
+func (app *application) requeueOutboxEmailHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	email, err := app.models.Outbox.Get(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	// A worker holds the lease on an email that is being sent; requeueing it would
+	// let a second worker send it too.
+	switch email.Status {
+	case data.OutboxSent:
+		v := validator.New()
+		v.AddError("status", "email has already been sent")
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	case data.OutboxSending:
+		v := validator.New()
+		v.AddError("status", "email is being sent")
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.Outbox.Requeue(r.Context(), email)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"email": email}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/shynggys9219/greenlight/internal/data"
+	"github.com/shynggys9219/greenlight/internal/testdb"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSimilarMovies(t *testing.T) {
+	ta := newTestApp(t)
+
+	movie := func(title string, year int32, genres ...string) *data.Movie {
+		return testdb.Movie(t, ta.models, func(movie *data.Movie) {
+			movie.Title = title
+			movie.Year = year
+			movie.Genres = genres
+		})
+	}
+
+	alien := movie("Alien", 1979, "horror", "sci-fi")
+	thing := movie("The Thing", 1982, "horror", "sci-fi")
+	bladeRunner := movie("Blade Runner", 1982, "sci-fi", "drama")
+	nottingHill := movie("Notting Hill", 1999, "romance")
+	movie("Amelie", 2001, "comedy")
+
+	director := &data.Person{Name: "Ridley Scott"}
+	require.NoError(t, ta.models.People.Insert(context.Background(), director))
+	for _, m := range []*data.Movie{alien, bladeRunner} {
+		require.NoError(t, ta.models.Credits.Insert(context.Background(), &data.Credit{MovieID: m.ID, PersonID: director.ID, Role: data.CreditDirector}))
+	}
+
+	do := func(method, path, token, body string, dst any) int {
+		var header http.Header
+		if token != "" {
+			header = bearer(token)
+		}
+
+		return ta.doRequest(method, path, header, body, dst).StatusCode
+	}
+
+	// Two users who like both Alien and Notting Hill make them similar.
+	for _, rating := range []int{9, 8} {
+		user := testdb.User(t, ta.models)
+		token := testdb.Token(t, ta.models, user, data.ScopeAuthentication)
+
+		for _, m := range []*data.Movie{alien, nottingHill} {
+			status := do(http.MethodPut, fmt.Sprintf("/v1/movies/%d/rating", m.ID), token, fmt.Sprintf(`{"rating": %d}`, rating), nil)
+			require.Equal(t, http.StatusOK, status)
+		}
+	}
+
+	require.Equal(t, http.StatusUnauthorized, do(http.MethodPut, fmt.Sprintf("/v1/movies/%d/rating", alien.ID), "", `{"rating": 5}`, nil))
+
+	var envelope struct {
+		Similar []*data.SimilarMovie `json:"similar"`
+	}
+
+	status := do(http.MethodGet, fmt.Sprintf("/v1/movies/%d/similar", alien.ID), "", "", &envelope)
+	require.Equal(t, http.StatusOK, status)
+
+	var ids []int64
+	for _, s := range envelope.Similar {
+		ids = append(ids, s.Movie.ID)
+		require.Nil(t, s.ComputedAt)
+	}
+	require.Equal(t, []int64{thing.ID, bladeRunner.ID, nottingHill.ID}, ids)
+
+	require.InDelta(t, 1, envelope.Similar[0].Breakdown.Genres.Similarity, 1e-9)
+	require.InDelta(t, 1, envelope.Similar[1].Breakdown.Directors.Similarity, 1e-9)
+	require.InDelta(t, 1, envelope.Similar[2].Breakdown.Ratings.Similarity, 1e-9)
+
+	run := ta.runJob(context.Background(), ta.job("refresh_movie_similarities"))
+	require.Empty(t, run.Error)
+	require.Equal(t, int64(5), run.Affected)
+
+	status = do(http.MethodGet, fmt.Sprintf("/v1/movies/%d/similar?limit=2", alien.ID), "", "", &envelope)
+	require.Equal(t, http.StatusOK, status)
+	require.Len(t, envelope.Similar, 2)
+	require.Equal(t, thing.ID, envelope.Similar[0].Movie.ID)
+	require.NotNil(t, envelope.Similar[0].ComputedAt)
+}
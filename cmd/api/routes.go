@@ -4,11 +4,34 @@ import (
 	"net/http"
 
 	"github.com/julienschmidt/httprouter"
+	"github.com/shynggys9219/greenlight/internal/data"
 )
 
-func (app *application) routes() *httprouter.Router {
+// routeTable wraps httprouter.Router and remembers every registered route, so tests
+// can check the OpenAPI document against what is actually served.
+type routeTable struct {
+	*httprouter.Router
+	routes []route
+}
+
+type route struct {
+	method string
+	path   string
+}
+
+func (t *routeTable) HandlerFunc(method, path string, handler http.HandlerFunc) {
+	t.routes = append(t.routes, route{method: method, path: path})
+	t.Router.HandlerFunc(method, path, handler)
+}
+
+func (app *application) routes() http.Handler {
+	// Return the router wrapped in the middleware chain.
+	return app.recoverPanic(app.requestID(app.authenticate(app.router())))
+}
+
+func (app *application) router() *routeTable {
 	// Initialize a new httprouter router instance.
-	router := httprouter.New()
+	router := &routeTable{Router: httprouter.New()}
 	router.NotFound = http.HandlerFunc(app.notFoundResponse)
 	router.MethodNotAllowed = http.HandlerFunc(app.methodNotAllowedResponse)
 	// Register the relevant methods, URL patterns and handler functions for our
@@ -16,6 +39,7 @@ func (app *application) routes() *httprouter.Router {
 	// http.MethodPost are constants which equate to the strings "GET" and "POST"
 	// respectively.
 	router.HandlerFunc(http.MethodGet, "/v1/healthcheck", app.healthcheckHandler)
+	router.HandlerFunc(http.MethodGet, "/v1/openapi.json", app.openAPIHandler)
 
 	router.HandlerFunc(http.MethodGet, "/v1/movies", app.listMoviesHandler)
 	router.HandlerFunc(http.MethodPost, "/v1/movies", app.createMovieHandler)
@@ -25,9 +49,73 @@ func (app *application) routes() *httprouter.Router {
 	router.HandlerFunc(http.MethodDelete, "/v1/movies/:id", app.deleteMovieHandler)
 	router.HandlerFunc(http.MethodGet, "/v1/directors/:direc_name", app.searchByName)
 
+	router.HandlerFunc(http.MethodGet, "/v1/movies/:id/credits", app.listMovieCreditsHandler)
+	router.HandlerFunc(http.MethodPost, "/v1/movies/:id/credits", app.requireRole(data.RoleAdmin, app.createMovieCreditHandler))
+	router.HandlerFunc(http.MethodPatch, "/v1/movies/:id/credits/:credit_id", app.requireRole(data.RoleAdmin, app.updateMovieCreditHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/movies/:id/credits/:credit_id", app.requireRole(data.RoleAdmin, app.deleteMovieCreditHandler))
+
+	router.HandlerFunc(http.MethodGet, "/v1/movies/:id/similar", app.similarMoviesHandler)
+	router.HandlerFunc(http.MethodPut, "/v1/movies/:id/rating", app.requireActivatedUser(app.putMovieRatingHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/movies/:id/rating", app.requireActivatedUser(app.deleteMovieRatingHandler))
+
+	router.HandlerFunc(http.MethodGet, "/v1/movies/:id/titles", app.listMovieTitlesHandler)
+	router.HandlerFunc(http.MethodPut, "/v1/movies/:id/titles/:lang", app.requireRole(data.RoleAdmin, app.putMovieTitleHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/movies/:id/titles/:lang", app.requireRole(data.RoleAdmin, app.deleteMovieTitleHandler))
+
+	router.HandlerFunc(http.MethodPost, "/v1/movies/:id/images", app.requireRole(data.RoleAdmin, app.uploadMovieImageHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/movies/:id/images/:image_id", app.requireRole(data.RoleAdmin, app.deleteMovieImageHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/images/*key", app.serveImageHandler)
+
+	router.HandlerFunc(http.MethodGet, "/v1/people", app.listPeopleHandler)
+	router.HandlerFunc(http.MethodPost, "/v1/people", app.requireRole(data.RoleAdmin, app.createPersonHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/people/:id", app.showPersonHandler)
+	router.HandlerFunc(http.MethodPatch, "/v1/people/:id", app.requireRole(data.RoleAdmin, app.updatePersonHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/people/:id", app.requireRole(data.RoleAdmin, app.deletePersonHandler))
+
 	router.HandlerFunc(http.MethodPost, "/v1/users", app.registerUserHandler)
-	router.HandlerFunc(http.MethodPost, "/v1/roles", app.createRoleHandler)
-	// router.HandlerFunc(http.MethodPut, "/v1/users/activated", app.activateUserHandler)
-	// Return the httprouter instance.
+	router.HandlerFunc(http.MethodGet, "/v1/users/me", app.requireAuthenticatedUser(app.showCurrentUserHandler))
+	router.HandlerFunc(http.MethodPatch, "/v1/users/me", app.requireSession(app.updateCurrentUserHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/users/me", app.requireSession(app.deleteCurrentUserHandler))
+	router.HandlerFunc(http.MethodPut, "/v1/users/me/password", app.requireSession(app.updateCurrentUserPasswordHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/users/me/totp", app.requireSession(app.enrollTOTPHandler))
+	router.HandlerFunc(http.MethodPut, "/v1/users/me/totp", app.requireSession(app.confirmTOTPHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/users/me/totp", app.requireSession(app.disableTOTPHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/users/me/sessions", app.requireSession(app.listSessionsHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/users/me/sessions/:id", app.requireSession(app.deleteSessionHandler))
+	router.HandlerFunc(http.MethodPut, "/v1/users/activated", app.activateUserHandler)
+	router.HandlerFunc(http.MethodPut, "/v1/users/email", app.confirmEmailChangeHandler)
+	router.HandlerFunc(http.MethodPut, "/v1/users/unlocked", app.unlockAccountHandler)
+
+	router.HandlerFunc(http.MethodGet, "/v1/api-keys", app.requireSession(app.listAPIKeysHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/api-keys", app.requireSession(app.createAPIKeyHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/api-keys/:id", app.requireSession(app.deleteAPIKeyHandler))
+
+	router.HandlerFunc(http.MethodPost, "/v1/roles", app.requireRole(data.RoleAdmin, app.createRoleHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/tokens/authentication", app.createAuthenticationTokenHandler)
+	router.HandlerFunc(http.MethodPost, "/v1/tokens/mfa", app.createMFAAuthenticationTokenHandler)
+	router.HandlerFunc(http.MethodPost, "/v1/tokens/refresh", app.refreshAuthenticationTokenHandler)
+	router.HandlerFunc(http.MethodGet, "/.well-known/jwks.json", app.jwksHandler)
+
+	router.HandlerFunc(http.MethodGet, "/v1/admin/emails", app.requireRole(data.RoleAdmin, app.listOutboxEmailsHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/admin/emails/:id", app.requireRole(data.RoleAdmin, app.showOutboxEmailHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/admin/emails/:id/requeue", app.requireRole(data.RoleAdmin, app.requeueOutboxEmailHandler))
+
+	router.HandlerFunc(http.MethodGet, "/v1/admin/jobs/runs", app.requireRole(data.RoleAdmin, app.listJobRunsHandler))
+
+	router.HandlerFunc(http.MethodGet, "/v1/admin/audit", app.requireRole(data.RoleAdmin, app.listAuditEventsHandler))
+
+	router.HandlerFunc(http.MethodGet, "/v1/admin/users", app.requireRole(data.RoleAdmin, app.listUsersHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/admin/users/:id", app.requireRole(data.RoleAdmin, app.showUserHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/admin/users/:id", app.requireRole(data.RoleAdmin, app.deleteUserHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/admin/users/:id/activate", app.requireRole(data.RoleAdmin, app.forceActivateUserHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/admin/users/:id/lock", app.requireRole(data.RoleAdmin, app.lockUserHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/admin/users/:id/unlock", app.requireRole(data.RoleAdmin, app.unlockUserHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/admin/users/:id/lockout", app.requireRole(data.RoleAdmin, app.clearUserLockoutHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/admin/ips/:ip/lockout", app.requireRole(data.RoleAdmin, app.clearIPLockoutHandler))
+	// Development helpers, never exposed in staging or production.
+	if app.config.Env == "development" {
+		router.HandlerFunc(http.MethodGet, "/debug/mail/preview/:template", app.previewMailHandler)
+	}
+
 	return router
 }
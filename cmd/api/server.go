@@ -1,71 +1,76 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 )
 
+// serve starts the background workers and the HTTP server, and shuts both down
+// gracefully on SIGINT or SIGTERM.
 func (app *application) serve() error {
-	// srv := &http.Server{
-	// 	Addr:         fmt.Sprintf(":%d", app.config.port),
-	// 	Handler:      app.routes(),
-	// 	IdleTimeout:  time.Minute,
-	// 	ReadTimeout:  10 * time.Second,
-	// 	WriteTimeout: 30 * time.Second,
-	// }
-	// shutdownError := make(chan error)
-	// go func() {
-	// 	quit := make(chan os.Signal, 1)
-	// 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-	// 	s := <-quit
-	// 	app.logger.Print("caught signal", map[string]string{
-	// 		"signal": s.String(),
-	// 	})
-	// 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	// 	defer cancel()
-	// 	// Call Shutdown() on the server like before, but now we only send on the
-	// 	// shutdownError channel if it returns an error.
-	// 	err := srv.Shutdown(ctx)
-	// 	if err != nil {
-	// 		shutdownError <- err
-	// 	}
-	// 	// Log a message to say that we're waiting for any background goroutines to
-	// 	// complete their tasks.
-	// 	app.logger.Print("completing background tasks", map[string]string{
-	// 		"addr": srv.Addr,
-	// 	})
-	// 	// Call Wait() to block until our WaitGroup counter is zero --- essentially
-	// 	// blocking until the background goroutines have finished. Then we return nil on
-	// 	// the shutdownError channel, to indicate that the shutdown completed without
-	// 	// any issues.
-	// 	app.wg.Wait()
-	// 	shutdownError <- nil
-	// }()
-	// app.logger.Print("starting server", map[string]string{
-	// 	"addr": srv.Addr,
-	// 	"env":  app.config.env,
-	// })
-	// err := srv.ListenAndServe()
-	// if !errors.Is(err, http.ErrServerClosed) {
-	// 	return err
-	// }
-	// err = <-shutdownError
-	// if err != nil {
-	// 	return err
-	// }
-	// app.logger.Print("stopped server", map[string]string{
-	// 	"addr": srv.Addr,
-	// })
-	// return nil
-
 	srv := &http.Server{
-		Addr:    fmt.Sprintf(":%d", app.config.port),
-		Handler: app.routes(),
+		Addr:         fmt.Sprintf(":%d", app.config.Port),
+		Handler:      app.routes(),
+		IdleTimeout:  time.Minute,
+		ReadTimeout:  10 * time.Second,
+		WriteTimeout: 30 * time.Second,
 	}
-	// Start the server.
+
+	// Cancelling workersCtx tells every background worker to finish what it is doing
+	// and return.
+	workersCtx, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+
+	app.startOutboxWorkers(workersCtx)
+	app.startScheduler(workersCtx)
+
+	shutdownError := make(chan error)
+
+	go func() {
+		quit := make(chan os.Signal, 1)
+		signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+		s := <-quit
+
+		app.logger.Printf("caught signal %s", s)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		// Shutdown() stops accepting new connections and waits for in-flight requests.
+		err := srv.Shutdown(ctx)
+		if err != nil {
+			shutdownError <- err
+			return
+		}
+
+		// Log a message to say that we're waiting for any background goroutines to
+		// complete their tasks. Wait() blocks until the WaitGroup counter is zero.
+		app.logger.Printf("completing background tasks on %s", srv.Addr)
+
+		stopWorkers()
+		app.wg.Wait()
+		shutdownError <- nil
+	}()
+
+	app.logger.Printf("starting %s server on %s", app.config.Env, srv.Addr)
+
 	err := srv.ListenAndServe()
-	if err != nil && err != http.ErrServerClosed {
+	if !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	err = <-shutdownError
+	if err != nil {
 		return err
 	}
+
+	app.logger.Printf("stopped server on %s", srv.Addr)
+
 	return nil
 }
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/shynggys9219/greenlight/internal/data"
+	"github.com/shynggys9219/greenlight/internal/data/memstore"
+	"github.com/shynggys9219/greenlight/internal/mailer"
+	"github.com/shynggys9219/greenlight/internal/testdb"
+	"github.com/stretchr/testify/require"
+)
+
+// testApp is an application whose routes are served by a test server, and whose
+// emails are kept in memory.
+type testApp struct {
+	*application
+	t      *testing.T
+	db     *sql.DB // nil on the in-memory store
+	server *httptest.Server
+	emails *mailer.Memory
+}
+
+// newTestApp returns a testApp on a database of its own, or skips the test when
+// there is no test database. Options change the application before its routes are
+// built.
+func newTestApp(t *testing.T, options ...func(*application)) *testApp {
+	t.Helper()
+
+	db := testdb.Open(t)
+
+	ta := startTestApp(t, data.NewModels(db), options...)
+	ta.db = db
+
+	return ta
+}
+
+// newMemstoreTestApp returns a testApp on the in-memory store, for tests that don't
+// need PostgreSQL.
+func newMemstoreTestApp(t *testing.T, options ...func(*application)) (*testApp, *memstore.Store) {
+	t.Helper()
+
+	store := memstore.New()
+
+	return startTestApp(t, store.Models(), options...), store
+}
+
+func startTestApp(t *testing.T, models data.Models, options ...func(*application)) *testApp {
+	t.Helper()
+
+	Start()
+
+	emails := mailer.NewMemory()
+
+	app := &application{
+		config: cfg,
+		logger: log.New(io.Discard, "", 0),
+		models: models,
+		mailer: mailer.New(emails, cfg.SMTP.Sender),
+	}
+	app.decodes = make(chan struct{}, app.config.Uploads.MaxDecodes)
+	app.config.Outbox.BatchSize = 10
+	app.config.Login.IPMaxFailures = 1000
+	app.config.Login.Delay = 0
+
+	for _, option := range options {
+		option(app)
+	}
+
+	app.users = newUserCache(app.config.Auth.JWT.UserCacheTTL)
+
+	server := httptest.NewServer(app.routes())
+	t.Cleanup(server.Close)
+
+	return &testApp{application: app, t: t, server: server, emails: emails}
+}
+
+// bearer returns the Authorization header for a bearer token.
+func bearer(token string) http.Header {
+	return http.Header{"Authorization": {"Bearer " + token}}
+}
+
+// doRequest sends a request with body, if any, and header to the test server. The
+// JSON response is decoded into dst unless it is nil, and the response is returned
+// with its body closed.
+func (ta *testApp) doRequest(method, path string, header http.Header, body string, dst any) *http.Response {
+	ta.t.Helper()
+
+	req, err := http.NewRequest(method, ta.server.URL+path, strings.NewReader(body))
+	require.NoError(ta.t, err)
+
+	for key, values := range header {
+		req.Header[key] = values
+	}
+
+	res, err := http.DefaultClient.Do(req)
+	require.NoError(ta.t, err)
+	defer res.Body.Close()
+
+	if dst != nil {
+		require.NoError(ta.t, json.NewDecoder(res.Body).Decode(dst))
+	}
+
+	return res
+}
+
+// newUser inserts an activated user, see testdb.User, and returns it with an
+// authentication token.
+func (ta *testApp) newUser(options ...func(*data.User)) (*data.User, string) {
+	ta.t.Helper()
+
+	user := testdb.User(ta.t, ta.models, options...)
+
+	return user, testdb.Token(ta.t, ta.models, user, data.ScopeAuthentication)
+}
+
+// newAdmin inserts a user with the admin role and returns it with an authentication
+// token.
+func (ta *testApp) newAdmin() (*data.User, string) {
+	ta.t.Helper()
+
+	admin, token := ta.newUser()
+	require.NoError(ta.t, ta.models.Role.InsertUserRole(context.Background(), &data.Role{RoleName: data.RoleAdmin, UserID: admin.ID}))
+
+	return admin, token
+}
+
+// deliverEmails sends the queued emails the way the outbox workers do and returns
+// everything sent so far.
+func (ta *testApp) deliverEmails() []mailer.Email {
+	for ta.processOutbox(context.Background()) > 0 {
+	}
+
+	return ta.emails.Emails()
+}
+
+var emailTokenRX = regexp.MustCompile(`"token": "(\w+)"`)
+
+// emailToken returns the token in the last email of the template sent to the address.
+func (ta *testApp) emailToken(to, template string) string {
+	ta.t.Helper()
+
+	var token string
+	for _, e := range ta.deliverEmails() {
+		if e.To == to && e.Template == template {
+			if match := emailTokenRX.FindStringSubmatch(e.PlainBody); match != nil {
+				token = match[1]
+			}
+		}
+	}
+	require.NotEmpty(ta.t, token, "no %s email with a token was sent to %s", template, to)
+
+	return token
+}
+
+// job returns the scheduled job with the name.
+func (ta *testApp) job(name string) job {
+	ta.t.Helper()
+
+	for _, j := range ta.jobs() {
+		if j.name == name {
+			return j
+		}
+	}
+
+	ta.t.Fatalf("no job named %s", name)
+	return job{}
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/shynggys9219/greenlight/internal/data"
+	"github.com/shynggys9219/greenlight/internal/jwt"
+	"github.com/shynggys9219/greenlight/internal/validator"
+)
+
+// createAuthenticationTokenHandler exchanges an email and password for a bearer token
+// for "POST /v1/tokens/authentication".
+func (app *application) createAuthenticationTokenHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Email    string `json:"email"`
+		Password string `json:"password"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	data.ValidateEmail(v, input.Email)
+	data.ValidatePasswordPlaintext(v, input.Password)
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	// Refuse early, before spending a bcrypt comparison, while this client or this
+	// account has to wait after earlier failures.
+	ip := clientIP(r)
+
+	wait, err := app.loginWait(r.Context(), input.Email, ip)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	if wait > 0 {
+		app.tooManyLoginAttemptsResponse(w, r, wait)
+		return
+	}
+
+	user, err := app.models.User.GetByEmain(r.Context(), input.Email)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			data.SimulatePasswordCheck(input.Password)
+			app.loginFailed(w, r, nil, input.Email, ip)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	match, err := user.Password.Matches(input.Password)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if !match {
+		app.loginFailed(w, r, user, input.Email, ip)
+		return
+	}
+
+	// Whether the account is locked is only told to someone who knows the password.
+	// Anyone else gets the same answer as for an email without an account.
+	if user.IsLockedOut() {
+		app.lockedOutResponse(w, r, time.Until(*user.LockedUntil))
+		return
+	}
+
+	if user.Locked {
+		app.lockedAccountResponse(w, r)
+		return
+	}
+
+	// With two-factor authentication the password only earns a short-lived token for
+	// POST /v1/tokens/mfa. The login counts as successful once the code was checked
+	// too, so the failure count keeps limiting guesses at the code.
+	credential, err := app.models.TOTP.Get(r.Context(), user.ID)
+	if err != nil && !errors.Is(err, data.ErrRecordNotFound) {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if credential != nil && credential.Enabled {
+		token, err := app.models.Token.New(r.Context(), user.ID, 5*time.Minute, data.ScopeMFAPending)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		err = app.writeJSON(w, http.StatusAccepted, envelope{"mfa_token": token}, nil)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.loginSucceeded(w, r, user, ip)
+}
+
+// loginSucceeded records the successful login and starts a session for the client.
+func (app *application) loginSucceeded(w http.ResponseWriter, r *http.Request, user *data.User, ip string) {
+	err := app.models.Logins.Insert(r.Context(), &data.LoginAttempt{Email: user.Email, IP: ip, Succeeded: true})
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.startSession(w, r, http.StatusCreated, user)
+}
+
+// startSession starts a session for the client that made the request and responds
+// with its first authentication and refresh token.
+func (app *application) startSession(w http.ResponseWriter, r *http.Request, status int, user *data.User) {
+	session := &data.Session{
+		UserID:    user.ID,
+		UserAgent: r.UserAgent(),
+		IP:        clientIP(r),
+	}
+
+	access, refresh, err := app.models.Sessions.New(r.Context(), session, app.storedAccessTTL(), app.config.Session.RefreshTTL)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	access, err = app.accessToken(session, access)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, status, envelope{"authentication_token": access, "refresh_token": refresh}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// refreshAuthenticationTokenHandler handles "POST /v1/tokens/refresh". Every refresh
+// token works once and is replaced by a new one. Using one a second time means it was
+// stolen, and the whole session is revoked.
+func (app *application) refreshAuthenticationTokenHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	if data.ValidateTokenPlaintext(v, input.RefreshToken); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	cfg := app.config.Session
+
+	session, access, refresh, err := app.models.Sessions.Refresh(r.Context(), input.RefreshToken, r.UserAgent(), clientIP(r), app.storedAccessTTL(), cfg.RefreshTTL)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrTokenReused):
+			app.logger.Printf("refresh token reused from %s, session revoked", clientIP(r))
+			app.invalidAuthenticationTokenResponse(w, r)
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.invalidAuthenticationTokenResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	user, err := app.models.User.Get(r.Context(), session.UserID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if user.Locked {
+		app.lockedAccountResponse(w, r)
+		return
+	}
+
+	access, err = app.accessToken(session, access)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusCreated, envelope{"authentication_token": access, "refresh_token": refresh}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// storedAccessTTL is the lifetime of the access tokens stored with a session. In
+// stateless mode none are stored; see accessToken.
+func (app *application) storedAccessTTL() time.Duration {
+	if app.config.Auth.Mode == "stateless" {
+		return 0
+	}
+	return app.config.Session.AccessTTL
+}
+
+// accessToken returns the access token to hand out for session: the stored one in
+// stateful mode, a signed JWT in stateless mode. Signed tokens are checked without
+// the tokens table, so revoking their session only takes effect when they expire.
+func (app *application) accessToken(session *data.Session, stored *data.Token) (*data.Token, error) {
+	if app.config.Auth.Mode != "stateless" {
+		return stored, nil
+	}
+
+	plaintext, expiry, err := app.signer.Sign(strconv.FormatInt(session.UserID, 10), session.ID, app.config.Session.AccessTTL)
+	if err != nil {
+		return nil, err
+	}
+
+	token := &data.Token{
+		Plaintext: plaintext,
+		UserID:    session.UserID,
+		Expiry:    expiry,
+		Scope:     data.ScopeAuthentication,
+	}
+
+	return token, nil
+}
+
+// jwksHandler publishes the public keys of signed tokens for "GET /.well-known/jwks.json",
+// so other services can verify them. Without signing keys the set is empty.
+func (app *application) jwksHandler(w http.ResponseWriter, r *http.Request) {
+	keys := []jwt.JWK{}
+	if app.signer != nil {
+		keys = app.signer.JWKS()
+	}
+
+	err := app.writeJSON(w, http.StatusOK, envelope{"keys": keys}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
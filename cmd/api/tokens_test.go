@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shynggys9219/greenlight/internal/data"
+	"github.com/shynggys9219/greenlight/internal/jwt"
+	"github.com/shynggys9219/greenlight/internal/testdb"
+	"github.com/stretchr/testify/require"
+)
+
+// loginResponse is the body of a successful "POST /v1/tokens/authentication".
+type loginResponse struct {
+	Access struct {
+		Token string `json:"token"`
+	} `json:"authentication_token"`
+	Refresh struct {
+		Token string `json:"token"`
+	} `json:"refresh_token"`
+}
+
+func (ta *testApp) login(email string) loginResponse {
+	ta.t.Helper()
+
+	var tokens loginResponse
+	body := fmt.Sprintf(`{"email": %q, "password": %q}`, email, testdb.FixturePassword)
+	res := ta.doRequest(http.MethodPost, "/v1/tokens/authentication", nil, body, &tokens)
+	require.Equal(ta.t, http.StatusCreated, res.StatusCode)
+
+	return tokens
+}
+
+func TestRefreshTokenReuse(t *testing.T) {
+	ta := newTestApp(t)
+
+	user := testdb.User(t, ta.models)
+
+	login := ta.login(user.Email)
+
+	var sessions struct {
+		Sessions []struct {
+			ID      int64 `json:"id"`
+			Current bool  `json:"current"`
+		} `json:"sessions"`
+	}
+	res := ta.doRequest(http.MethodGet, "/v1/users/me/sessions", bearer(login.Access.Token), "", &sessions)
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	require.Len(t, sessions.Sessions, 1)
+	require.True(t, sessions.Sessions[0].Current)
+
+	refresh := func(token string, dst any) int {
+		return ta.doRequest(http.MethodPost, "/v1/tokens/refresh", nil, fmt.Sprintf(`{"refresh_token": %q}`, token), dst).StatusCode
+	}
+
+	var refreshed loginResponse
+	require.Equal(t, http.StatusCreated, refresh(login.Refresh.Token, &refreshed))
+	require.Equal(t, http.StatusOK, ta.doRequest(http.MethodGet, "/v1/users/me", bearer(refreshed.Access.Token), "", nil).StatusCode)
+
+	// Replaying the first refresh token revokes the session, including the tokens
+	// the legitimate refresh produced.
+	require.Equal(t, http.StatusUnauthorized, refresh(login.Refresh.Token, nil))
+	require.Equal(t, http.StatusUnauthorized, ta.doRequest(http.MethodGet, "/v1/users/me", bearer(refreshed.Access.Token), "", nil).StatusCode)
+
+	require.Equal(t, http.StatusUnauthorized, refresh(refreshed.Refresh.Token, nil))
+}
+
+func TestSessionsWithMemstore(t *testing.T) {
+	ta, _ := newMemstoreTestApp(t)
+
+	user := testdb.User(t, ta.models)
+
+	login := ta.login(user.Email)
+
+	res := ta.doRequest(http.MethodGet, "/v1/users/me", bearer(login.Access.Token), "", nil)
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	res = ta.doRequest(http.MethodGet, "/v1/users/me/sessions", bearer(login.Access.Token), "", nil)
+	require.Equal(t, http.StatusOK, res.StatusCode)
+
+	var refreshed loginResponse
+	res = ta.doRequest(http.MethodPost, "/v1/tokens/refresh", nil, fmt.Sprintf(`{"refresh_token": %q}`, login.Refresh.Token), &refreshed)
+	require.Equal(t, http.StatusCreated, res.StatusCode)
+
+	res = ta.doRequest(http.MethodPost, "/v1/tokens/refresh", nil, fmt.Sprintf(`{"refresh_token": %q}`, login.Refresh.Token), nil)
+	require.Equal(t, http.StatusUnauthorized, res.StatusCode)
+	res = ta.doRequest(http.MethodGet, "/v1/users/me", bearer(refreshed.Access.Token), "", nil)
+	require.Equal(t, http.StatusUnauthorized, res.StatusCode)
+}
+
+func TestStatelessAuthentication(t *testing.T) {
+	keys, err := jwt.ParseKeys("k1:" + base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{7}, 32)))
+	require.NoError(t, err)
+	signer, err := jwt.New("greenlight", "greenlight", keys)
+	require.NoError(t, err)
+
+	ta, _ := newMemstoreTestApp(t, func(app *application) {
+		app.signer = signer
+		app.config.Auth.Mode = "stateless"
+	})
+
+	user := testdb.User(t, ta.models)
+
+	login := ta.login(user.Email)
+	require.Equal(t, 2, strings.Count(login.Access.Token, "."))
+
+	claims, err := signer.Verify(login.Access.Token)
+	require.NoError(t, err)
+	require.Equal(t, fmt.Sprint(user.ID), claims.Subject)
+
+	require.Equal(t, http.StatusOK, ta.doRequest(http.MethodGet, "/v1/users/me", bearer(login.Access.Token), "", nil).StatusCode)
+
+	// A token signed with a key the server doesn't know is rejected.
+	otherKeys, err := jwt.ParseKeys("k1:" + base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{8}, 32)))
+	require.NoError(t, err)
+	other, err := jwt.New("greenlight", "greenlight", otherKeys)
+	require.NoError(t, err)
+	forged, _, err := other.Sign(fmt.Sprint(user.ID), claims.SessionID, time.Minute)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusUnauthorized, ta.doRequest(http.MethodGet, "/v1/users/me", bearer(forged), "", nil).StatusCode)
+
+	// Stored tokens are only accepted in stateful mode, signed ones only in stateless.
+	stored := testdb.Token(t, ta.models, user, data.ScopeAuthentication)
+	require.Equal(t, http.StatusUnauthorized, ta.doRequest(http.MethodGet, "/v1/users/me", bearer(stored), "", nil).StatusCode)
+
+	stateful, _ := newMemstoreTestApp(t, func(app *application) {
+		app.signer = signer
+	})
+	carol := testdb.User(t, stateful.models)
+	signed, _, err := signer.Sign(fmt.Sprint(carol.ID), 0, time.Minute)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusUnauthorized, stateful.doRequest(http.MethodGet, "/v1/users/me", bearer(signed), "", nil).StatusCode)
+
+	// Reads reuse the cached user, but never after the account changed on this instance.
+	for _, name := range []string{"Alice", "Alicia"} {
+		res := ta.doRequest(http.MethodPatch, "/v1/users/me", bearer(login.Access.Token), fmt.Sprintf(`{"name": %q}`, name), nil)
+		require.Equal(t, http.StatusOK, res.StatusCode)
+
+		var me struct {
+			User data.User `json:"user"`
+		}
+		require.Equal(t, http.StatusOK, ta.doRequest(http.MethodGet, "/v1/users/me", bearer(login.Access.Token), "", &me).StatusCode)
+		require.Equal(t, name, me.User.Name)
+	}
+
+	admin := testdb.User(t, ta.models)
+	require.NoError(t, ta.models.Role.InsertUserRole(context.Background(), &data.Role{RoleName: data.RoleAdmin, UserID: admin.ID}))
+	res := ta.doRequest(http.MethodPost, fmt.Sprintf("/v1/admin/users/%d/lock", user.ID), bearer(ta.login(admin.Email).Access.Token), "", nil)
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	require.Equal(t, http.StatusForbidden, ta.doRequest(http.MethodGet, "/v1/users/me", bearer(login.Access.Token), "", nil).StatusCode)
+
+	var jwks struct {
+		Keys []jwt.JWK `json:"keys"`
+	}
+	require.Equal(t, http.StatusOK, ta.doRequest(http.MethodGet, "/.well-known/jwks.json", nil, "", &jwks).StatusCode)
+	require.Equal(t, signer.JWKS(), jwks.Keys)
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shynggys9219/greenlight/internal/testdb"
+	"github.com/stretchr/testify/require"
+)
+
+// totpNow computes the current code for a base32 secret the way an authenticator app
+// does.
+func totpNow(t *testing.T, secret string) string {
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(secret)
+	require.NoError(t, err)
+
+	var counter [8]byte
+	binary.BigEndian.PutUint64(counter[:], uint64(time.Now().Unix()/30))
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(counter[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	return fmt.Sprintf("%06d", (binary.BigEndian.Uint32(sum[offset:offset+4])&0x7fffffff)%1000000)
+}
+
+func TestTOTPLogin(t *testing.T) {
+	ta := newTestApp(t)
+
+	user, token := ta.newUser()
+
+	var enrollment struct {
+		TOTP struct {
+			Secret string `json:"secret"`
+			URI    string `json:"uri"`
+		} `json:"totp"`
+	}
+	res := ta.doRequest(http.MethodPost, "/v1/users/me/totp", bearer(token), "", &enrollment)
+	require.Equal(t, http.StatusCreated, res.StatusCode)
+	require.True(t, strings.HasPrefix(enrollment.TOTP.URI, "otpauth://totp/"))
+
+	var confirmation struct {
+		RecoveryCodes []string `json:"recovery_codes"`
+	}
+	res = ta.doRequest(http.MethodPut, "/v1/users/me/totp", bearer(token), fmt.Sprintf(`{"code": %q}`, totpNow(t, enrollment.TOTP.Secret)), &confirmation)
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	require.NotEmpty(t, confirmation.RecoveryCodes)
+
+	login := func() string {
+		var pending struct {
+			MFAToken struct {
+				Token string `json:"token"`
+			} `json:"mfa_token"`
+		}
+		body := fmt.Sprintf(`{"email": %q, "password": %q}`, user.Email, testdb.FixturePassword)
+		res := ta.doRequest(http.MethodPost, "/v1/tokens/authentication", nil, body, &pending)
+		require.Equal(t, http.StatusAccepted, res.StatusCode)
+
+		return pending.MFAToken.Token
+	}
+
+	// The mfa_token is no bearer token.
+	mfaToken := login()
+	res = ta.doRequest(http.MethodGet, "/v1/users/me", bearer(mfaToken), "", nil)
+	require.Equal(t, http.StatusUnauthorized, res.StatusCode)
+
+	recovery := fmt.Sprintf(`{"token": %q, "recovery_code": %q}`, mfaToken, confirmation.RecoveryCodes[0])
+	res = ta.doRequest(http.MethodPost, "/v1/tokens/mfa", nil, recovery, nil)
+	require.Equal(t, http.StatusCreated, res.StatusCode)
+
+	// Recovery codes only work once.
+	recovery = fmt.Sprintf(`{"token": %q, "recovery_code": %q}`, login(), confirmation.RecoveryCodes[0])
+	res = ta.doRequest(http.MethodPost, "/v1/tokens/mfa", nil, recovery, nil)
+	require.Equal(t, http.StatusUnauthorized, res.StatusCode)
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/shynggys9219/greenlight/internal/data"
+)
+
+// userCache keeps the users of signed access tokens for -jwt-user-cache-ttl, so read
+// requests in stateless mode don't load the user from the database every time. The
+// cache is per instance: a change made on another instance, like locking the account,
+// reaches reads here once the entry expires.
+type userCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	users   map[int64]cachedUser
+	sweepAt int // size at which expired entries are removed
+}
+
+type cachedUser struct {
+	user    data.User
+	expires time.Time
+}
+
+func newUserCache(ttl time.Duration) *userCache {
+	return &userCache{
+		ttl:     ttl,
+		users:   make(map[int64]cachedUser),
+		sweepAt: 64,
+	}
+}
+
+// get returns a copy of the cached user, which the handler is free to change.
+func (c *userCache) get(id int64) (*data.User, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	cached, ok := c.users[id]
+	if !ok || time.Now().After(cached.expires) {
+		return nil, false
+	}
+
+	user := cached.user
+	return &user, true
+}
+
+func (c *userCache) put(user *data.User) {
+	if c.ttl <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+
+	if len(c.users) >= c.sweepAt {
+		for id, cached := range c.users {
+			if now.After(cached.expires) {
+				delete(c.users, id)
+			}
+		}
+		c.sweepAt = 2 * len(c.users)
+		if c.sweepAt < 64 {
+			c.sweepAt = 64
+		}
+	}
+
+	c.users[user.ID] = cachedUser{user: *user, expires: now.Add(c.ttl)}
+}
+
+// forget drops the user after a change, so the next request loads it again.
+func (c *userCache) forget(id int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.users, id)
+}
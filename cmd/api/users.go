@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/shynggys9219/greenlight/internal/data"
+	"github.com/shynggys9219/greenlight/internal/mailer"
 	"github.com/shynggys9219/greenlight/internal/validator"
 )
 
@@ -15,17 +16,28 @@ func (app *application) registerUserHandler(w http.ResponseWriter, r *http.Reque
 		Name     string `json:"name"`
 		Email    string `json:"email"`
 		Password string `json:"password"`
+		Locale   string `json:"locale"`
 	}
 
 	err := app.readJSON(w, r, &input)
 	if err != nil {
+		app.badRequestResponse(w, r, err)
 		return
 	}
 
+	// Without an explicit locale, emails go out in the language the client prefers.
+	if input.Locale == "" {
+		input.Locale = mailer.DefaultLocale
+		if languages := app.requestLanguages(r); len(languages) > 0 {
+			input.Locale = languages[0]
+		}
+	}
+
 	user := &data.User{
 		Name:      input.Name,
 		Email:     input.Email,
 		Activated: false,
+		Locale:    input.Locale,
 	}
 
 	err = user.Password.Set(input.Password)
@@ -37,44 +49,25 @@ func (app *application) registerUserHandler(w http.ResponseWriter, r *http.Reque
 	v := validator.New()
 
 	if data.ValidateUser(v, user); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
 		return
 	}
 
-	err = app.models.User.Insert(user)
+	// The user, the activation token and the welcome email are written in one
+	// transaction. The email itself is delivered later by the outbox workers, so a
+	// mail server outage can't fail the registration.
+	_, err = app.audited(r).User.InsertWithActivation(r.Context(), user, 3*24*time.Hour, "user_welcome")
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrDuplicateEmail):
 			v.AddError("email", "a user with this email addres already exists")
+			app.failedValidationResponse(w, r, v.Errors)
 		default:
 			app.serverErrorResponse(w, r, err)
 		}
 		return
 	}
 
-	token, err := app.models.Token.New(user.ID, 3*24*time.Hour, data.ScopeActivation)
-	if err != nil {
-		app.serverErrorResponse(w, r, err)
-		return
-	}
-
-	err = app.mailer.Send(user.Email, "user_welcome.tmpl", user)
-	if err != nil {
-		app.serverErrorResponse(w, r, err)
-		return
-	}
-
-	app.background(func() {
-		data := map[string]any{
-			"activationToken": token.Plaintext,
-			"userID":          user.ID,
-		}
-
-		err = app.mailer.Send(user.Email, "user_welcome.tmpl", data)
-		if err != nil {
-			app.logger.Print(err, nil)
-		}
-	})
-
 	err = app.writeJSON(w, http.StatusCreated, envelope{"user": user}, nil)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
@@ -141,24 +134,24 @@ func (app *application) activateUserHandler(w http.ResponseWriter, r *http.Reque
 	}
 	err := app.readJSON(w, r, &input)
 	if err != nil {
-		// app.badRequestResponse(w, r, err)
+		app.badRequestResponse(w, r, err)
 		return
 	}
 	// Validate the plaintext token provided by the client.
 	v := validator.New()
 	if data.ValidateTokenPlaintext(v, input.TokenPlaintext); !v.Valid() {
-		// app.failedValidationResponse(w, r, v.Errors)
+		app.failedValidationResponse(w, r, v.Errors)
 		return
 	}
 	// Retrieve the details of the user associated with the token using the
 	// GetForToken() method (which we will create in a minute). If no matching record
 	// is found, then we let the client know that the token they provided is not valid.
-	user, err := app.models.User.GetForToken(data.ScopeActivation, input.TokenPlaintext)
+	user, err := app.models.User.GetForToken(r.Context(), data.ScopeActivation, input.TokenPlaintext)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
 			v.AddError("token", "invalid or expired activation token")
-			// app.failedValidationResponse(w, r, v.Errors)
+			app.failedValidationResponse(w, r, v.Errors)
 		default:
 			app.serverErrorResponse(w, r, err)
 		}
@@ -167,8 +160,16 @@ func (app *application) activateUserHandler(w http.ResponseWriter, r *http.Reque
 	// Update the user's activation status.
 	user.Activated = true
 	// Save the updated user record in our database, checking for any edit conflicts in
-	// the same way that we did for our movie records.
-	err = app.models.User.Update(user)
+	// the same way that we did for our movie records, and delete all activation tokens
+	// for the user in the same transaction.
+	err = app.audited(r).WithTx(r.Context(), func(m data.Models) error {
+		err := m.User.Update(r.Context(), user)
+		if err != nil {
+			return err
+		}
+
+		return m.Token.DeleteAllForUser(r.Context(), data.ScopeActivation, user.ID)
+	})
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrEditConflict):
@@ -178,13 +179,6 @@ func (app *application) activateUserHandler(w http.ResponseWriter, r *http.Reque
 		}
 		return
 	}
-	// If everything went successfully, then we delete all activation tokens for the
-	// user.
-	err = app.models.Token.DeleteAllForUser(data.ScopeActivation, user.ID)
-	if err != nil {
-		app.serverErrorResponse(w, r, err)
-		return
-	}
 	// Send the updated user details to the client in a JSON response.
 	err = app.writeJSON(w, http.StatusOK, envelope{"user": user}, nil)
 	if err != nil {
@@ -202,6 +196,7 @@ func (app *application) createRoleHandler(w http.ResponseWriter, r *http.Request
 	err := app.readJSON(w, r, &input)
 	if err != nil {
 		app.errorResponse(w, r, http.StatusBadRequest, err.Error())
+		return
 	}
 
 	role := &data.Role{
@@ -210,9 +205,21 @@ func (app *application) createRoleHandler(w http.ResponseWriter, r *http.Request
 		UserID:   input.UserID,
 	}
 
-	err = app.models.Role.InsertUserRole(role)
+	v := validator.New()
+	if data.ValidateRole(v, role); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.audited(r).Role.InsertUserRole(r.Context(), role)
 	if err != nil {
-		app.serverErrorResponse(w, r, err)
+		switch {
+		case errors.Is(err, data.ErrUnknownUser):
+			v.AddError("user_id", "does not exist")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
 		return
 	}
 
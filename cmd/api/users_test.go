@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/shynggys9219/greenlight/internal/data"
+	"github.com/shynggys9219/greenlight/internal/mailer"
+	"github.com/stretchr/testify/require"
+)
+
+func registerUser(ta *testApp, email string) *http.Response {
+	body := fmt.Sprintf(`{"name": "Alice", "email": %q, "password": "pa55word1234", "locale": "ru"}`, email)
+	return ta.doRequest(http.MethodPost, "/v1/users", nil, body, nil)
+}
+
+func TestRegisterUserSendsActivationEmail(t *testing.T) {
+	ta := newTestApp(t)
+
+	email := fmt.Sprintf("activation-%d@example.com", time.Now().UnixNano())
+
+	res := registerUser(ta, email)
+	require.Equal(t, http.StatusCreated, res.StatusCode)
+
+	var sent []mailer.Email
+	for _, e := range ta.deliverEmails() {
+		if e.To == email {
+			sent = append(sent, e)
+		}
+	}
+
+	require.Len(t, sent, 1, "expected one email to %s", email)
+	require.Equal(t, "user_welcome", sent[0].Template)
+	require.Equal(t, "ru", sent[0].Locale)
+	require.Contains(t, sent[0].PlainBody, `{"token": "`)
+}
+
+func TestRegisterUserWithMemstore(t *testing.T) {
+	ta, store := newMemstoreTestApp(t)
+
+	res := registerUser(ta, "alice@example.com")
+	require.Equal(t, http.StatusCreated, res.StatusCode)
+
+	// Emails are unique regardless of case.
+	res = registerUser(ta, "Alice@Example.com")
+	require.Equal(t, http.StatusUnprocessableEntity, res.StatusCode)
+
+	emails := store.Emails()
+	require.Len(t, emails, 1)
+	require.Equal(t, "user_welcome", emails[0].Template)
+
+	token, _ := emails[0].Data["activationToken"].(string)
+	user, err := ta.models.User.GetForToken(context.Background(), data.ScopeActivation, token)
+	require.NoError(t, err)
+	require.Equal(t, "alice@example.com", user.Email)
+
+	// A stale version is an edit conflict.
+	stale := *user
+	user.Activated = true
+	require.NoError(t, ta.models.User.Update(context.Background(), user))
+	require.ErrorIs(t, ta.models.User.Update(context.Background(), &stale), data.ErrEditConflict)
+}
+
+func TestActivateUserWithMemstore(t *testing.T) {
+	ta, store := newMemstoreTestApp(t)
+
+	res := registerUser(ta, "alice@example.com")
+	require.Equal(t, http.StatusCreated, res.StatusCode)
+
+	token, _ := store.Emails()[0].Data["activationToken"].(string)
+
+	activate := func(body string) (int, *data.User, map[string]string) {
+		var envelope struct {
+			User  *data.User        `json:"user"`
+			Error map[string]string `json:"error"`
+		}
+		res := ta.doRequest(http.MethodPut, "/v1/users/activated", nil, body, &envelope)
+		return res.StatusCode, envelope.User, envelope.Error
+	}
+
+	res = ta.doRequest(http.MethodPut, "/v1/users/activated", nil, `{"token": 1}`, nil)
+	require.Equal(t, http.StatusBadRequest, res.StatusCode)
+
+	status, _, errs := activate(`{"token": "short"}`)
+	require.Equal(t, http.StatusUnprocessableEntity, status)
+	require.Contains(t, errs, "token")
+
+	status, user, _ := activate(fmt.Sprintf(`{"token": %q}`, token))
+	require.Equal(t, http.StatusOK, status)
+	require.True(t, user.Activated)
+
+	// The token is deleted once used.
+	status, _, errs = activate(fmt.Sprintf(`{"token": %q}`, token))
+	require.Equal(t, http.StatusUnprocessableEntity, status)
+	require.Equal(t, "invalid or expired activation token", errs["token"])
+
+	stored, err := ta.models.User.GetByEmain(context.Background(), "alice@example.com")
+	require.NoError(t, err)
+	require.True(t, stored.Activated)
+}
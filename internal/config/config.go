@@ -0,0 +1,463 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/shynggys9219/greenlight/internal/validator"
+	"gopkg.in/yaml.v3"
+)
+
+// EnvPrefix is prepended to the upper-cased flag name to get the environment variable
+// for a setting, e.g. -db-dsn is read from GREENLIGHT_DB_DSN.
+const EnvPrefix = "GREENLIGHT_"
+
+// Secret is a string that never shows up in logs or printed configuration.
+type Secret string
+
+func (s Secret) String() string {
+	if s == "" {
+		return ""
+	}
+	return "[REDACTED]"
+}
+
+// Config holds every setting of the API. Settings are layered, later layers win:
+// built-in defaults, the YAML or TOML file given by -config (or GREENLIGHT_CONFIG),
+// GREENLIGHT_* environment variables, and finally command-line flags.
+type Config struct {
+	Port int    `yaml:"port"`
+	Env  string `yaml:"env"`
+	DB   struct {
+		DSN          Secret `yaml:"dsn"`            // a connection string to a sql server
+		MaxOpenConns int    `yaml:"max_open_conns"` // limit on the number of ‘open’ connections
+		MaxIdleConns int    `yaml:"max_idle_conns"` // limit on the number of idle connections in the pool
+		MaxIdleTime  string `yaml:"max_idle_time"`  // the maximum length of time that a connection can be idle
+		AutoMigrate  bool   `yaml:"automigrate"`    // apply pending migrations on startup
+
+		QueryTimeout time.Duration `yaml:"query_timeout"` // limit on a single query, on top of the request's own deadline
+	} `yaml:"db"`
+	Limiter struct {
+		Enabled bool    `yaml:"enabled"`
+		RPS     float64 `yaml:"rps"`
+		Burst   int     `yaml:"burst"`
+	} `yaml:"limiter"`
+	SMTP struct {
+		Transport string `yaml:"transport"` // smtp, maildir, memory or log
+		Maildir   string `yaml:"maildir"`   // directory used by the maildir transport
+		Host      string `yaml:"host"`
+		Port      int    `yaml:"port"`
+		Username  string `yaml:"username"`
+		Password  Secret `yaml:"password"`
+		Sender    string `yaml:"sender"`
+	} `yaml:"smtp"`
+	Storage struct {
+		Dir string `yaml:"dir"` // root directory of the filesystem blob storage
+		URL string `yaml:"url"` // URL prefix the stored files are served from
+	} `yaml:"storage"`
+	Uploads struct {
+		MaxBytes     int64 `yaml:"max_bytes"`     // largest accepted image upload
+		MinDimension int   `yaml:"min_dimension"` // smallest accepted width/height of an image, in pixels
+		MaxDimension int   `yaml:"max_dimension"` // largest accepted width/height of an image, in pixels
+		MaxDecodes   int   `yaml:"max_decodes"`   // images decoded at once; further uploads wait for a slot
+	} `yaml:"uploads"`
+	Outbox struct {
+		Workers      int           `yaml:"workers"`       // number of goroutines sending queued emails
+		BatchSize    int           `yaml:"batch_size"`    // emails claimed by a worker at once
+		PollInterval time.Duration `yaml:"poll_interval"` // how often idle workers look for due emails
+	} `yaml:"outbox"`
+	Login struct {
+		MaxFailures   int           `yaml:"max_failures"`    // failed logins for an account before it is locked out
+		IPMaxFailures int           `yaml:"ip_max_failures"` // failed logins from one address before it is blocked
+		Window        time.Duration `yaml:"window"`          // how far back failures are counted
+		Lockout       time.Duration `yaml:"lockout"`         // how long a lockout or block lasts
+		Delay         time.Duration `yaml:"delay"`           // wait after the first failure, doubled with each further one
+		MaxDelay      time.Duration `yaml:"max_delay"`       // cap on that wait
+	} `yaml:"login"`
+	Session struct {
+		AccessTTL  time.Duration `yaml:"access_ttl"`  // lifetime of an authentication token
+		RefreshTTL time.Duration `yaml:"refresh_ttl"` // lifetime of a refresh token, and so of an idle session
+	} `yaml:"session"`
+	Auth struct {
+		Mode string `yaml:"mode"` // stateful (tokens in the database) or stateless (signed JWTs)
+		JWT  struct {
+			Issuer   string `yaml:"issuer"`
+			Audience string `yaml:"audience"`
+			Keys     Secret `yaml:"keys"` // "kid:seed,..."; the first key signs, all of them verify
+
+			UserCacheTTL time.Duration `yaml:"user_cache_ttl"` // how long reads reuse the user of a signed token, 0 to always load it
+		} `yaml:"jwt"`
+	} `yaml:"auth"`
+	Jobs struct {
+		Enabled              bool          `yaml:"enabled"`                // run the scheduled background jobs in this instance
+		Interval             time.Duration `yaml:"interval"`               // time between two runs of a job
+		UnactivatedUserGrace time.Duration `yaml:"unactivated_user_grace"` // age at which never-activated accounts are deleted
+	} `yaml:"jobs"`
+
+	// Args are the command-line arguments left after the flags, e.g. a subcommand.
+	Args []string `yaml:"-"`
+}
+
+// Defaults returns the built-in settings. They are meant for local development and
+// deliberately contain no credentials.
+func Defaults() Config {
+	var cfg Config
+
+	cfg.Port = 9000
+	cfg.Env = "development"
+
+	// lib/pq falls back to PGUSER/PGPASSWORD when the DSN has no credentials.
+	cfg.DB.DSN = "postgres://localhost:5432/aitu_golang?sslmode=disable"
+	cfg.DB.MaxOpenConns = 25
+	cfg.DB.MaxIdleConns = 25
+	cfg.DB.MaxIdleTime = "15m"
+	cfg.DB.QueryTimeout = 3 * time.Second
+
+	cfg.Limiter.Enabled = true
+	cfg.Limiter.RPS = 2
+	cfg.Limiter.Burst = 4
+
+	cfg.SMTP.Transport = "log"
+	cfg.SMTP.Maildir = "./maildir"
+	cfg.SMTP.Host = "smtp.mailtrap.io"
+	cfg.SMTP.Port = 25
+	cfg.SMTP.Sender = "Greenlight <no-reply@greenlight.alexedwards.net>"
+
+	cfg.Storage.Dir = "./uploads"
+	cfg.Storage.URL = "/v1/images"
+
+	cfg.Uploads.MaxBytes = 10 << 20
+	cfg.Uploads.MinDimension = 100
+	cfg.Uploads.MaxDimension = 4000
+	cfg.Uploads.MaxDecodes = 2
+
+	cfg.Outbox.Workers = 2
+	cfg.Outbox.BatchSize = 10
+	cfg.Outbox.PollInterval = 5 * time.Second
+
+	cfg.Login.MaxFailures = 5
+	cfg.Login.IPMaxFailures = 50
+	cfg.Login.Window = 15 * time.Minute
+	cfg.Login.Lockout = 30 * time.Minute
+	cfg.Login.Delay = time.Second
+	cfg.Login.MaxDelay = 30 * time.Second
+
+	cfg.Session.AccessTTL = 15 * time.Minute
+	cfg.Session.RefreshTTL = 30 * 24 * time.Hour
+
+	cfg.Auth.Mode = "stateful"
+	cfg.Auth.JWT.Issuer = "greenlight"
+	cfg.Auth.JWT.Audience = "greenlight"
+	cfg.Auth.JWT.UserCacheTTL = 30 * time.Second
+
+	cfg.Jobs.Enabled = true
+	cfg.Jobs.Interval = time.Hour
+	cfg.Jobs.UnactivatedUserGrace = 7 * 24 * time.Hour
+
+	return cfg
+}
+
+// Load builds the configuration from all layers. args are the command-line arguments
+// without the program name and getenv is usually os.Getenv; tests pass their own.
+func Load(args []string, getenv func(string) string) (Config, error) {
+	cfg := Defaults()
+
+	path := getenv(EnvPrefix + "CONFIG")
+	if p, ok := configFlag(args); ok {
+		path = p
+	}
+
+	if path != "" {
+		err := cfg.loadFile(path)
+		if err != nil {
+			return cfg, err
+		}
+	}
+
+	// The flags are registered with the values loaded so far as defaults, so a flag
+	// that isn't passed keeps the file (or built-in) value.
+	fs := cfg.flagSet()
+
+	var envErr error
+	fs.VisitAll(func(f *flag.Flag) {
+		value := getenv(envName(f.Name))
+		if value == "" || envErr != nil {
+			return
+		}
+		if err := fs.Set(f.Name, value); err != nil {
+			envErr = fmt.Errorf("config: invalid value %q for %s: %w", value, envName(f.Name), err)
+		}
+	})
+	if envErr != nil {
+		return cfg, envErr
+	}
+
+	err := fs.Parse(args)
+	if err != nil {
+		return cfg, err
+	}
+	cfg.Args = fs.Args()
+
+	v := validator.New()
+	if Validate(v, &cfg); !v.Valid() {
+		return cfg, ValidationError(v.Errors)
+	}
+
+	return cfg, nil
+}
+
+// loadFile reads a YAML file, or a TOML one when path ends in ".toml". Both use the
+// yaml keys of Config, and unknown keys are an error.
+func (cfg *Config) loadFile(path string) error {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("config: %w", err)
+	}
+
+	if strings.EqualFold(filepath.Ext(path), ".toml") {
+		contents, err = tomlToYAML(contents)
+		if err != nil {
+			return fmt.Errorf("config: %s: %w", path, err)
+		}
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(contents))
+	decoder.KnownFields(true)
+
+	// An empty file is reported as io.EOF and simply changes nothing.
+	err = decoder.Decode(cfg)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("config: %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// tomlToYAML converts a TOML document to YAML, so that the settings have a single set
+// of keys and are decoded (and checked for typos) in one place.
+func tomlToYAML(contents []byte) ([]byte, error) {
+	var doc map[string]any
+
+	err := toml.Unmarshal(contents, &doc)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(doc) == 0 {
+		return nil, nil
+	}
+
+	return yaml.Marshal(doc)
+}
+
+func (cfg *Config) flagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet("greenlight", flag.ContinueOnError)
+
+	// -config is handled before the other flags, it is only registered here so it
+	// shows up in -help and isn't rejected as unknown.
+	fs.String("config", "", "Path to a YAML or TOML configuration file")
+
+	fs.IntVar(&cfg.Port, "port", cfg.Port, "API server port")
+	fs.StringVar(&cfg.Env, "env", cfg.Env, "Environment (development|staging|production)")
+
+	fs.Var(secretValue{&cfg.DB.DSN}, "db-dsn", "PostgreSQL DSN")
+	fs.IntVar(&cfg.DB.MaxOpenConns, "db-max-open-conns", cfg.DB.MaxOpenConns, "PostgreSQL max open connections")
+	fs.IntVar(&cfg.DB.MaxIdleConns, "db-max-idle-conns", cfg.DB.MaxIdleConns, "PostgreSQL max idle connections")
+	fs.StringVar(&cfg.DB.MaxIdleTime, "db-max-idle-time", cfg.DB.MaxIdleTime, "PostgreSQL max idle time")
+	fs.BoolVar(&cfg.DB.AutoMigrate, "db-automigrate", cfg.DB.AutoMigrate, "Apply pending database migrations on startup")
+	fs.DurationVar(&cfg.DB.QueryTimeout, "db-query-timeout", cfg.DB.QueryTimeout, "Timeout of a single database query")
+
+	fs.BoolVar(&cfg.Limiter.Enabled, "limiter-enabled", cfg.Limiter.Enabled, "Enable rate limiter")
+	fs.Float64Var(&cfg.Limiter.RPS, "limiter-rps", cfg.Limiter.RPS, "Rate limiter maximum requests per second")
+	fs.IntVar(&cfg.Limiter.Burst, "limiter-burst", cfg.Limiter.Burst, "Rate limiter maximum burst")
+
+	fs.StringVar(&cfg.SMTP.Transport, "mail-transport", cfg.SMTP.Transport, "Mail transport (smtp|maildir|memory|log)")
+	fs.StringVar(&cfg.SMTP.Maildir, "mail-maildir", cfg.SMTP.Maildir, "Maildir directory for the maildir mail transport")
+	fs.StringVar(&cfg.SMTP.Host, "smtp-host", cfg.SMTP.Host, "SMTP host")
+	fs.IntVar(&cfg.SMTP.Port, "smtp-port", cfg.SMTP.Port, "SMTP port")
+	fs.StringVar(&cfg.SMTP.Username, "smtp-username", cfg.SMTP.Username, "SMTP username")
+	fs.Var(secretValue{&cfg.SMTP.Password}, "smtp-password", "SMTP password")
+	fs.StringVar(&cfg.SMTP.Sender, "smtp-sender", cfg.SMTP.Sender, "SMTP sender")
+
+	fs.StringVar(&cfg.Storage.Dir, "storage-dir", cfg.Storage.Dir, "Directory for uploaded images")
+	fs.StringVar(&cfg.Storage.URL, "storage-url", cfg.Storage.URL, "URL prefix uploaded images are served from")
+
+	fs.Int64Var(&cfg.Uploads.MaxBytes, "upload-max-bytes", cfg.Uploads.MaxBytes, "Maximum image upload size in bytes")
+	fs.IntVar(&cfg.Uploads.MinDimension, "upload-min-dimension", cfg.Uploads.MinDimension, "Minimum image width and height in pixels")
+	fs.IntVar(&cfg.Uploads.MaxDimension, "upload-max-dimension", cfg.Uploads.MaxDimension, "Maximum image width and height in pixels")
+	fs.IntVar(&cfg.Uploads.MaxDecodes, "upload-max-decodes", cfg.Uploads.MaxDecodes, "Maximum number of uploaded images decoded at once")
+
+	fs.IntVar(&cfg.Outbox.Workers, "outbox-workers", cfg.Outbox.Workers, "Number of email outbox workers")
+	fs.IntVar(&cfg.Outbox.BatchSize, "outbox-batch-size", cfg.Outbox.BatchSize, "Emails claimed by an outbox worker at once")
+	fs.DurationVar(&cfg.Outbox.PollInterval, "outbox-poll-interval", cfg.Outbox.PollInterval, "Email outbox polling interval")
+
+	fs.IntVar(&cfg.Login.MaxFailures, "login-max-failures", cfg.Login.MaxFailures, "Failed logins before an account is locked out")
+	fs.IntVar(&cfg.Login.IPMaxFailures, "login-ip-max-failures", cfg.Login.IPMaxFailures, "Failed logins from one IP address before it is blocked")
+	fs.DurationVar(&cfg.Login.Window, "login-window", cfg.Login.Window, "Period in which failed logins are counted")
+	fs.DurationVar(&cfg.Login.Lockout, "login-lockout", cfg.Login.Lockout, "Duration of an account lockout or IP block")
+	fs.DurationVar(&cfg.Login.Delay, "login-delay", cfg.Login.Delay, "Delay after a failed login, doubled with each further failure")
+	fs.DurationVar(&cfg.Login.MaxDelay, "login-max-delay", cfg.Login.MaxDelay, "Maximum delay between failed logins")
+
+	fs.DurationVar(&cfg.Session.AccessTTL, "session-access-ttl", cfg.Session.AccessTTL, "Lifetime of authentication tokens")
+	fs.DurationVar(&cfg.Session.RefreshTTL, "session-refresh-ttl", cfg.Session.RefreshTTL, "Lifetime of refresh tokens")
+
+	fs.StringVar(&cfg.Auth.Mode, "auth-mode", cfg.Auth.Mode, "Authentication tokens to issue (stateful|stateless)")
+	fs.StringVar(&cfg.Auth.JWT.Issuer, "jwt-issuer", cfg.Auth.JWT.Issuer, "Issuer (iss) of signed tokens")
+	fs.StringVar(&cfg.Auth.JWT.Audience, "jwt-audience", cfg.Auth.JWT.Audience, "Audience (aud) of signed tokens")
+	fs.Var(secretValue{&cfg.Auth.JWT.Keys}, "jwt-keys", "Ed25519 signing keys as kid:base64-seed pairs separated by commas, the first one signs")
+	fs.DurationVar(&cfg.Auth.JWT.UserCacheTTL, "jwt-user-cache-ttl", cfg.Auth.JWT.UserCacheTTL, "How long read requests with a signed token reuse its user instead of loading it (0 disables)")
+
+	fs.BoolVar(&cfg.Jobs.Enabled, "jobs-enabled", cfg.Jobs.Enabled, "Run the scheduled background jobs")
+	fs.DurationVar(&cfg.Jobs.Interval, "jobs-interval", cfg.Jobs.Interval, "Time between two runs of a background job")
+	fs.DurationVar(&cfg.Jobs.UnactivatedUserGrace, "jobs-unactivated-user-grace", cfg.Jobs.UnactivatedUserGrace, "Age at which never-activated accounts are deleted")
+
+	return fs
+}
+
+// Validate checks the final configuration.
+func Validate(v *validator.Validator, cfg *Config) {
+	v.Check(cfg.Port > 0 && cfg.Port <= 65535, "port", "must be between 1 and 65535")
+	v.Check(validator.PermittedValue(cfg.Env, "development", "staging", "production"), "env", "must be development, staging or production")
+
+	v.Check(cfg.DB.DSN != "", "db-dsn", "must be provided")
+	v.Check(cfg.DB.MaxOpenConns > 0, "db-max-open-conns", "must be greater than zero")
+	v.Check(cfg.DB.MaxIdleConns >= 0, "db-max-idle-conns", "must not be negative")
+	_, err := time.ParseDuration(cfg.DB.MaxIdleTime)
+	v.Check(err == nil, "db-max-idle-time", "must be a duration such as 15m")
+	v.Check(cfg.DB.QueryTimeout > 0, "db-query-timeout", "must be greater than zero")
+
+	v.Check(!cfg.Limiter.Enabled || cfg.Limiter.RPS > 0, "limiter-rps", "must be greater than zero")
+	v.Check(!cfg.Limiter.Enabled || cfg.Limiter.Burst > 0, "limiter-burst", "must be greater than zero")
+
+	v.Check(validator.PermittedValue(cfg.SMTP.Transport, "smtp", "maildir", "memory", "log"), "mail-transport", "must be smtp, maildir, memory or log")
+	v.Check(cfg.SMTP.Transport != "smtp" || cfg.SMTP.Host != "", "smtp-host", "must be provided for the smtp transport")
+	v.Check(cfg.SMTP.Transport != "maildir" || cfg.SMTP.Maildir != "", "mail-maildir", "must be provided for the maildir transport")
+	// The memory and log transports never deliver mail, so users could not activate their accounts.
+	v.Check(cfg.Env != "production" || !validator.PermittedValue(cfg.SMTP.Transport, "memory", "log"), "mail-transport", "must be smtp or maildir in production")
+	v.Check(cfg.SMTP.Sender != "", "smtp-sender", "must be provided")
+
+	v.Check(cfg.Storage.Dir != "", "storage-dir", "must be provided")
+	v.Check(cfg.Uploads.MaxBytes > 0, "upload-max-bytes", "must be greater than zero")
+	v.Check(cfg.Uploads.MinDimension > 0 && cfg.Uploads.MinDimension <= cfg.Uploads.MaxDimension, "upload-min-dimension", "must be between 1 and upload-max-dimension")
+	v.Check(cfg.Uploads.MaxDecodes > 0, "upload-max-decodes", "must be greater than zero")
+
+	v.Check(cfg.Outbox.Workers >= 0, "outbox-workers", "must not be negative")
+	v.Check(cfg.Outbox.BatchSize > 0, "outbox-batch-size", "must be greater than zero")
+	v.Check(cfg.Outbox.PollInterval > 0, "outbox-poll-interval", "must be greater than zero")
+
+	v.Check(cfg.Login.MaxFailures > 0, "login-max-failures", "must be greater than zero")
+	v.Check(cfg.Login.IPMaxFailures > 0, "login-ip-max-failures", "must be greater than zero")
+	v.Check(cfg.Login.Window > 0, "login-window", "must be greater than zero")
+	v.Check(cfg.Login.Lockout > 0, "login-lockout", "must be greater than zero")
+	v.Check(cfg.Login.Delay >= 0 && cfg.Login.Delay <= cfg.Login.MaxDelay, "login-delay", "must be between zero and login-max-delay")
+
+	v.Check(cfg.Session.AccessTTL > 0, "session-access-ttl", "must be greater than zero")
+	v.Check(cfg.Session.RefreshTTL >= cfg.Session.AccessTTL, "session-refresh-ttl", "must not be shorter than session-access-ttl")
+
+	v.Check(validator.PermittedValue(cfg.Auth.Mode, "stateful", "stateless"), "auth-mode", "must be stateful or stateless")
+	v.Check(cfg.Auth.Mode != "stateless" || cfg.Auth.JWT.Keys != "", "jwt-keys", "must be provided for stateless authentication")
+	v.Check(cfg.Auth.JWT.UserCacheTTL >= 0, "jwt-user-cache-ttl", "must not be negative")
+
+	v.Check(cfg.Jobs.Interval > 0, "jobs-interval", "must be greater than zero")
+	v.Check(cfg.Jobs.UnactivatedUserGrace > 0, "jobs-unactivated-user-grace", "must be greater than zero")
+}
+
+// ValidationError is returned by Load when the merged configuration is invalid.
+type ValidationError map[string]string
+
+func (e ValidationError) Error() string {
+	keys := make([]string, 0, len(e))
+	for key := range e {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	problems := make([]string, 0, len(keys))
+	for _, key := range keys {
+		problems = append(problems, fmt.Sprintf("%s %s", key, e[key]))
+	}
+
+	return "config: invalid configuration: " + strings.Join(problems, "; ")
+}
+
+// String prints every setting as "name=value" with secrets redacted. The password of
+// the DSN is masked while the rest of it is kept, since host and database are handy
+// when debugging.
+func (cfg Config) String() string {
+	fs := cfg.flagSet()
+
+	var settings []string
+	fs.VisitAll(func(f *flag.Flag) {
+		if f.Name == "config" {
+			return
+		}
+		settings = append(settings, fmt.Sprintf("%s=%s", f.Name, f.Value.String()))
+	})
+
+	return strings.Join(settings, " ")
+}
+
+// RedactedDSN returns the DSN with its password replaced, or a fully redacted value
+// when it can't be parsed as a URL.
+func RedactedDSN(dsn Secret) string {
+	u, err := url.Parse(string(dsn))
+	if err != nil || u.Scheme == "" {
+		return dsn.String()
+	}
+	return u.Redacted()
+}
+
+// secretValue adapts a Secret to flag.Value. String() is what -help and Config.String
+// print, so it never reveals the value (except the non-secret parts of a DSN).
+type secretValue struct {
+	s *Secret
+}
+
+func (v secretValue) String() string {
+	if v.s == nil {
+		return ""
+	}
+	if strings.Contains(string(*v.s), "://") {
+		return RedactedDSN(*v.s)
+	}
+	return v.s.String()
+}
+
+func (v secretValue) Set(value string) error {
+	*v.s = Secret(value)
+	return nil
+}
+
+func envName(flagName string) string {
+	return EnvPrefix + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+// configFlag finds -config/--config in args without parsing the other flags.
+func configFlag(args []string) (string, bool) {
+	for i, arg := range args {
+		if arg == "--" {
+			break
+		}
+		name := strings.TrimLeft(arg, "-")
+		if name == arg {
+			continue
+		}
+		switch {
+		case name == "config" && i+1 < len(args):
+			return args[i+1], true
+		case strings.HasPrefix(name, "config="):
+			return strings.TrimPrefix(name, "config="), true
+		}
+	}
+	return "", false
+}
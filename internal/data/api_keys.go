@@ -0,0 +1,234 @@
+package data
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base32"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/shynggys9219/greenlight/internal/validator"
+)
+
+// Permissions an API key can be given. A key acts as its owner, so it can never do
+// more than the owner; the permissions only narrow that down. Safe methods (GET,
+// HEAD) need PermissionRead, everything else PermissionWrite, and routes restricted
+// to a role need PermissionAdmin as well.
+const (
+	PermissionRead  = "read"
+	PermissionWrite = "write"
+	PermissionAdmin = "admin"
+)
+
+var Permissions = []string{PermissionRead, PermissionWrite, PermissionAdmin}
+
+// apiKeyLastUsedPrecision limits how often last_used_at is written for a busy key.
+const apiKeyLastUsedPrecision = time.Minute
+
+type APIKey struct {
+	ID          int64      `json:"id"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UserID      int64      `json:"-"`
+	Name        string     `json:"name"`
+	Prefix      string     `json:"prefix"`
+	Plaintext   string     `json:"key,omitempty"` // only set right after creation
+	Hash        []byte     `json:"-"`
+	Permissions []string   `json:"permissions"`
+	Expiry      *time.Time `json:"expiry,omitempty"`
+	LastUsedAt  *time.Time `json:"last_used_at,omitempty"`
+}
+
+// Permits reports whether the key was given permission.
+func (k *APIKey) Permits(permission string) bool {
+	return validator.PermittedValue(permission, k.Permissions...)
+}
+
+// GenerateAPIKey fills in a new random key. The plaintext is "gl_<prefix>_<secret>",
+// so keys are easy to recognize, e.g. by secret scanners.
+func GenerateAPIKey(key *APIKey) error {
+	encoding := base32.StdEncoding.WithPadding(base32.NoPadding)
+
+	randomBytes := make([]byte, 5+20)
+
+	_, err := rand.Read(randomBytes)
+	if err != nil {
+		return err
+	}
+
+	key.Prefix = strings.ToLower(encoding.EncodeToString(randomBytes[:5]))
+	key.Plaintext = "gl_" + key.Prefix + "_" + encoding.EncodeToString(randomBytes[5:])
+
+	hash := sha256.Sum256([]byte(key.Plaintext))
+	key.Hash = hash[:]
+
+	return nil
+}
+
+func ValidateAPIKey(v *validator.Validator, key *APIKey) {
+	v.Check(key.Name != "", "name", "must be provided")
+	v.Check(len(key.Name) <= 100, "name", "must not be more than 100 bytes long")
+
+	v.Check(len(key.Permissions) > 0, "permissions", "must contain at least 1 permission")
+	v.Check(validator.Unique(key.Permissions), "permissions", "must not contain duplicate values")
+	for _, permission := range key.Permissions {
+		v.Check(validator.PermittedValue(permission, Permissions...), "permissions", "must only contain read, write or admin")
+	}
+
+	if key.Expiry != nil {
+		v.Check(key.Expiry.After(time.Now()), "expiry", "must be in the future")
+	}
+}
+
+func ValidateAPIKeyPlaintext(v *validator.Validator, plaintext string) {
+	v.Check(plaintext != "", "key", "must be provided")
+	v.Check(len(plaintext) == 3+8+1+32 && strings.HasPrefix(plaintext, "gl_"), "key", "must be a greenlight API key")
+}
+
+type APIKeyModel struct {
+	DB querier
+}
+
+// Insert generates the key and stores it. key.Plaintext is the only copy of the key
+// and has to be handed to the user.
+func (m APIKeyModel) Insert(ctx context.Context, key *APIKey) error {
+	err := GenerateAPIKey(key)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO api_keys (user_id, name, prefix, hash, permissions, expiry)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at`
+
+	args := []any{key.UserID, key.Name, key.Prefix, key.Hash, pq.Array(key.Permissions), key.Expiry}
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	return m.DB.QueryRowContext(ctx, query, args...).Scan(&key.ID, &key.CreatedAt)
+}
+
+// GetForPlaintext returns the unexpired key and records that it was used.
+func (m APIKeyModel) GetForPlaintext(ctx context.Context, plaintext string) (*APIKey, error) {
+	hash := sha256.Sum256([]byte(plaintext))
+
+	query := `
+		SELECT id, created_at, user_id, name, prefix, permissions, expiry, last_used_at
+		FROM api_keys
+		WHERE hash = $1 AND (expiry IS NULL OR expiry > $2)`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	var key APIKey
+
+	err := m.DB.QueryRowContext(ctx, query, hash[:], time.Now()).Scan(
+		&key.ID,
+		&key.CreatedAt,
+		&key.UserID,
+		&key.Name,
+		&key.Prefix,
+		pq.Array(&key.Permissions),
+		&key.Expiry,
+		&key.LastUsedAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	// Writing the row on every request would make a busy key a hot spot, so
+	// last_used_at is only as precise as apiKeyLastUsedPrecision.
+	now := time.Now()
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) > apiKeyLastUsedPrecision {
+		_, err = m.DB.ExecContext(ctx, `UPDATE api_keys SET last_used_at = $1 WHERE id = $2`, now, key.ID)
+		if err != nil {
+			return nil, err
+		}
+		key.LastUsedAt = &now
+	}
+
+	return &key, nil
+}
+
+// GetAllForUser lists the user's keys, expired ones included, newest first.
+func (m APIKeyModel) GetAllForUser(ctx context.Context, userID int64) ([]*APIKey, error) {
+	query := `
+		SELECT id, created_at, user_id, name, prefix, permissions, expiry, last_used_at
+		FROM api_keys
+		WHERE user_id = $1
+		ORDER BY id DESC`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []*APIKey{}
+
+	for rows.Next() {
+		var key APIKey
+
+		err := rows.Scan(
+			&key.ID,
+			&key.CreatedAt,
+			&key.UserID,
+			&key.Name,
+			&key.Prefix,
+			pq.Array(&key.Permissions),
+			&key.Expiry,
+			&key.LastUsedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		keys = append(keys, &key)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+// Delete revokes one of the user's keys. Keys of other users are reported as
+// ErrRecordNotFound.
+func (m APIKeyModel) Delete(ctx context.Context, id, userID int64) error {
+	query := `
+		DELETE FROM api_keys
+		WHERE id = $1 AND user_id = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, id, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,229 @@
+package data
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/shynggys9219/greenlight/internal/validator"
+)
+
+// Actor is who makes a change, as recorded in the audit log. The zero Actor is the
+// system itself, e.g. a background job.
+type Actor struct {
+	UserID    int64 // 0 for the system and anonymous requests
+	IP        string
+	RequestID string
+}
+
+// Change is the value of one field before and after a change. Before is null for
+// created resources, After for deleted ones.
+type Change struct {
+	Before any `json:"before"`
+	After  any `json:"after"`
+}
+
+type AuditEvent struct {
+	ID           int64             `json:"id"`
+	CreatedAt    time.Time         `json:"created_at"`
+	ActorID      int64             `json:"actor_id,omitempty"`
+	Action       string            `json:"action"` // e.g. "movie.update", "role.grant"
+	ResourceType string            `json:"resource_type"`
+	ResourceID   int64             `json:"resource_id"`
+	Changes      map[string]Change `json:"changes"`
+	IP           string            `json:"ip,omitempty"`
+	RequestID    string            `json:"request_id,omitempty"`
+}
+
+// diff compares the JSON representations of before and after, either of which can be
+// nil, and returns the fields that differ. Fields hidden from JSON, like password
+// hashes, never show up.
+func diff(before, after any) (map[string]Change, error) {
+	b, err := auditFields(before)
+	if err != nil {
+		return nil, err
+	}
+
+	a, err := auditFields(after)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := map[string]Change{}
+
+	for key, value := range b {
+		if !reflect.DeepEqual(value, a[key]) {
+			changes[key] = Change{Before: value, After: a[key]}
+		}
+	}
+
+	for key, value := range a {
+		if _, ok := b[key]; !ok {
+			changes[key] = Change{After: value}
+		}
+	}
+
+	return changes, nil
+}
+
+func auditFields(v any) (map[string]any, error) {
+	fields := map[string]any{}
+
+	if rv := reflect.ValueOf(v); v == nil || (rv.Kind() == reflect.Pointer && rv.IsNil()) {
+		return fields, nil
+	}
+
+	js, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(js, &fields)
+	if err != nil {
+		return nil, err
+	}
+
+	return fields, nil
+}
+
+// userDiff is diff for users. A new password is noted without either hash.
+func userDiff(before, after *User) (map[string]Change, error) {
+	changes, err := diff(before, after)
+	if err != nil {
+		return nil, err
+	}
+
+	if before != nil && after != nil && !bytes.Equal(before.Password.hash, after.Password.hash) {
+		changes["password"] = Change{Before: "[redacted]", After: "[redacted]"}
+	}
+
+	return changes, nil
+}
+
+// recordEvent writes an audit event in the transaction of the change it describes, so
+// there is never a change without its event or the other way round.
+func recordEvent(ctx context.Context, tx querier, actor Actor, action, resourceType string, resourceID int64, changes map[string]Change) error {
+	js, err := json.Marshal(changes)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO audit_events (actor_id, action, resource_type, resource_id, changes, ip, request_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`
+
+	args := []any{
+		sql.NullInt64{Int64: actor.UserID, Valid: actor.UserID != 0},
+		action,
+		resourceType,
+		resourceID,
+		js,
+		actor.IP,
+		actor.RequestID,
+	}
+
+	_, err = tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return fmt.Errorf("audit %s: %w", action, err)
+	}
+
+	return nil
+}
+
+// AuditFilter narrows down AuditModel.GetAll. Zero values don't filter.
+type AuditFilter struct {
+	ActorID       int64
+	Action        string
+	ResourceType  string
+	ResourceID    int64
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+}
+
+func ValidateAuditFilter(v *validator.Validator, filter AuditFilter) {
+	v.Check(filter.ActorID >= 0, "actor_id", "must not be negative")
+	v.Check(filter.ResourceID >= 0, "resource_id", "must not be negative")
+	v.Check(filter.ResourceID == 0 || filter.ResourceType != "", "resource_id", "needs resource_type")
+	v.Check(filter.CreatedAfter.IsZero() || filter.CreatedBefore.IsZero() || filter.CreatedAfter.Before(filter.CreatedBefore), "created_after", "must be before created_before")
+}
+
+type AuditModel struct {
+	DB querier
+}
+
+// GetAll lists events newest first.
+func (m AuditModel) GetAll(ctx context.Context, filter AuditFilter, filters Filters) ([]*AuditEvent, error) {
+	query := `
+		SELECT id, created_at, actor_id, action, resource_type, resource_id, changes, ip, request_id
+		FROM audit_events
+		WHERE (actor_id = $1 OR $1 = 0)
+		AND (action = $2 OR $2 = '')
+		AND (resource_type = $3 OR $3 = '')
+		AND (resource_id = $4 OR $4 = 0)
+		AND (created_at >= $5 OR $5 IS NULL)
+		AND (created_at < $6 OR $6 IS NULL)
+		ORDER BY id DESC
+		LIMIT $7 OFFSET $8`
+
+	args := []any{
+		filter.ActorID,
+		filter.Action,
+		filter.ResourceType,
+		filter.ResourceID,
+		sql.NullTime{Time: filter.CreatedAfter, Valid: !filter.CreatedAfter.IsZero()},
+		sql.NullTime{Time: filter.CreatedBefore, Valid: !filter.CreatedBefore.IsZero()},
+		filters.limit(),
+		filters.offset(),
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []*AuditEvent{}
+
+	for rows.Next() {
+		var event AuditEvent
+		var actorID sql.NullInt64
+		var changes []byte
+
+		err := rows.Scan(
+			&event.ID,
+			&event.CreatedAt,
+			&actorID,
+			&event.Action,
+			&event.ResourceType,
+			&event.ResourceID,
+			&changes,
+			&event.IP,
+			&event.RequestID,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		event.ActorID = actorID.Int64
+
+		err = json.Unmarshal(changes, &event.Changes)
+		if err != nil {
+			return nil, err
+		}
+
+		events = append(events, &event)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
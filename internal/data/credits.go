@@ -0,0 +1,287 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/shynggys9219/greenlight/internal/validator"
+)
+
+var (
+	ErrDuplicateCredit = errors.New("duplicate credit")
+	ErrUnknownPerson   = errors.New("unknown person")
+)
+
+// The credit roles accepted by the credits_role_check constraint.
+const (
+	CreditActor    = "actor"
+	CreditDirector = "director"
+	CreditWriter   = "writer"
+	CreditComposer = "composer"
+	CreditProducer = "producer"
+)
+
+// Credit links a person to a movie in a specific role. PersonName is filled
+// from the people table when credits are read back for a movie.
+type Credit struct {
+	ID            int64  `json:"id"`
+	MovieID       int64  `json:"movie_id"`
+	PersonID      int64  `json:"person_id"`
+	PersonName    string `json:"person_name,omitempty"`
+	Role          string `json:"role"`
+	CharacterName string `json:"character_name,omitempty"`
+	BillingOrder  int32  `json:"billing_order"`
+	Version       int32  `json:"version"`
+}
+
+// FilmographyEntry is one credit of a person seen from the movie side.
+type FilmographyEntry struct {
+	CreditID      int64  `json:"credit_id"`
+	MovieID       int64  `json:"movie_id"`
+	Title         string `json:"title"`
+	Year          int32  `json:"year,omitempty"`
+	Role          string `json:"role"`
+	CharacterName string `json:"character_name,omitempty"`
+	BillingOrder  int32  `json:"billing_order"`
+}
+
+type CreditModel struct {
+	DB querier
+}
+
+func ValidateCredit(v *validator.Validator, credit *Credit) {
+	v.Check(credit.PersonID > 0, "person_id", "must be provided")
+	v.Check(validator.PermittedValue(credit.Role, CreditActor, CreditDirector, CreditWriter, CreditComposer, CreditProducer), "role", "must be one of actor, director, writer, composer or producer")
+	v.Check(credit.Role == CreditActor || credit.CharacterName == "", "character_name", "must only be set for actors")
+	v.Check(len(credit.CharacterName) <= 500, "character_name", "must not be more than 500 bytes long")
+	v.Check(credit.BillingOrder >= 0, "billing_order", "must not be negative")
+}
+
+func (m CreditModel) Insert(ctx context.Context, credit *Credit) error {
+	query := `
+		INSERT INTO credits (movie_id, person_id, role, character_name, billing_order)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, version`
+
+	args := []any{credit.MovieID, credit.PersonID, credit.Role, credit.CharacterName, credit.BillingOrder}
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&credit.ID, &credit.Version)
+	if err != nil {
+		return creditError(err)
+	}
+
+	return nil
+}
+
+// Get fetches a credit that belongs to the given movie, so a credit id from one
+// movie can't be used through another movie's URL.
+func (m CreditModel) Get(ctx context.Context, movieID, id int64) (*Credit, error) {
+	if movieID < 1 || id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+		SELECT credits.id, credits.movie_id, credits.person_id, people.name, credits.role,
+			credits.character_name, credits.billing_order, credits.version
+		FROM credits
+		INNER JOIN people ON people.id = credits.person_id
+		WHERE credits.movie_id = $1 AND credits.id = $2`
+
+	var credit Credit
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, movieID, id).Scan(
+		&credit.ID,
+		&credit.MovieID,
+		&credit.PersonID,
+		&credit.PersonName,
+		&credit.Role,
+		&credit.CharacterName,
+		&credit.BillingOrder,
+		&credit.Version,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &credit, nil
+}
+
+func (m CreditModel) Update(ctx context.Context, credit *Credit) error {
+	query := `
+		UPDATE credits
+		SET person_id = $1, role = $2, character_name = $3, billing_order = $4, version = version + 1
+		WHERE id = $5 AND movie_id = $6 AND version = $7
+		RETURNING version`
+
+	args := []any{
+		credit.PersonID,
+		credit.Role,
+		credit.CharacterName,
+		credit.BillingOrder,
+		credit.ID,
+		credit.MovieID,
+		credit.Version,
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&credit.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return creditError(err)
+		}
+	}
+
+	return nil
+}
+
+func (m CreditModel) Delete(ctx context.Context, movieID, id int64) error {
+	if movieID < 1 || id < 1 {
+		return ErrRecordNotFound
+	}
+
+	query := `
+		DELETE FROM credits
+		WHERE movie_id = $1 AND id = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, movieID, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// GetAllForMovie returns the cast and crew of a movie in billing order.
+func (m CreditModel) GetAllForMovie(ctx context.Context, movieID int64) ([]*Credit, error) {
+	query := `
+		SELECT credits.id, credits.movie_id, credits.person_id, people.name, credits.role,
+			credits.character_name, credits.billing_order, credits.version
+		FROM credits
+		INNER JOIN people ON people.id = credits.person_id
+		WHERE credits.movie_id = $1
+		ORDER BY credits.billing_order ASC, credits.id ASC`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, movieID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	credits := []*Credit{}
+
+	for rows.Next() {
+		var credit Credit
+		err := rows.Scan(
+			&credit.ID,
+			&credit.MovieID,
+			&credit.PersonID,
+			&credit.PersonName,
+			&credit.Role,
+			&credit.CharacterName,
+			&credit.BillingOrder,
+			&credit.Version,
+		)
+		if err != nil {
+			return nil, err
+		}
+		credits = append(credits, &credit)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return credits, nil
+}
+
+// GetFilmography returns every credit of a person, newest movies first.
+func (m CreditModel) GetFilmography(ctx context.Context, personID int64) ([]*FilmographyEntry, error) {
+	query := `
+		SELECT credits.id, movies.id, movies.title, movies.year, credits.role,
+			credits.character_name, credits.billing_order
+		FROM credits
+		INNER JOIN movies ON movies.id = credits.movie_id
+		WHERE credits.person_id = $1
+		ORDER BY movies.year DESC, movies.id ASC, credits.billing_order ASC`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, personID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	entries := []*FilmographyEntry{}
+
+	for rows.Next() {
+		var entry FilmographyEntry
+		err := rows.Scan(
+			&entry.CreditID,
+			&entry.MovieID,
+			&entry.Title,
+			&entry.Year,
+			&entry.Role,
+			&entry.CharacterName,
+			&entry.BillingOrder,
+		)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, &entry)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+func creditError(err error) error {
+	switch {
+	case err.Error() == `pq: duplicate key value violates unique constraint "credits_movie_id_person_id_role_character_name_key"`:
+		return ErrDuplicateCredit
+	case err.Error() == `pq: insert or update on table "credits" violates foreign key constraint "credits_person_id_fkey"`:
+		return ErrUnknownPerson
+	case err.Error() == `pq: insert or update on table "credits" violates foreign key constraint "credits_movie_id_fkey"`:
+		return ErrRecordNotFound
+	default:
+		return err
+	}
+}
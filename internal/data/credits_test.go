@@ -0,0 +1,67 @@
+package data
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/shynggys9219/greenlight/internal/validator"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateCredit(t *testing.T) {
+	tests := []struct {
+		name   string
+		credit Credit
+		errors []string
+	}{
+		{"actor", Credit{PersonID: 1, Role: CreditActor, CharacterName: "Ripley", BillingOrder: 1}, nil},
+		{"actor without a character", Credit{PersonID: 1, Role: CreditActor}, nil},
+		{"director", Credit{PersonID: 1, Role: CreditDirector}, nil},
+		{"composer", Credit{PersonID: 1, Role: CreditComposer, BillingOrder: 3}, nil},
+		{"no person", Credit{Role: CreditWriter}, []string{"person_id"}},
+		{"negative person", Credit{PersonID: -1, Role: CreditWriter}, []string{"person_id"}},
+		{"no role", Credit{PersonID: 1}, []string{"role"}},
+		{"unknown role", Credit{PersonID: 1, Role: "gaffer"}, []string{"role"}},
+		{"role in upper case", Credit{PersonID: 1, Role: "Actor", CharacterName: "Ripley"}, []string{"role", "character_name"}},
+		{"character for a writer", Credit{PersonID: 1, Role: CreditWriter, CharacterName: "Ripley"}, []string{"character_name"}},
+		{"character too long", Credit{PersonID: 1, Role: CreditActor, CharacterName: strings.Repeat("a", 501)}, []string{"character_name"}},
+		{"negative billing order", Credit{PersonID: 1, Role: CreditProducer, BillingOrder: -1}, []string{"billing_order"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := validator.New()
+			ValidateCredit(v, &tt.credit)
+
+			keys := []string{}
+			for key := range v.Errors {
+				keys = append(keys, key)
+			}
+			require.ElementsMatch(t, tt.errors, keys)
+		})
+	}
+}
+
+func TestValidatePerson(t *testing.T) {
+	tests := []struct {
+		person Person
+		errors []string
+	}{
+		{Person{Name: "Sigourney Weaver"}, nil},
+		{Person{Name: "Sigourney Weaver", Biography: strings.Repeat("a", 10000)}, nil},
+		{Person{}, []string{"name"}},
+		{Person{Name: strings.Repeat("a", 501)}, []string{"name"}},
+		{Person{Name: "Sigourney Weaver", Biography: strings.Repeat("a", 10001)}, []string{"biography"}},
+	}
+
+	for _, tt := range tests {
+		v := validator.New()
+		ValidatePerson(v, &tt.person)
+
+		keys := []string{}
+		for key := range v.Errors {
+			keys = append(keys, key)
+		}
+		require.ElementsMatch(t, tt.errors, keys, tt.person.Name)
+	}
+}
@@ -5,7 +5,6 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
-	"time"
 
 	"github.com/lib/pq"
 )
@@ -18,19 +17,72 @@ type Director struct {
 }
 
 type DirectorModel struct {
-	DB *sql.DB
+	DB    querier
+	Actor Actor // who changes are attributed to in the audit log, see Models.As
 }
 
-func (d DirectorModel) Insert(director *Director) error {
+func (d DirectorModel) Insert(ctx context.Context, director *Director) error {
 	query := `
 		INSERT INTO directors(direc_name, direc_surname, awards)
 		VALUES ($1, $2, $3)
 		RETURNING id`
 
-	return d.DB.QueryRow(query, &director.Name, &director.Surname, pq.Array(&director.Awards)).Scan(&director.ID)
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	tx, err := beginTx(ctx, d.DB)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, query, &director.Name, &director.Surname, pq.Array(&director.Awards)).Scan(&director.ID)
+	if err != nil {
+		return err
+	}
+
+	changes, err := diff(nil, director)
+	if err != nil {
+		return err
+	}
+
+	err = recordEvent(ctx, tx, d.Actor, "director.create", "director", director.ID, changes)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
-func (d DirectorModel) Get(id int64) (*Director, error) {
+// getForUpdate reads and locks a director in tx.
+func (d DirectorModel) getForUpdate(ctx context.Context, tx querier, id int64) (*Director, error) {
+	query := `
+		SELECT id, direc_name, direc_surname, awards
+		FROM directors
+		WHERE id = $1
+		FOR UPDATE`
+
+	var director Director
+
+	err := tx.QueryRowContext(ctx, query, id).Scan(
+		&director.ID,
+		&director.Name,
+		&director.Surname,
+		pq.Array(&director.Awards),
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &director, nil
+}
+
+func (d DirectorModel) Get(ctx context.Context, id int64) (*Director, error) {
 	if id < 1 {
 		return nil, ErrRecordNotFound
 	}
@@ -42,7 +94,10 @@ func (d DirectorModel) Get(id int64) (*Director, error) {
 
 	var director Director
 
-	err := d.DB.QueryRow(query, id).Scan(
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	err := d.DB.QueryRowContext(ctx, query, id).Scan(
 		&director.ID,
 		&director.Name,
 		&director.Surname,
@@ -63,7 +118,7 @@ func (d DirectorModel) Get(id int64) (*Director, error) {
 }
 
 // method for updating a specific record in the movies table.
-func (d DirectorModel) Update(director *Director) error {
+func (d DirectorModel) Update(ctx context.Context, director *Director) error {
 	query := `
 		UPDATE directors
 		SET direc_name = $1, direc_surname = $2, awards = $3
@@ -76,11 +131,40 @@ func (d DirectorModel) Update(director *Director) error {
 		director.ID,
 	}
 
-	return d.DB.QueryRow(query, args...).Scan()
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	tx, err := beginTx(ctx, d.DB)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	before, err := d.getForUpdate(ctx, tx, director.ID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+
+	changes, err := diff(before, director)
+	if err != nil {
+		return err
+	}
+
+	err = recordEvent(ctx, tx, d.Actor, "director.update", "director", director.ID, changes)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
 // method for deleting a specific record from the movies table.
-func (d DirectorModel) Delete(id int64) error {
+func (d DirectorModel) Delete(ctx context.Context, id int64) error {
 	if id < 1 {
 		return ErrRecordNotFound
 	}
@@ -89,24 +173,39 @@ func (d DirectorModel) Delete(id int64) error {
 		DELETE FROM directors
 		WHERE id = $1`
 
-	result, err := d.DB.Exec(query, id)
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	tx, err := beginTx(ctx, d.DB)
 	if err != nil {
 		return err
 	}
+	defer tx.Rollback()
 
-	rowsAffected, err := result.RowsAffected()
+	before, err := d.getForUpdate(ctx, tx, id)
 	if err != nil {
 		return err
 	}
 
-	if rowsAffected == 0 {
-		return ErrRecordNotFound
+	_, err = tx.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
 	}
 
-	return nil
+	changes, err := diff(before, nil)
+	if err != nil {
+		return err
+	}
+
+	err = recordEvent(ctx, tx, d.Actor, "director.delete", "director", id, changes)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
-func (d DirectorModel) GetAll(name string, surname string, awards []string, filters Filters) ([]*Director, error) {
+func (d DirectorModel) GetAll(ctx context.Context, name string, surname string, awards []string, filters Filters) ([]*Director, error) {
 	// query := `
 	// SELECT *
 	// FROM directors
@@ -119,15 +218,20 @@ func (d DirectorModel) GetAll(name string, surname string, awards []string, filt
 	// AND (awards @> $2 OR $2 = '{}')
 	// ORDER BY %s %s, id ASC
 	// LIMIT $3 OFFSET $4`, filters.sortColumn(), filters.sortByAwards())
+	orderBy, err := filters.orderBy()
+	if err != nil {
+		return nil, err
+	}
+
 	query := fmt.Sprintf(`
 SELECT id, direc_name, direc_surname, awards
 FROM directors
 WHERE   (to_tsvector('simple', direc_name) @@ plainto_tsquery('simple', $1) OR $1 = '')
 AND (awards @> $2 OR $2 = '{}')
-ORDER BY %s %s, id ASC
-LIMIT $3 OFFSET $4`, filters.sortColumn(), filters.sortByAwards())
+ORDER BY %s, id ASC
+LIMIT $3 OFFSET $4`, orderBy)
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
 	defer cancel()
 
 	// rows, err := d.DB.QueryContext(ctx, query)
@@ -163,22 +267,27 @@ LIMIT $3 OFFSET $4`, filters.sortColumn(), filters.sortByAwards())
 	return directors, nil
 }
 
-func (d DirectorModel) GetOneByName(name string, filters Filters) ([]*Director, error) {
+func (d DirectorModel) GetOneByName(ctx context.Context, name string, filters Filters) ([]*Director, error) {
 	// query := `
 	// SELECT *
 	// FROM directors
 	// WHERE direc_name = '$1'
 	// ORDER BY id`
 
+	orderBy, err := filters.orderBy()
+	if err != nil {
+		return nil, err
+	}
+
 	query := fmt.Sprintf(`
 SELECT id, direc_name, direc_surname, awards
 FROM directors
 WHERE   (to_tsvector('simple', direc_name) @@ plainto_tsquery('simple', $1) OR $1 = '')
 AND (awards @> $2 OR $2 = '{}')
-ORDER BY %s %s, id ASC
-LIMIT $3 OFFSET $4`, filters.sortColumn(), filters.sortByAwards())
+ORDER BY %s, id ASC
+LIMIT $3 OFFSET $4`, orderBy)
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
 	defer cancel()
 
 	rows, err := d.DB.QueryContext(ctx, query)
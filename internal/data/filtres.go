@@ -1,29 +1,145 @@
 package data
 
-import "strings"
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/shynggys9219/greenlight/internal/validator"
+)
 
 type Filters struct {
-	Page         int
-	PageSize     int
-	Sort         string
-	SortSafelist []string
+	Page     int
+	PageSize int
+	Sort     string   // comma-separated sort keys, each prefixed with "-" for descending
+	SortSpec SortSpec // the sort keys the resource accepts
+}
+
+// SortSpec maps the sort keys a resource accepts in the API to the SQL columns they
+// sort by. Only columns from a spec ever go into a query, never text from the request.
+type SortSpec map[string]string
+
+// The sort specs of the resources that can be listed.
+var (
+	MovieSort = SortSpec{
+		"id":         "id",
+		"title":      "title",
+		"year":       "year",
+		"runtime":    "runtime",
+		"created_at": "created_at",
+	}
+	DirectorSort = SortSpec{
+		"id":      "id",
+		"name":    "direc_name",
+		"surname": "direc_surname",
+		"awards":  "awards",
+	}
+	UserSort = SortSpec{
+		"id":         "id",
+		"email":      "email",
+		"created_at": "created_at",
+	}
+	PersonSort = SortSpec{
+		"id":   "id",
+		"name": "name",
+	}
+	OutboxSort = SortSpec{
+		"id":              "id",
+		"created_at":      "created_at",
+		"next_attempt_at": "next_attempt_at",
+	}
+)
+
+// SortField is one key of a parsed sort.
+type SortField struct {
+	Key    string // the API sort key, without the "-"
+	Column string // the SQL column Key maps to
+	Desc   bool
+}
+
+// Parse parses a sort like "-year,title" into its fields. The errors read as
+// validation messages: for an empty key, a key the spec doesn't have, or a key
+// given twice.
+func (s SortSpec) Parse(sort string) ([]SortField, error) {
+	fields := []SortField{}
+	seen := map[string]bool{}
+
+	for _, key := range strings.Split(sort, ",") {
+		field := SortField{Key: strings.TrimPrefix(key, "-"), Desc: strings.HasPrefix(key, "-")}
+
+		if field.Key == "" {
+			return nil, errors.New("must not have an empty key")
+		}
+
+		column, ok := s[field.Key]
+		if !ok {
+			return nil, fmt.Errorf("unknown key %q, must be one of %s", field.Key, strings.Join(s.keys(), ", "))
+		}
+
+		if seen[field.Key] {
+			return nil, fmt.Errorf("must not have the key %q twice", field.Key)
+		}
+		seen[field.Key] = true
+
+		field.Column = column
+		fields = append(fields, field)
+	}
+
+	return fields, nil
+}
+
+func (s SortSpec) keys() []string {
+	keys := make([]string, 0, len(s))
+	for key := range s {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
 }
 
-func (f Filters) sortColumn() string {
-	for _, safeValue := range f.SortSafelist {
-		if f.Sort == safeValue {
-			return strings.TrimPrefix(f.Sort, "-	")
+// SortFields parses Sort with SortSpec.
+func (f Filters) SortFields() ([]SortField, error) {
+	return f.SortSpec.Parse(f.Sort)
+}
+
+// orderBy returns the ORDER BY terms for Sort, e.g. "year DESC, title ASC". Handlers
+// check the sort with ValidateSort first, so an error here is a bug.
+func (f Filters) orderBy() (string, error) {
+	fields, err := f.SortFields()
+	if err != nil {
+		return "", fmt.Errorf("invalid sort %q: %w", f.Sort, err)
+	}
+
+	terms := make([]string, 0, len(fields))
+
+	for _, field := range fields {
+		direction := "ASC"
+		if field.Desc {
+			direction = "DESC"
 		}
+
+		terms = append(terms, field.Column+" "+direction)
 	}
 
-	panic("unsafe sort parameter: " + f.Sort)
+	return strings.Join(terms, ", "), nil
 }
 
-func (f Filters) sortDirection() string {
-	if strings.HasPrefix(f.Sort, "-") {
-		return "DESC"
+// ValidateFilters checks the page, the page size and the sort.
+func ValidateFilters(v *validator.Validator, f Filters) {
+	v.Check(f.Page > 0, "page", "must be greater than zero")
+	v.Check(f.PageSize > 0 && f.PageSize <= 100, "page_size", "must be between 1 and 100")
+
+	ValidateSort(v, f)
+}
+
+// ValidateSort checks Sort against SortSpec.
+func ValidateSort(v *validator.Validator, f Filters) {
+	_, err := f.SortFields()
+	if err != nil {
+		v.AddError("sort", err.Error())
 	}
-	return "ASC"
 }
 
 func (f Filters) limit() int {
@@ -33,10 +149,3 @@ func (f Filters) limit() int {
 func (f Filters) offset() int {
 	return (f.Page - 1) * f.PageSize
 }
-
-func (f Filters) sortByAwards() string {
-	if strings.HasPrefix(f.Sort, "-") {
-		return "DESC"
-	}
-	return "ASC"
-}
@@ -0,0 +1,202 @@
+package data
+
+import (
+	"testing"
+
+	"github.com/shynggys9219/greenlight/internal/validator"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSortSpecParse(t *testing.T) {
+	tests := []struct {
+		name   string
+		spec   SortSpec
+		sort   string
+		fields []SortField
+		err    string
+	}{
+		{
+			name:   "ascending",
+			spec:   MovieSort,
+			sort:   "year",
+			fields: []SortField{{Key: "year", Column: "year"}},
+		},
+		{
+			name:   "descending",
+			spec:   MovieSort,
+			sort:   "-year",
+			fields: []SortField{{Key: "year", Column: "year", Desc: true}},
+		},
+		{
+			name: "several keys",
+			spec: MovieSort,
+			sort: "-year,title,-runtime",
+			fields: []SortField{
+				{Key: "year", Column: "year", Desc: true},
+				{Key: "title", Column: "title"},
+				{Key: "runtime", Column: "runtime", Desc: true},
+			},
+		},
+		{
+			name:   "key mapped to another column",
+			spec:   DirectorSort,
+			sort:   "-name",
+			fields: []SortField{{Key: "name", Column: "direc_name", Desc: true}},
+		},
+		{
+			name: "unknown key",
+			spec: MovieSort,
+			sort: "version",
+			err:  `unknown key "version", must be one of created_at, id, runtime, title, year`,
+		},
+		{
+			name: "column name instead of key",
+			spec: DirectorSort,
+			sort: "direc_name",
+			err:  `unknown key "direc_name", must be one of awards, id, name, surname`,
+		},
+		{
+			name: "key of another resource",
+			spec: UserSort,
+			sort: "title",
+			err:  `unknown key "title", must be one of created_at, email, id`,
+		},
+		{
+			name: "tab after the minus",
+			spec: MovieSort,
+			sort: "-\tyear",
+			err:  `unknown key "\tyear", must be one of created_at, id, runtime, title, year`,
+		},
+		{
+			name: "SQL",
+			spec: MovieSort,
+			sort: "year; DROP TABLE movies",
+			err:  `unknown key "year; DROP TABLE movies", must be one of created_at, id, runtime, title, year`,
+		},
+		{
+			name: "empty",
+			spec: MovieSort,
+			sort: "",
+			err:  "must not have an empty key",
+		},
+		{
+			name: "only a minus",
+			spec: MovieSort,
+			sort: "-",
+			err:  "must not have an empty key",
+		},
+		{
+			name: "trailing comma",
+			spec: MovieSort,
+			sort: "year,",
+			err:  "must not have an empty key",
+		},
+		{
+			name: "key twice",
+			spec: MovieSort,
+			sort: "year,-year",
+			err:  `must not have the key "year" twice`,
+		},
+		{
+			name: "no spec",
+			spec: nil,
+			sort: "id",
+			err:  `unknown key "id", must be one of `,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fields, err := tt.spec.Parse(tt.sort)
+
+			if tt.err != "" {
+				require.EqualError(t, err, tt.err)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tt.fields, fields)
+		})
+	}
+}
+
+func TestFiltersOrderBy(t *testing.T) {
+	tests := []struct {
+		filters Filters
+		orderBy string
+		err     string
+	}{
+		{Filters{Sort: "id", SortSpec: MovieSort}, "id ASC", ""},
+		{Filters{Sort: "-year,title", SortSpec: MovieSort}, "year DESC, title ASC", ""},
+		{Filters{Sort: "surname,-name", SortSpec: DirectorSort}, "direc_surname ASC, direc_name DESC", ""},
+		{Filters{Sort: "-created_at", SortSpec: UserSort}, "created_at DESC", ""},
+		{Filters{Sort: "-year", SortSpec: nil}, "", `invalid sort "-year": unknown key "year", must be one of `},
+		{Filters{Sort: "name", SortSpec: MovieSort}, "", `invalid sort "name": unknown key "name", must be one of created_at, id, runtime, title, year`},
+	}
+
+	for _, tt := range tests {
+		orderBy, err := tt.filters.orderBy()
+
+		if tt.err != "" {
+			require.EqualError(t, err, tt.err, tt.filters.Sort)
+			continue
+		}
+
+		require.NoError(t, err, tt.filters.Sort)
+		require.Equal(t, tt.orderBy, orderBy, tt.filters.Sort)
+	}
+}
+
+func TestValidateSort(t *testing.T) {
+	tests := []struct {
+		filters Filters
+		valid   bool
+	}{
+		{Filters{Sort: "-year,title", SortSpec: MovieSort}, true},
+		{Filters{Sort: "-awards", SortSpec: DirectorSort}, true},
+		{Filters{Sort: "awards", SortSpec: MovieSort}, false},
+		{Filters{Sort: "title,title", SortSpec: MovieSort}, false},
+		{Filters{Sort: "", SortSpec: PersonSort}, false},
+	}
+
+	for _, tt := range tests {
+		v := validator.New()
+		ValidateSort(v, tt.filters)
+
+		require.Equal(t, tt.valid, v.Valid(), tt.filters.Sort)
+		if !tt.valid {
+			require.Contains(t, v.Errors, "sort")
+		}
+	}
+}
+
+func TestValidateFilters(t *testing.T) {
+	tests := []struct {
+		name    string
+		filters Filters
+		errors  []string
+	}{
+		{"valid", Filters{Page: 1, PageSize: 20, Sort: "id", SortSpec: MovieSort}, nil},
+		{"largest page size", Filters{Page: 3, PageSize: 100, Sort: "-awards", SortSpec: DirectorSort}, nil},
+		{"zero page", Filters{Page: 0, PageSize: 20, Sort: "id", SortSpec: MovieSort}, []string{"page"}},
+		{"negative page", Filters{Page: -1, PageSize: 20, Sort: "id", SortSpec: MovieSort}, []string{"page"}},
+		{"zero page size", Filters{Page: 1, PageSize: 0, Sort: "id", SortSpec: MovieSort}, []string{"page_size"}},
+		{"page size too large", Filters{Page: 1, PageSize: 101, Sort: "id", SortSpec: MovieSort}, []string{"page_size"}},
+		{"bad sort", Filters{Page: 1, PageSize: 20, Sort: "title", SortSpec: UserSort}, []string{"sort"}},
+		{"everything wrong", Filters{Page: 0, PageSize: 1000, Sort: "", SortSpec: PersonSort}, []string{"page", "page_size", "sort"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := validator.New()
+			ValidateFilters(v, tt.filters)
+
+			keys := []string{}
+			for key := range v.Errors {
+				keys = append(keys, key)
+			}
+
+			require.ElementsMatch(t, tt.errors, keys)
+		})
+	}
+}
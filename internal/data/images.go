@@ -0,0 +1,197 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	ImagePoster = "poster"
+	ImageStill  = "still"
+)
+
+// Image is the metadata of an uploaded poster or still. The blobs themselves live
+// in storage under Key (the original) and ThumbnailKey(width) for each thumbnail.
+// URL and Thumbnails are filled in by the API from the configured storage.
+type Image struct {
+	ID              int64             `json:"id"`
+	CreatedAt       time.Time         `json:"created_at"`
+	MovieID         int64             `json:"movie_id"`
+	Kind            string            `json:"kind"`
+	Key             string            `json:"-"`
+	ContentType     string            `json:"content_type"`
+	Width           int32             `json:"width"`
+	Height          int32             `json:"height"`
+	Size            int64             `json:"size"`
+	ThumbnailWidths []int64           `json:"-"`
+	URL             string            `json:"url,omitempty"`
+	Thumbnails      map[string]string `json:"thumbnails,omitempty"`
+}
+
+// ThumbnailKey returns the storage key of the thumbnail with the given width,
+// e.g. "movies/1/ab12.png" -> "movies/1/ab12_w185.jpg". Thumbnails are always JPEG.
+func (i *Image) ThumbnailKey(width int64) string {
+	key := i.Key
+	if dot := strings.LastIndex(key, "."); dot > strings.LastIndex(key, "/") {
+		key = key[:dot]
+	}
+	return fmt.Sprintf("%s_w%d.jpg", key, width)
+}
+
+type ImageModel struct {
+	DB querier
+}
+
+func (m ImageModel) Insert(ctx context.Context, image *Image) error {
+	query := `
+		INSERT INTO images (movie_id, kind, storage_key, content_type, width, height, size_bytes, thumbnail_widths)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id, created_at`
+
+	args := []any{
+		image.MovieID,
+		image.Kind,
+		image.Key,
+		image.ContentType,
+		image.Width,
+		image.Height,
+		image.Size,
+		pq.Array(image.ThumbnailWidths),
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&image.ID, &image.CreatedAt)
+	if err != nil {
+		switch {
+		case err.Error() == `pq: insert or update on table "images" violates foreign key constraint "images_movie_id_fkey"`:
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m ImageModel) Get(ctx context.Context, movieID, id int64) (*Image, error) {
+	if movieID < 1 || id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+		SELECT id, created_at, movie_id, kind, storage_key, content_type, width, height, size_bytes, thumbnail_widths
+		FROM images
+		WHERE movie_id = $1 AND id = $2`
+
+	var image Image
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, movieID, id).Scan(
+		&image.ID,
+		&image.CreatedAt,
+		&image.MovieID,
+		&image.Kind,
+		&image.Key,
+		&image.ContentType,
+		&image.Width,
+		&image.Height,
+		&image.Size,
+		pq.Array(&image.ThumbnailWidths),
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &image, nil
+}
+
+func (m ImageModel) Delete(ctx context.Context, movieID, id int64) error {
+	if movieID < 1 || id < 1 {
+		return ErrRecordNotFound
+	}
+
+	query := `
+		DELETE FROM images
+		WHERE movie_id = $1 AND id = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, movieID, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// GetAllForMovies loads the images of several movies in one query, keyed by movie id.
+func (m ImageModel) GetAllForMovies(ctx context.Context, movieIDs []int64) (map[int64][]*Image, error) {
+	query := `
+		SELECT id, created_at, movie_id, kind, storage_key, content_type, width, height, size_bytes, thumbnail_widths
+		FROM images
+		WHERE movie_id = ANY($1)
+		ORDER BY movie_id ASC, kind ASC, id ASC`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, pq.Array(movieIDs))
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	images := make(map[int64][]*Image)
+
+	for rows.Next() {
+		var image Image
+		err := rows.Scan(
+			&image.ID,
+			&image.CreatedAt,
+			&image.MovieID,
+			&image.Kind,
+			&image.Key,
+			&image.ContentType,
+			&image.Width,
+			&image.Height,
+			&image.Size,
+			pq.Array(&image.ThumbnailWidths),
+		)
+		if err != nil {
+			return nil, err
+		}
+		images[image.MovieID] = append(images[image.MovieID], &image)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return images, nil
+}
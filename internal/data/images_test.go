@@ -0,0 +1,27 @@
+package data
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestThumbnailKey(t *testing.T) {
+	tests := []struct {
+		key   string
+		width int64
+		want  string
+	}{
+		{"movies/1/ab12.png", 185, "movies/1/ab12_w185.jpg"},
+		{"movies/1/ab12.jpg", 500, "movies/1/ab12_w500.jpg"},
+		{"movies/1/ab12", 92, "movies/1/ab12_w92.jpg"},
+		{"movies/1.d/ab12", 92, "movies/1.d/ab12_w92.jpg"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			img := &Image{Key: tt.key}
+			require.Equal(t, tt.want, img.ThumbnailKey(tt.width))
+		})
+	}
+}
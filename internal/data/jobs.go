@@ -0,0 +1,73 @@
+package data
+
+import (
+	"context"
+	"time"
+)
+
+// JobRun is one run of a scheduled background job.
+type JobRun struct {
+	ID         int64     `json:"id"`
+	Job        string    `json:"job"`
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+	Affected   int64     `json:"affected"` // rows the job deleted or changed
+	Error      string    `json:"error,omitempty"`
+}
+
+type JobRunModel struct {
+	DB querier
+}
+
+func (m JobRunModel) Insert(ctx context.Context, run *JobRun) error {
+	query := `
+		INSERT INTO job_runs (job, started_at, finished_at, affected, error)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id`
+
+	args := []any{run.Job, run.StartedAt, run.FinishedAt, run.Affected, run.Error}
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	return m.DB.QueryRowContext(ctx, query, args...).Scan(&run.ID)
+}
+
+// GetAll lists runs newest first, only those of one job unless job is empty.
+func (m JobRunModel) GetAll(ctx context.Context, job string, filters Filters) ([]*JobRun, error) {
+	query := `
+		SELECT id, job, started_at, finished_at, affected, error
+		FROM job_runs
+		WHERE (job = $1 OR $1 = '')
+		ORDER BY id DESC
+		LIMIT $2 OFFSET $3`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, job, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	runs := []*JobRun{}
+
+	for rows.Next() {
+		var run JobRun
+
+		err := rows.Scan(&run.ID, &run.Job, &run.StartedAt, &run.FinishedAt, &run.Affected, &run.Error)
+		if err != nil {
+			return nil, err
+		}
+
+		runs = append(runs, &run)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return runs, nil
+}
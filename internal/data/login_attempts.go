@@ -0,0 +1,99 @@
+package data
+
+import (
+	"context"
+	"time"
+)
+
+// LoginAttempt is one credential check against POST /v1/tokens/authentication.
+type LoginAttempt struct {
+	ID        int64     `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	Email     string    `json:"email"`
+	IP        string    `json:"ip"`
+	Succeeded bool      `json:"succeeded"`
+}
+
+// LoginFailures summarizes recent failed attempts.
+type LoginFailures struct {
+	Count int
+	Last  time.Time // time of the most recent failure, zero when Count is 0
+}
+
+type LoginAttemptModel struct {
+	DB querier
+}
+
+func (m LoginAttemptModel) Insert(ctx context.Context, attempt *LoginAttempt) error {
+	query := `
+		INSERT INTO login_attempts (email, ip, succeeded)
+		VALUES ($1, $2, $3)
+		RETURNING id, created_at`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	return m.DB.QueryRowContext(ctx, query, attempt.Email, attempt.IP, attempt.Succeeded).Scan(&attempt.ID, &attempt.CreatedAt)
+}
+
+// FailuresForEmail counts failed attempts for an account since the given time. A
+// successful login resets the count.
+func (m LoginAttemptModel) FailuresForEmail(ctx context.Context, email string, since time.Time) (LoginFailures, error) {
+	query := `
+		SELECT count(*), COALESCE(max(created_at), 'epoch')
+		FROM login_attempts
+		WHERE email = $1 AND NOT succeeded
+		AND created_at > GREATEST($2, (
+			SELECT COALESCE(max(created_at), 'epoch')
+			FROM login_attempts
+			WHERE email = $1 AND succeeded))`
+
+	return m.failures(ctx, query, email, since)
+}
+
+// FailuresForIP counts failed attempts from a client address since the given time,
+// whatever account they were for.
+func (m LoginAttemptModel) FailuresForIP(ctx context.Context, ip string, since time.Time) (LoginFailures, error) {
+	query := `
+		SELECT count(*), COALESCE(max(created_at), 'epoch')
+		FROM login_attempts
+		WHERE ip = $1 AND NOT succeeded AND created_at > $2`
+
+	return m.failures(ctx, query, ip, since)
+}
+
+func (m LoginAttemptModel) failures(ctx context.Context, query, key string, since time.Time) (LoginFailures, error) {
+	var failures LoginFailures
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, key, since).Scan(&failures.Count, &failures.Last)
+	if err != nil {
+		return LoginFailures{}, err
+	}
+
+	if failures.Count == 0 {
+		failures.Last = time.Time{}
+	}
+
+	return failures, nil
+}
+
+// ClearEmail forgets the failed attempts for an account, e.g. after it was unlocked.
+func (m LoginAttemptModel) ClearEmail(ctx context.Context, email string) error {
+	return m.clear(ctx, `DELETE FROM login_attempts WHERE email = $1 AND NOT succeeded`, email)
+}
+
+// ClearIP forgets the failed attempts from a client address.
+func (m LoginAttemptModel) ClearIP(ctx context.Context, ip string) error {
+	return m.clear(ctx, `DELETE FROM login_attempts WHERE ip = $1 AND NOT succeeded`, ip)
+}
+
+func (m LoginAttemptModel) clear(ctx context.Context, query, key string) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, key)
+	return err
+}
@@ -0,0 +1,101 @@
+package memstore
+
+import (
+	"context"
+	"crypto/sha256"
+	"sort"
+	"time"
+
+	"github.com/shynggys9219/greenlight/internal/data"
+)
+
+type apiKeyStore struct {
+	s *Store
+}
+
+// copyAPIKey copies what the api_keys table holds; the plaintext is never stored.
+func copyAPIKey(key *data.APIKey) *data.APIKey {
+	c := *key
+	c.Plaintext = ""
+	c.Hash = nil
+	c.Permissions = copyStrings(key.Permissions)
+	if key.Expiry != nil {
+		expiry := *key.Expiry
+		c.Expiry = &expiry
+	}
+	if key.LastUsedAt != nil {
+		lastUsedAt := *key.LastUsedAt
+		c.LastUsedAt = &lastUsedAt
+	}
+	return &c
+}
+
+// Insert generates the key and stores it, like APIKeyModel.Insert.
+func (m apiKeyStore) Insert(ctx context.Context, key *data.APIKey) error {
+	err := data.GenerateAPIKey(key)
+	if err != nil {
+		return err
+	}
+
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	key.ID = m.s.nextID("api_keys")
+	key.CreatedAt = time.Now()
+
+	stored := copyAPIKey(key)
+	stored.Hash = append([]byte(nil), key.Hash...)
+	m.s.apiKeys[key.ID] = stored
+
+	return nil
+}
+
+func (m apiKeyStore) GetForPlaintext(ctx context.Context, plaintext string) (*data.APIKey, error) {
+	hash := sha256.Sum256([]byte(plaintext))
+
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	now := time.Now()
+
+	for _, key := range m.s.apiKeys {
+		if string(key.Hash) == string(hash[:]) && (key.Expiry == nil || key.Expiry.After(now)) {
+			key.LastUsedAt = &now
+			return copyAPIKey(key), nil
+		}
+	}
+
+	return nil, data.ErrRecordNotFound
+}
+
+// GetAllForUser lists the user's keys, expired ones included, newest first.
+func (m apiKeyStore) GetAllForUser(ctx context.Context, userID int64) ([]*data.APIKey, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	keys := []*data.APIKey{}
+
+	for _, key := range m.s.apiKeys {
+		if key.UserID == userID {
+			keys = append(keys, copyAPIKey(key))
+		}
+	}
+
+	sort.Slice(keys, func(i, j int) bool { return keys[i].ID > keys[j].ID })
+
+	return keys, nil
+}
+
+func (m apiKeyStore) Delete(ctx context.Context, id, userID int64) error {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	key, ok := m.s.apiKeys[id]
+	if !ok || key.UserID != userID {
+		return data.ErrRecordNotFound
+	}
+
+	delete(m.s.apiKeys, id)
+
+	return nil
+}
@@ -0,0 +1,440 @@
+package memstore_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shynggys9219/greenlight/internal/data"
+	"github.com/shynggys9219/greenlight/internal/data/memstore"
+	"github.com/shynggys9219/greenlight/internal/testdb"
+	"github.com/stretchr/testify/require"
+)
+
+// The conformance tests run the same checks against the memstore and the PostgreSQL
+// models, so that handler tests on the memstore stay meaningful. The PostgreSQL runs
+// are skipped when there is no test database.
+
+// forEachBackend runs test as a subtest on empty models of every backend.
+func forEachBackend(t *testing.T, test func(t *testing.T, models data.Models)) {
+	t.Run("memstore", func(t *testing.T) {
+		test(t, memstore.New().Models())
+	})
+	t.Run("postgres", func(t *testing.T) {
+		test(t, data.NewModels(testdb.Open(t)))
+	})
+}
+
+func TestMovieConformance(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, models data.Models) {
+		ctx := context.Background()
+
+		movie := testdb.Movie(t, models, func(m *data.Movie) { m.Title = "Alien"; m.Genres = []string{"horror", "sci-fi"} })
+		require.NotZero(t, movie.ID)
+		require.NotZero(t, movie.CreatedAt)
+		require.Equal(t, int32(1), movie.Version)
+
+		stored, err := models.Movies.Get(ctx, movie.ID)
+		require.NoError(t, err)
+		require.Equal(t, movie.Title, stored.Title)
+		require.Equal(t, movie.Year, stored.Year)
+		require.Equal(t, movie.Runtime, stored.Runtime)
+		require.Equal(t, movie.Genres, stored.Genres)
+		require.Equal(t, movie.Version, stored.Version)
+
+		for _, id := range []int64{0, -1, movie.ID + 1000} {
+			_, err = models.Movies.Get(ctx, id)
+			require.ErrorIs(t, err, data.ErrRecordNotFound, "id %d", id)
+		}
+
+		stale := *stored
+		stored.Title = "Aliens"
+		require.NoError(t, models.Movies.Update(ctx, stored))
+		require.Equal(t, int32(2), stored.Version)
+
+		stale.Title = "Alien 3"
+		require.ErrorIs(t, models.Movies.Update(ctx, &stale), data.ErrEditConflict)
+
+		missing := *stored
+		missing.ID += 1000
+		require.ErrorIs(t, models.Movies.Update(ctx, &missing), data.ErrEditConflict)
+
+		byTitle, err := models.Movies.GetByTitle(ctx, "Aliens")
+		require.NoError(t, err)
+		require.Equal(t, movie.ID, byTitle.ID)
+		require.Equal(t, int32(2), byTitle.Version)
+
+		_, err = models.Movies.GetByTitle(ctx, "Alien")
+		require.ErrorIs(t, err, data.ErrRecordNotFound)
+
+		testdb.Movie(t, models, func(m *data.Movie) { m.Title = "The Thing" })
+
+		movies, err := models.Movies.GetAllMovies(ctx)
+		require.NoError(t, err)
+		require.Len(t, movies, 2)
+
+		require.NoError(t, models.Movies.Delete(ctx, movie.ID))
+		require.ErrorIs(t, models.Movies.Delete(ctx, movie.ID), data.ErrRecordNotFound)
+		require.ErrorIs(t, models.Movies.Delete(ctx, 0), data.ErrRecordNotFound)
+
+		require.ErrorIs(t, models.Movies.DeleteByTitle(ctx, "Aliens"), data.ErrRecordNotFound)
+		require.NoError(t, models.Movies.DeleteByTitle(ctx, "The Thing"))
+
+		movies, err = models.Movies.GetAllMovies(ctx)
+		require.NoError(t, err)
+		require.Empty(t, movies)
+	})
+}
+
+func TestMovieFilterConformance(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, models data.Models) {
+		movie := func(title string, year, runtime int32, genres ...string) {
+			testdb.Movie(t, models, func(movie *data.Movie) {
+				movie.Title = title
+				movie.Year = year
+				movie.Runtime = runtime
+				movie.Genres = genres
+			})
+		}
+
+		movie("Alien", 1979, 117, "horror", "sci-fi")
+		movie("The Thing", 1982, 109, "horror", "sci-fi")
+		movie("Blade Runner", 1982, 117, "sci-fi", "drama")
+		movie("Notting Hill", 1999, 124, "romance", "comedy")
+		movie("Amelie", 2001, 122, "comedy")
+
+		tests := []struct {
+			filter   data.MovieFilter
+			sort     string
+			page     int
+			pageSize int
+			titles   []string
+		}{
+			{data.MovieFilter{}, "-year,title", 1, 20, []string{"Amelie", "Notting Hill", "Blade Runner", "The Thing", "Alien"}},
+			{data.MovieFilter{}, "title", 2, 2, []string{"Blade Runner", "Notting Hill"}},
+			{data.MovieFilter{}, "title", 4, 2, []string{}},
+			{data.MovieFilter{Title: "blade"}, "id", 1, 20, []string{"Blade Runner"}},
+			{data.MovieFilter{Title: "THE thing"}, "id", 1, 20, []string{"The Thing"}},
+			{data.MovieFilter{YearMin: 1980, YearMax: 1999}, "title", 1, 20, []string{"Blade Runner", "Notting Hill", "The Thing"}},
+			{data.MovieFilter{RuntimeMin: 110, RuntimeMax: 120}, "id", 1, 20, []string{"Alien", "Blade Runner"}},
+			{data.MovieFilter{Genres: []string{"horror", "drama"}, GenresMode: data.GenresAny}, "id", 1, 20, []string{"Alien", "The Thing", "Blade Runner"}},
+			{data.MovieFilter{Genres: []string{"horror", "sci-fi"}}, "id", 1, 20, []string{"Alien", "The Thing"}},
+			{data.MovieFilter{Genres: []string{"sci-fi"}, GenresMode: data.GenresNone}, "id", 1, 20, []string{"Notting Hill", "Amelie"}},
+			{data.MovieFilter{Genres: []string{"sci-fi"}, ExcludeGenres: []string{"horror"}}, "id", 1, 20, []string{"Blade Runner"}},
+			{data.MovieFilter{CreatedBefore: time.Now().Add(-time.Hour)}, "id", 1, 20, []string{}},
+			{data.MovieFilter{}, "-runtime,-year,title", 1, 20, []string{"Notting Hill", "Amelie", "Blade Runner", "Alien", "The Thing"}},
+		}
+
+		for _, tt := range tests {
+			movies, err := models.Movies.GetAll(context.Background(), tt.filter, data.Filters{
+				Page:     tt.page,
+				PageSize: tt.pageSize,
+				Sort:     tt.sort,
+				SortSpec: data.MovieSort,
+			})
+			require.NoError(t, err)
+
+			titles := []string{}
+			for _, movie := range movies {
+				titles = append(titles, movie.Title)
+			}
+			require.Equal(t, tt.titles, titles, "%+v sorted by %s, page %d of %d", tt.filter, tt.sort, tt.page, tt.pageSize)
+		}
+
+		_, err := models.Movies.GetAll(context.Background(), data.MovieFilter{}, data.Filters{
+			Page:     1,
+			PageSize: 20,
+			Sort:     "version",
+			SortSpec: data.MovieSort,
+		})
+		require.Error(t, err)
+	})
+}
+
+func TestUserConformance(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, models data.Models) {
+		ctx := context.Background()
+
+		alice := testdb.User(t, models, func(u *data.User) { u.Email = "alice@example.com" })
+		require.NotZero(t, alice.ID)
+		require.Equal(t, 1, alice.Version)
+
+		bob := testdb.User(t, models, func(u *data.User) { u.Email = "bob@example.com"; u.Activated = false })
+
+		duplicate := &data.User{Name: "Alice", Email: "ALICE@example.com", Locale: "en"}
+		require.NoError(t, duplicate.Password.Set(testdb.FixturePassword))
+		require.ErrorIs(t, models.User.Insert(ctx, duplicate), data.ErrDuplicateEmail)
+
+		byEmail, err := models.User.GetByEmain(ctx, "Alice@Example.com")
+		require.NoError(t, err)
+		require.Equal(t, alice.ID, byEmail.ID)
+
+		_, err = models.User.GetByEmain(ctx, "carol@example.com")
+		require.ErrorIs(t, err, data.ErrRecordNotFound)
+		_, err = models.User.Get(ctx, bob.ID+1000)
+		require.ErrorIs(t, err, data.ErrRecordNotFound)
+
+		stored, err := models.User.Get(ctx, alice.ID)
+		require.NoError(t, err)
+		require.Equal(t, alice.Email, stored.Email)
+		require.True(t, stored.Activated)
+
+		stale := *stored
+		stored.Name = "Alice Liddell"
+		require.NoError(t, models.User.Update(ctx, stored))
+		require.Equal(t, 2, stored.Version)
+		require.ErrorIs(t, models.User.Update(ctx, &stale), data.ErrEditConflict)
+
+		stored.Email = "BOB@example.com"
+		require.ErrorIs(t, models.User.Update(ctx, stored), data.ErrDuplicateEmail)
+		stored.Email = alice.Email
+
+		activated := false
+		for _, tt := range []struct {
+			filter data.UserFilter
+			emails []string
+		}{
+			{data.UserFilter{}, []string{"alice@example.com", "bob@example.com"}},
+			{data.UserFilter{Email: "BOB"}, []string{"bob@example.com"}},
+			{data.UserFilter{Activated: &activated}, []string{"bob@example.com"}},
+			{data.UserFilter{CreatedBefore: time.Now().Add(-time.Hour)}, []string{}},
+		} {
+			users, err := models.User.GetAll(ctx, tt.filter, data.Filters{Page: 1, PageSize: 20, Sort: "email", SortSpec: data.UserSort})
+			require.NoError(t, err)
+
+			emails := []string{}
+			for _, user := range users {
+				emails = append(emails, user.Email)
+			}
+			require.Equal(t, tt.emails, emails, "%+v", tt.filter)
+		}
+
+		token := testdb.Token(t, models, stored, data.ScopeAuthentication)
+		_, err = models.Role.NewRole(ctx, 0, data.RoleAdmin, stored.ID)
+		require.NoError(t, err)
+
+		byToken, err := models.User.GetForToken(ctx, data.ScopeAuthentication, token)
+		require.NoError(t, err)
+		require.Equal(t, stored.ID, byToken.ID)
+
+		require.ErrorIs(t, models.User.Delete(ctx, &stale), data.ErrEditConflict)
+		require.NoError(t, models.User.Delete(ctx, stored))
+
+		// Deleting a user deletes its tokens and roles too.
+		_, err = models.User.Get(ctx, stored.ID)
+		require.ErrorIs(t, err, data.ErrRecordNotFound)
+		_, err = models.User.GetForToken(ctx, data.ScopeAuthentication, token)
+		require.ErrorIs(t, err, data.ErrRecordNotFound)
+
+		roles, err := models.Role.GetAllForUser(ctx, stored.ID)
+		require.NoError(t, err)
+		require.Empty(t, roles)
+	})
+}
+
+func TestTokenConformance(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, models data.Models) {
+		ctx := context.Background()
+
+		user := testdb.User(t, models)
+
+		authentication := testdb.Token(t, models, user, data.ScopeAuthentication)
+		activation := testdb.Token(t, models, user, data.ScopeActivation)
+
+		expired, err := models.Token.New(ctx, user.ID, -time.Minute, data.ScopeAuthentication)
+		require.NoError(t, err)
+
+		forToken := func(scope, plaintext string) error {
+			_, err := models.User.GetForToken(ctx, scope, plaintext)
+			return err
+		}
+
+		require.NoError(t, forToken(data.ScopeAuthentication, authentication))
+		require.NoError(t, forToken(data.ScopeActivation, activation))
+		require.ErrorIs(t, forToken(data.ScopeActivation, authentication), data.ErrRecordNotFound)
+		require.ErrorIs(t, forToken(data.ScopeAuthentication, expired.Plaintext), data.ErrRecordNotFound)
+		require.ErrorIs(t, forToken(data.ScopeAuthentication, strings.Repeat("A", 26)), data.ErrRecordNotFound)
+
+		deleted, err := models.Token.DeleteExpired(ctx)
+		require.NoError(t, err)
+		require.Equal(t, int64(1), deleted)
+
+		// An empty scope deletes nothing.
+		require.NoError(t, models.Token.DeleteAllForUser(ctx, "", user.ID))
+		require.NoError(t, forToken(data.ScopeAuthentication, authentication))
+
+		require.NoError(t, models.Token.DeleteAllForUser(ctx, data.ScopeAuthentication, user.ID))
+		require.ErrorIs(t, forToken(data.ScopeAuthentication, authentication), data.ErrRecordNotFound)
+		require.NoError(t, forToken(data.ScopeActivation, activation))
+	})
+}
+
+func TestRoleConformance(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, models data.Models) {
+		ctx := context.Background()
+
+		user := testdb.User(t, models)
+		other := testdb.User(t, models)
+
+		roles, err := models.Role.GetAllForUser(ctx, user.ID)
+		require.NoError(t, err)
+		require.Equal(t, []string{}, roles)
+
+		admin, err := models.Role.NewRole(ctx, 0, data.RoleAdmin, user.ID)
+		require.NoError(t, err)
+		require.NotZero(t, admin.ID)
+
+		moderator := &data.Role{RoleName: "moderator", UserID: user.ID}
+		require.NoError(t, models.Role.Insert(ctx, moderator))
+		require.NotZero(t, moderator.ID)
+		require.NotEqual(t, admin.ID, moderator.ID)
+
+		// Granting a role twice lists it once.
+		require.NoError(t, models.Role.InsertUserRole(ctx, &data.Role{RoleName: data.RoleAdmin, UserID: user.ID}))
+
+		roles, err = models.Role.GetAllForUser(ctx, user.ID)
+		require.NoError(t, err)
+		require.Equal(t, []string{data.RoleAdmin, "moderator"}, roles)
+
+		roles, err = models.Role.GetAllForUser(ctx, other.ID)
+		require.NoError(t, err)
+		require.Empty(t, roles)
+
+		unknown := &data.Role{RoleName: data.RoleAdmin, UserID: other.ID + 1000}
+		require.ErrorIs(t, models.Role.Insert(ctx, unknown), data.ErrUnknownUser)
+	})
+}
+
+func TestMovieTitleConformance(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, models data.Models) {
+		ctx := context.Background()
+
+		movie := testdb.Movie(t, models, func(m *data.Movie) { m.Title = "Alien" })
+
+		original := &data.MovieTitle{MovieID: movie.ID, Language: "en", Title: "Alien", IsOriginal: true}
+		require.NoError(t, models.Titles.Upsert(ctx, original))
+		require.Equal(t, int32(1), original.Version)
+
+		russian := &data.MovieTitle{MovieID: movie.ID, Language: "ru", Title: "Чужой"}
+		require.NoError(t, models.Titles.Upsert(ctx, russian))
+
+		second := &data.MovieTitle{MovieID: movie.ID, Language: "de", Title: "Alien", IsOriginal: true}
+		require.ErrorIs(t, models.Titles.Upsert(ctx, second), data.ErrDuplicateOriginalTitle)
+
+		missing := &data.MovieTitle{MovieID: movie.ID + 1000, Language: "ru", Title: "Чужой"}
+		require.ErrorIs(t, models.Titles.Upsert(ctx, missing), data.ErrRecordNotFound)
+
+		russian.Title = "Чужой 2"
+		require.NoError(t, models.Titles.Upsert(ctx, russian))
+		require.Equal(t, int32(2), russian.Version)
+
+		titles, err := models.Titles.GetAllForMovie(ctx, movie.ID)
+		require.NoError(t, err)
+		require.Len(t, titles, 2)
+		require.Equal(t, "en", titles[0].Language)
+		require.Equal(t, "Чужой 2", titles[1].Title)
+
+		movies, err := models.Movies.GetAll(ctx, data.MovieFilter{Title: "чужой"}, data.Filters{Page: 1, PageSize: 20, Sort: "id", SortSpec: data.MovieSort})
+		require.NoError(t, err)
+		require.Len(t, movies, 1)
+
+		require.NoError(t, models.Titles.Delete(ctx, movie.ID, "ru"))
+		require.ErrorIs(t, models.Titles.Delete(ctx, movie.ID, "ru"), data.ErrRecordNotFound)
+
+		// Deleting the movie deletes its titles.
+		require.NoError(t, models.Movies.Delete(ctx, movie.ID))
+		titles, err = models.Titles.GetAllForMovie(ctx, movie.ID)
+		require.NoError(t, err)
+		require.Empty(t, titles)
+	})
+}
+
+func TestSessionConformance(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, models data.Models) {
+		ctx := context.Background()
+
+		user := testdb.User(t, models)
+
+		session := &data.Session{UserID: user.ID, UserAgent: "test", IP: "192.0.2.1"}
+		access, refresh, err := models.Sessions.New(ctx, session, time.Hour, 24*time.Hour)
+		require.NoError(t, err)
+		require.NotZero(t, session.ID)
+
+		found, err := models.Sessions.GetForToken(ctx, access.Plaintext)
+		require.NoError(t, err)
+		require.Equal(t, session.ID, found.ID)
+
+		_, err = models.Sessions.GetForToken(ctx, testdb.Token(t, models, user, data.ScopeAuthentication))
+		require.ErrorIs(t, err, data.ErrRecordNotFound)
+
+		sessions, err := models.Sessions.GetAllForUser(ctx, user.ID)
+		require.NoError(t, err)
+		require.Len(t, sessions, 1)
+
+		refreshed, newAccess, _, err := models.Sessions.Refresh(ctx, refresh.Plaintext, "other", "192.0.2.2", time.Hour, 24*time.Hour)
+		require.NoError(t, err)
+		require.Equal(t, session.ID, refreshed.ID)
+		require.Equal(t, "other", refreshed.UserAgent)
+
+		_, _, _, err = models.Sessions.Refresh(ctx, refresh.Plaintext, "", "", time.Hour, 24*time.Hour)
+		require.ErrorIs(t, err, data.ErrTokenReused)
+
+		// Reuse revoked the session and every token it had.
+		_, err = models.Sessions.GetForToken(ctx, newAccess.Plaintext)
+		require.ErrorIs(t, err, data.ErrRecordNotFound)
+		_, err = models.User.GetForToken(ctx, data.ScopeAuthentication, access.Plaintext)
+		require.ErrorIs(t, err, data.ErrRecordNotFound)
+		require.ErrorIs(t, models.Sessions.Delete(ctx, session.ID, user.ID), data.ErrRecordNotFound)
+
+		other := &data.Session{UserID: user.ID}
+		_, _, err = models.Sessions.New(ctx, other, 0, 24*time.Hour)
+		require.NoError(t, err)
+		require.ErrorIs(t, models.Sessions.Delete(ctx, other.ID, user.ID+1000), data.ErrRecordNotFound)
+		require.NoError(t, models.Sessions.Delete(ctx, other.ID, user.ID))
+	})
+}
+
+func TestOutboxConformance(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, models data.Models) {
+		ctx := context.Background()
+
+		email := &data.OutboxEmail{Recipient: "outbox@example.com", Template: "user_welcome", Data: map[string]any{"userID": int64(1234567)}}
+		require.NoError(t, models.Outbox.Enqueue(ctx, email))
+		require.Equal(t, data.OutboxPending, email.Status)
+		require.Equal(t, "en", email.Locale)
+
+		// Numbers come back the way templates should print them.
+		stored, err := models.Outbox.Get(ctx, email.ID)
+		require.NoError(t, err)
+		require.Equal(t, "1234567", fmt.Sprint(stored.Data["userID"]))
+
+		claimed, err := models.Outbox.Claim(ctx, 10, time.Minute)
+		require.NoError(t, err)
+		require.Len(t, claimed, 1)
+		require.Equal(t, data.OutboxSending, claimed[0].Status)
+
+		// The worker holding the lease owns the email until it reports back.
+		sending := *claimed[0]
+		require.ErrorIs(t, models.Outbox.Requeue(ctx, &sending), data.ErrEditConflict)
+
+		require.NoError(t, models.Outbox.MarkFailed(ctx, claimed[0], errors.New("connection refused"), time.Now().Add(time.Hour)))
+		require.Equal(t, data.OutboxPending, claimed[0].Status)
+
+		require.NoError(t, models.Outbox.Requeue(ctx, claimed[0]))
+		require.Equal(t, int32(0), claimed[0].Attempts)
+
+		claimed, err = models.Outbox.Claim(ctx, 10, time.Minute)
+		require.NoError(t, err)
+		require.Len(t, claimed, 1)
+		require.NoError(t, models.Outbox.MarkSent(ctx, claimed[0]))
+		require.ErrorIs(t, models.Outbox.Requeue(ctx, claimed[0]), data.ErrEditConflict)
+
+		stored, err = models.Outbox.Get(ctx, email.ID)
+		require.NoError(t, err)
+		require.Equal(t, data.OutboxSent, stored.Status)
+		require.Empty(t, stored.Data)
+	})
+}
@@ -0,0 +1,169 @@
+package memstore
+
+import (
+	"context"
+	"sort"
+
+	"github.com/shynggys9219/greenlight/internal/data"
+)
+
+type creditStore struct {
+	s *Store
+}
+
+// check returns the error the foreign keys and the unique constraint of the credits
+// table give for storing credit, or nil; the caller holds the lock.
+func (m creditStore) check(credit *data.Credit) error {
+	if _, ok := m.s.movies[credit.MovieID]; !ok {
+		return data.ErrRecordNotFound
+	}
+
+	if _, ok := m.s.people[credit.PersonID]; !ok {
+		return data.ErrUnknownPerson
+	}
+
+	for _, other := range m.s.credits {
+		if other.ID != credit.ID && other.MovieID == credit.MovieID && other.PersonID == credit.PersonID &&
+			other.Role == credit.Role && other.CharacterName == credit.CharacterName {
+			return data.ErrDuplicateCredit
+		}
+	}
+
+	return nil
+}
+
+// withName returns a copy of the credit with the person's name, as the models read it
+// back; the caller holds the lock.
+func (m creditStore) withName(credit *data.Credit) *data.Credit {
+	c := *credit
+	c.PersonName = m.s.people[credit.PersonID].Name
+	return &c
+}
+
+func (m creditStore) Insert(ctx context.Context, credit *data.Credit) error {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	if err := m.check(credit); err != nil {
+		return err
+	}
+
+	credit.ID = m.s.nextID("credits")
+	credit.Version = 1
+
+	stored := *credit
+	stored.PersonName = ""
+	m.s.credits[credit.ID] = &stored
+
+	return nil
+}
+
+func (m creditStore) Get(ctx context.Context, movieID, id int64) (*data.Credit, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	credit, ok := m.s.credits[id]
+	if !ok || credit.MovieID != movieID {
+		return nil, data.ErrRecordNotFound
+	}
+
+	return m.withName(credit), nil
+}
+
+func (m creditStore) Update(ctx context.Context, credit *data.Credit) error {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	stored, ok := m.s.credits[credit.ID]
+	if !ok || stored.MovieID != credit.MovieID || stored.Version != credit.Version {
+		return data.ErrEditConflict
+	}
+
+	if err := m.check(credit); err != nil {
+		return err
+	}
+
+	credit.Version++
+	stored.PersonID = credit.PersonID
+	stored.Role = credit.Role
+	stored.CharacterName = credit.CharacterName
+	stored.BillingOrder = credit.BillingOrder
+	stored.Version = credit.Version
+
+	return nil
+}
+
+func (m creditStore) Delete(ctx context.Context, movieID, id int64) error {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	credit, ok := m.s.credits[id]
+	if !ok || credit.MovieID != movieID {
+		return data.ErrRecordNotFound
+	}
+
+	delete(m.s.credits, id)
+
+	return nil
+}
+
+func (m creditStore) GetAllForMovie(ctx context.Context, movieID int64) ([]*data.Credit, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	credits := []*data.Credit{}
+
+	for _, credit := range m.s.credits {
+		if credit.MovieID == movieID {
+			credits = append(credits, m.withName(credit))
+		}
+	}
+
+	sort.Slice(credits, func(i, j int) bool {
+		if credits[i].BillingOrder != credits[j].BillingOrder {
+			return credits[i].BillingOrder < credits[j].BillingOrder
+		}
+		return credits[i].ID < credits[j].ID
+	})
+
+	return credits, nil
+}
+
+func (m creditStore) GetFilmography(ctx context.Context, personID int64) ([]*data.FilmographyEntry, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	entries := []*data.FilmographyEntry{}
+
+	for _, credit := range m.s.credits {
+		if credit.PersonID != personID {
+			continue
+		}
+
+		movie := m.s.movies[credit.MovieID]
+		entries = append(entries, &data.FilmographyEntry{
+			CreditID:      credit.ID,
+			MovieID:       movie.ID,
+			Title:         movie.Title,
+			Year:          movie.Year,
+			Role:          credit.Role,
+			CharacterName: credit.CharacterName,
+			BillingOrder:  credit.BillingOrder,
+		})
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		switch {
+		case a.Year != b.Year:
+			return a.Year > b.Year
+		case a.MovieID != b.MovieID:
+			return a.MovieID < b.MovieID
+		case a.BillingOrder != b.BillingOrder:
+			return a.BillingOrder < b.BillingOrder
+		}
+		return a.CreditID < b.CreditID
+	})
+
+	return entries, nil
+}
@@ -0,0 +1,102 @@
+package memstore
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/shynggys9219/greenlight/internal/data"
+)
+
+type directorStore struct {
+	s *Store
+}
+
+func copyDirector(director *data.Director) *data.Director {
+	c := *director
+	c.Awards = copyStrings(director.Awards)
+	return &c
+}
+
+func (d directorStore) Insert(ctx context.Context, director *data.Director) error {
+	d.s.mu.Lock()
+	defer d.s.mu.Unlock()
+
+	director.ID = d.s.nextID("directors")
+	d.s.directors[director.ID] = copyDirector(director)
+
+	return nil
+}
+
+func (d directorStore) Get(ctx context.Context, id int64) (*data.Director, error) {
+	d.s.mu.Lock()
+	defer d.s.mu.Unlock()
+
+	director, ok := d.s.directors[id]
+	if !ok {
+		return nil, data.ErrRecordNotFound
+	}
+
+	return copyDirector(director), nil
+}
+
+func (d directorStore) Update(ctx context.Context, director *data.Director) error {
+	d.s.mu.Lock()
+	defer d.s.mu.Unlock()
+
+	if _, ok := d.s.directors[director.ID]; !ok {
+		return data.ErrRecordNotFound
+	}
+
+	d.s.directors[director.ID] = copyDirector(director)
+
+	return nil
+}
+
+func (d directorStore) Delete(ctx context.Context, id int64) error {
+	d.s.mu.Lock()
+	defer d.s.mu.Unlock()
+
+	if _, ok := d.s.directors[id]; !ok {
+		return data.ErrRecordNotFound
+	}
+
+	delete(d.s.directors, id)
+
+	return nil
+}
+
+// GetAll filters by name and awards like DirectorModel.GetAll; surname doesn't filter
+// there either.
+func (d directorStore) GetAll(ctx context.Context, name string, surname string, awards []string, filters data.Filters) ([]*data.Director, error) {
+	compare, err := sorter(filters, map[string]func(a, b *data.Director) int{
+		"id":      func(a, b *data.Director) int { return compareInts(a.ID, b.ID) },
+		"name":    func(a, b *data.Director) int { return strings.Compare(a.Name, b.Name) },
+		"surname": func(a, b *data.Director) int { return strings.Compare(a.Surname, b.Surname) },
+		"awards": func(a, b *data.Director) int {
+			return strings.Compare(strings.Join(a.Awards, "\x00"), strings.Join(b.Awards, "\x00"))
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	d.s.mu.Lock()
+	defer d.s.mu.Unlock()
+
+	directors := []*data.Director{}
+
+	for _, director := range d.s.directors {
+		if matchesWords(director.Name, name) && containsAll(director.Awards, awards) {
+			directors = append(directors, copyDirector(director))
+		}
+	}
+
+	sort.Slice(directors, func(i, j int) bool { return directors[i].ID < directors[j].ID })
+
+	return sortPage(directors, filters, compare, func(director *data.Director) int64 { return director.ID }), nil
+}
+
+func (d directorStore) GetOneByName(ctx context.Context, name string, filters data.Filters) ([]*data.Director, error) {
+	return d.GetAll(ctx, name, "", nil, filters)
+}
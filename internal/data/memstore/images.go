@@ -0,0 +1,91 @@
+package memstore
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/shynggys9219/greenlight/internal/data"
+)
+
+type imageStore struct {
+	s *Store
+}
+
+// copyImage copies what the images table holds; URL and Thumbnails are the API's.
+func copyImage(image *data.Image) *data.Image {
+	c := *image
+	c.ThumbnailWidths = append([]int64(nil), image.ThumbnailWidths...)
+	c.URL = ""
+	c.Thumbnails = nil
+	return &c
+}
+
+func (m imageStore) Insert(ctx context.Context, image *data.Image) error {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	if _, ok := m.s.movies[image.MovieID]; !ok {
+		return data.ErrRecordNotFound
+	}
+
+	image.ID = m.s.nextID("images")
+	image.CreatedAt = time.Now()
+
+	m.s.images[image.ID] = copyImage(image)
+
+	return nil
+}
+
+func (m imageStore) Get(ctx context.Context, movieID, id int64) (*data.Image, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	image, ok := m.s.images[id]
+	if !ok || image.MovieID != movieID {
+		return nil, data.ErrRecordNotFound
+	}
+
+	return copyImage(image), nil
+}
+
+func (m imageStore) Delete(ctx context.Context, movieID, id int64) error {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	image, ok := m.s.images[id]
+	if !ok || image.MovieID != movieID {
+		return data.ErrRecordNotFound
+	}
+
+	delete(m.s.images, id)
+
+	return nil
+}
+
+// GetAllForMovies lists each movie's images by kind, then in upload order.
+func (m imageStore) GetAllForMovies(ctx context.Context, movieIDs []int64) (map[int64][]*data.Image, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	images := make(map[int64][]*data.Image)
+
+	for _, movieID := range movieIDs {
+		for _, image := range m.s.images {
+			if image.MovieID == movieID {
+				images[movieID] = append(images[movieID], copyImage(image))
+			}
+		}
+	}
+
+	for _, list := range images {
+		sort.Slice(list, func(i, j int) bool {
+			if list[i].Kind != list[j].Kind {
+				return list[i].Kind < list[j].Kind
+			}
+			return list[i].ID < list[j].ID
+		})
+	}
+
+	return images, nil
+}
@@ -0,0 +1,42 @@
+package memstore
+
+import (
+	"context"
+
+	"github.com/shynggys9219/greenlight/internal/data"
+)
+
+type jobRunStore struct {
+	s *Store
+}
+
+func (m jobRunStore) Insert(ctx context.Context, run *data.JobRun) error {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	run.ID = m.s.nextID("job_runs")
+
+	stored := *run
+	m.s.jobRuns = append(m.s.jobRuns, &stored)
+
+	return nil
+}
+
+// GetAll lists runs newest first, only those of one job unless job is empty.
+func (m jobRunStore) GetAll(ctx context.Context, job string, filters data.Filters) ([]*data.JobRun, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	runs := []*data.JobRun{}
+
+	for _, run := range m.s.jobRuns {
+		if job == "" || run.Job == job {
+			c := *run
+			runs = append(runs, &c)
+		}
+	}
+
+	newestFirst := func(a, b *data.JobRun) int { return -compareInts(a.ID, b.ID) }
+
+	return sortPage(runs, filters, newestFirst, func(run *data.JobRun) int64 { return run.ID }), nil
+}
@@ -0,0 +1,90 @@
+package memstore
+
+import (
+	"context"
+	"time"
+
+	"github.com/shynggys9219/greenlight/internal/data"
+)
+
+type loginAttemptStore struct {
+	s *Store
+}
+
+func (m loginAttemptStore) Insert(ctx context.Context, attempt *data.LoginAttempt) error {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	attempt.ID = m.s.nextID("login_attempts")
+	attempt.CreatedAt = time.Now()
+
+	stored := *attempt
+	m.s.logins = append(m.s.logins, &stored)
+
+	return nil
+}
+
+// FailuresForEmail counts like LoginAttemptModel.FailuresForEmail: only failures
+// after the account's last successful login.
+func (m loginAttemptStore) FailuresForEmail(ctx context.Context, email string, since time.Time) (data.LoginFailures, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	for _, attempt := range m.s.logins {
+		if attempt.Email == email && attempt.Succeeded && attempt.CreatedAt.After(since) {
+			since = attempt.CreatedAt
+		}
+	}
+
+	return m.failures(func(attempt *data.LoginAttempt) bool { return attempt.Email == email }, since), nil
+}
+
+func (m loginAttemptStore) FailuresForIP(ctx context.Context, ip string, since time.Time) (data.LoginFailures, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	return m.failures(func(attempt *data.LoginAttempt) bool { return attempt.IP == ip }, since), nil
+}
+
+// failures summarizes the failed attempts matching keep after since; the caller
+// holds the lock.
+func (m loginAttemptStore) failures(keep func(*data.LoginAttempt) bool, since time.Time) data.LoginFailures {
+	var failures data.LoginFailures
+
+	for _, attempt := range m.s.logins {
+		if !attempt.Succeeded && keep(attempt) && attempt.CreatedAt.After(since) {
+			failures.Count++
+			if attempt.CreatedAt.After(failures.Last) {
+				failures.Last = attempt.CreatedAt
+			}
+		}
+	}
+
+	return failures
+}
+
+func (m loginAttemptStore) ClearEmail(ctx context.Context, email string) error {
+	m.clear(func(attempt *data.LoginAttempt) bool { return attempt.Email == email })
+	return nil
+}
+
+func (m loginAttemptStore) ClearIP(ctx context.Context, ip string) error {
+	m.clear(func(attempt *data.LoginAttempt) bool { return attempt.IP == ip })
+	return nil
+}
+
+// clear deletes the failed attempts matching match.
+func (m loginAttemptStore) clear(match func(*data.LoginAttempt) bool) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	kept := m.s.logins[:0]
+
+	for _, attempt := range m.s.logins {
+		if attempt.Succeeded || !match(attempt) {
+			kept = append(kept, attempt)
+		}
+	}
+
+	m.s.logins = kept
+}
@@ -0,0 +1,223 @@
+// Package memstore keeps the data of the data package's models in memory, for tests
+// that shouldn't need PostgreSQL. It mimics the models: the same errors, filtering,
+// sorting and paging, version checks, unique emails and cascading deletes. It keeps
+// no audit log, computes no similar movies and has no two-factor authentication.
+package memstore
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/shynggys9219/greenlight/internal/data"
+)
+
+// Store is one in-memory database. It is safe for concurrent use.
+type Store struct {
+	mu sync.Mutex
+
+	lastID        map[string]int64 // per table, like the id sequences
+	movies        map[int64]*data.Movie
+	directors     map[int64]*data.Director
+	users         map[int64]*data.User
+	tokens        []*data.Token
+	roles         []*data.Role
+	emails        []data.OutboxEmail
+	people        map[int64]*data.Person
+	credits       map[int64]*data.Credit
+	titles        map[int64]*data.MovieTitle
+	images        map[int64]*data.Image
+	logins        []*data.LoginAttempt
+	apiKeys       map[int64]*data.APIKey
+	sessions      map[int64]*data.Session
+	sessionTokens map[string]int64 // access token hash to session id, like tokens.session_id
+	refreshTokens []*refreshToken
+	jobRuns       []*data.JobRun
+	ratings       map[ratingKey]*data.Rating
+}
+
+func New() *Store {
+	return &Store{
+		lastID:        map[string]int64{},
+		movies:        map[int64]*data.Movie{},
+		directors:     map[int64]*data.Director{},
+		users:         map[int64]*data.User{},
+		people:        map[int64]*data.Person{},
+		credits:       map[int64]*data.Credit{},
+		titles:        map[int64]*data.MovieTitle{},
+		images:        map[int64]*data.Image{},
+		apiKeys:       map[int64]*data.APIKey{},
+		sessions:      map[int64]*data.Session{},
+		sessionTokens: map[string]int64{},
+		ratings:       map[ratingKey]*data.Rating{},
+	}
+}
+
+// Models returns models for the store. WithTx runs its function without a
+// transaction, so nothing is rolled back on error.
+func (s *Store) Models() data.Models {
+	return data.Models{
+		Movies:    movieStore{s},
+		Directors: directorStore{s},
+		User:      userStore{s},
+		Token:     tokenStore{s},
+		Role:      roleStore{s},
+		People:    personStore{s},
+		Credits:   creditStore{s},
+		Titles:    titleStore{s},
+		Images:    imageStore{s},
+		Outbox:    outboxStore{s},
+		Logins:    loginAttemptStore{s},
+		TOTP:      totpStore{},
+		APIKeys:   apiKeyStore{s},
+		Sessions:  sessionStore{s},
+		JobRuns:   jobRunStore{s},
+		Audit:     auditStore{},
+		Ratings:   ratingStore{s},
+		Similar:   similarityStore{},
+	}
+}
+
+// Emails returns every email in the outbox, oldest first, whatever its status.
+func (s *Store) Emails() []data.OutboxEmail {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]data.OutboxEmail(nil), s.emails...)
+}
+
+func (s *Store) nextID(table string) int64 {
+	s.lastID[table]++
+	return s.lastID[table]
+}
+
+// sorter combines the comparisons of the keys of filters' sort, by key, into one.
+// Like the models it fails on a sort that the filters' SortSpec doesn't allow.
+func sorter[T any](filters data.Filters, compareBy map[string]func(a, b T) int) (func(a, b T) int, error) {
+	fields, err := filters.SortFields()
+	if err != nil {
+		return nil, fmt.Errorf("invalid sort %q: %w", filters.Sort, err)
+	}
+
+	compares := make([]func(a, b T) int, 0, len(fields))
+
+	for _, field := range fields {
+		compare, ok := compareBy[field.Key]
+		if !ok {
+			return nil, fmt.Errorf("memstore: cannot sort by %q", field.Key)
+		}
+
+		if field.Desc {
+			ascending := compare
+			compare = func(a, b T) int { return -ascending(a, b) }
+		}
+
+		compares = append(compares, compare)
+	}
+
+	return func(a, b T) int {
+		for _, compare := range compares {
+			if c := compare(a, b); c != 0 {
+				return c
+			}
+		}
+		return 0
+	}, nil
+}
+
+// sortPage sorts items by compare, then by id like the "ORDER BY %s, id ASC" of the
+// queries, and returns the requested page.
+func sortPage[T any](items []T, filters data.Filters, compare func(a, b T) int, id func(T) int64) []T {
+	sort.SliceStable(items, func(i, j int) bool {
+		if c := compare(items[i], items[j]); c != 0 {
+			return c < 0
+		}
+		return id(items[i]) < id(items[j])
+	})
+
+	offset := (filters.Page - 1) * filters.PageSize
+	if offset < 0 || offset >= len(items) {
+		return items[:0]
+	}
+
+	end := offset + filters.PageSize
+	if end > len(items) || filters.PageSize < 0 {
+		end = len(items)
+	}
+
+	return items[offset:end]
+}
+
+func compareInts[T int | int32 | int64](a, b T) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// matchesWords reports whether text contains every word of query, which is what
+// to_tsvector('simple', text) @@ plainto_tsquery('simple', query) checks. An empty
+// query matches everything, as in the queries of the data package.
+func matchesWords(text, query string) bool {
+	have := map[string]bool{}
+	for _, word := range words(text) {
+		have[word] = true
+	}
+
+	for _, word := range words(query) {
+		if !have[word] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func words(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// containsAll reports whether have contains every element of want, like "@>".
+func containsAll(have, want []string) bool {
+	for _, w := range want {
+		found := false
+		for _, h := range have {
+			if h == w {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+// containsAny reports whether have and want have an element in common, like "&&".
+func containsAny(have, want []string) bool {
+	for _, w := range want {
+		for _, h := range have {
+			if h == w {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func copyStrings(s []string) []string {
+	if s == nil {
+		return nil
+	}
+	return append([]string{}, s...)
+}
@@ -0,0 +1,221 @@
+package memstore
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/shynggys9219/greenlight/internal/data"
+)
+
+type movieStore struct {
+	s *Store
+}
+
+func copyMovie(movie *data.Movie) *data.Movie {
+	c := *movie
+	c.Genres = copyStrings(movie.Genres)
+	return &c
+}
+
+// sortedMovies returns copies of the movies matching keep, by id.
+func (m movieStore) sortedMovies(keep func(*data.Movie) bool) []*data.Movie {
+	movies := []*data.Movie{}
+
+	for _, movie := range m.s.movies {
+		if keep(movie) {
+			movies = append(movies, copyMovie(movie))
+		}
+	}
+
+	sort.Slice(movies, func(i, j int) bool { return movies[i].ID < movies[j].ID })
+
+	return movies
+}
+
+func (m movieStore) Insert(ctx context.Context, movie *data.Movie) error {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	movie.ID = m.s.nextID("movies")
+	movie.CreatedAt = time.Now()
+	movie.Version = 1
+
+	m.s.movies[movie.ID] = copyMovie(movie)
+
+	return nil
+}
+
+func (m movieStore) Get(ctx context.Context, id int64) (*data.Movie, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	movie, ok := m.s.movies[id]
+	if !ok {
+		return nil, data.ErrRecordNotFound
+	}
+
+	return copyMovie(movie), nil
+}
+
+// Update does what MovieModel.Update does: it fails with ErrEditConflict unless the
+// stored movie still has movie.Version.
+func (m movieStore) Update(ctx context.Context, movie *data.Movie) error {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	stored, ok := m.s.movies[movie.ID]
+	if !ok || stored.Version != movie.Version {
+		return data.ErrEditConflict
+	}
+
+	movie.Version++
+	stored.Title = movie.Title
+	stored.Year = movie.Year
+	stored.Runtime = movie.Runtime
+	stored.Genres = copyStrings(movie.Genres)
+	stored.Version = movie.Version
+
+	return nil
+}
+
+func (m movieStore) Delete(ctx context.Context, id int64) error {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	if _, ok := m.s.movies[id]; !ok {
+		return data.ErrRecordNotFound
+	}
+
+	m.s.deleteMovie(id)
+
+	return nil
+}
+
+// deleteMovie deletes the movie with its credits, titles, images and ratings, as ON
+// DELETE CASCADE does; the caller holds the lock.
+func (s *Store) deleteMovie(id int64) {
+	delete(s.movies, id)
+
+	for creditID, credit := range s.credits {
+		if credit.MovieID == id {
+			delete(s.credits, creditID)
+		}
+	}
+	for titleID, title := range s.titles {
+		if title.MovieID == id {
+			delete(s.titles, titleID)
+		}
+	}
+	for imageID, image := range s.images {
+		if image.MovieID == id {
+			delete(s.images, imageID)
+		}
+	}
+	for key := range s.ratings {
+		if key.movieID == id {
+			delete(s.ratings, key)
+		}
+	}
+}
+
+// GetAll matches the title against the movies' titles and their localized titles.
+// Each word has to match as it is; unlike PostgreSQL the store doesn't stem words in
+// the title's language.
+func (m movieStore) GetAll(ctx context.Context, filter data.MovieFilter, filters data.Filters) ([]*data.Movie, error) {
+	compare, err := sorter(filters, map[string]func(a, b *data.Movie) int{
+		"id":         func(a, b *data.Movie) int { return compareInts(a.ID, b.ID) },
+		"title":      func(a, b *data.Movie) int { return strings.Compare(a.Title, b.Title) },
+		"year":       func(a, b *data.Movie) int { return compareInts(a.Year, b.Year) },
+		"runtime":    func(a, b *data.Movie) int { return compareInts(a.Runtime, b.Runtime) },
+		"created_at": func(a, b *data.Movie) int { return compareInts(a.CreatedAt.UnixNano(), b.CreatedAt.UnixNano()) },
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	movies := m.sortedMovies(func(movie *data.Movie) bool {
+		return matchesTitle(movie.Title, m.s.titlesOf(movie.ID), filter.Title) &&
+			matchesGenres(movie.Genres, filter.Genres, filter.GenresMode) &&
+			!containsAny(movie.Genres, filter.ExcludeGenres) &&
+			(filter.YearMin == 0 || movie.Year >= filter.YearMin) &&
+			(filter.YearMax == 0 || movie.Year <= filter.YearMax) &&
+			(filter.RuntimeMin == 0 || movie.Runtime >= filter.RuntimeMin) &&
+			(filter.RuntimeMax == 0 || movie.Runtime <= filter.RuntimeMax) &&
+			(filter.CreatedAfter.IsZero() || !movie.CreatedAt.Before(filter.CreatedAfter)) &&
+			(filter.CreatedBefore.IsZero() || movie.CreatedAt.Before(filter.CreatedBefore))
+	})
+
+	return sortPage(movies, filters, compare, func(movie *data.Movie) int64 { return movie.ID }), nil
+}
+
+// matchesTitle reports whether the title or one of the localized titles matches query.
+func matchesTitle(title string, localized []string, query string) bool {
+	if matchesWords(title, query) {
+		return true
+	}
+
+	for _, t := range localized {
+		if matchesWords(t, query) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matchesGenres matches genres the way data.MovieFilter.GenresMode says. No genres
+// match every movie, whatever the mode.
+func matchesGenres(have, want []string, mode string) bool {
+	if len(want) == 0 {
+		return true
+	}
+
+	switch mode {
+	case data.GenresAny:
+		return containsAny(have, want)
+	case data.GenresNone:
+		return !containsAny(have, want)
+	default:
+		return containsAll(have, want)
+	}
+}
+
+func (m movieStore) GetAllMovies(ctx context.Context) ([]*data.Movie, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	return m.sortedMovies(func(*data.Movie) bool { return true }), nil
+}
+
+func (m movieStore) GetByTitle(ctx context.Context, title string) (*data.Movie, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	movies := m.sortedMovies(func(movie *data.Movie) bool { return movie.Title == title })
+	if len(movies) == 0 {
+		return nil, data.ErrRecordNotFound
+	}
+
+	return movies[0], nil
+}
+
+func (m movieStore) DeleteByTitle(ctx context.Context, title string) error {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	movies := m.sortedMovies(func(movie *data.Movie) bool { return movie.Title == title })
+	if len(movies) == 0 {
+		return data.ErrRecordNotFound
+	}
+
+	for _, movie := range movies {
+		m.s.deleteMovie(movie.ID)
+	}
+
+	return nil
+}
@@ -0,0 +1,218 @@
+package memstore
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/shynggys9219/greenlight/internal/data"
+)
+
+// maxEmailAttempts is the default of email_outbox.max_attempts.
+const maxEmailAttempts = 8
+
+type outboxStore struct {
+	s *Store
+}
+
+func copyEmail(email *data.OutboxEmail) *data.OutboxEmail {
+	c := *email
+	if email.Data != nil {
+		c.Data = make(map[string]any, len(email.Data))
+		for k, v := range email.Data {
+			c.Data[k] = v
+		}
+	}
+	if email.SentAt != nil {
+		sentAt := *email.SentAt
+		c.SentAt = &sentAt
+	}
+	return &c
+}
+
+// enqueueEmail stores the email with the defaults of the email_outbox columns and
+// fills them in on email; the caller holds the lock.
+func (s *Store) enqueueEmail(email *data.OutboxEmail) {
+	if email.Locale == "" {
+		email.Locale = "en"
+	}
+
+	email.ID = s.nextID("email_outbox")
+	email.CreatedAt = time.Now()
+	email.Status = data.OutboxPending
+	email.Attempts = 0
+	email.MaxAttempts = maxEmailAttempts
+	email.NextAttemptAt = email.CreatedAt
+	email.LastError = ""
+	email.SentAt = nil
+	email.Version = 1
+
+	s.emails = append(s.emails, *copyEmail(email))
+}
+
+// email returns the stored email with the id, nil if there is none; the caller holds
+// the lock.
+func (s *Store) email(id int64) *data.OutboxEmail {
+	for i := range s.emails {
+		if s.emails[i].ID == id {
+			return &s.emails[i]
+		}
+	}
+	return nil
+}
+
+func (m outboxStore) Enqueue(ctx context.Context, email *data.OutboxEmail) error {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	m.s.enqueueEmail(email)
+
+	return nil
+}
+
+// Claim leases due emails like OutboxModel.Claim, and buries those whose lease ran
+// out after their last attempt.
+func (m outboxStore) Claim(ctx context.Context, limit int, lease time.Duration) ([]*data.OutboxEmail, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	now := time.Now()
+	due := []*data.OutboxEmail{}
+
+	for i := range m.s.emails {
+		email := &m.s.emails[i]
+
+		switch {
+		case email.Status == data.OutboxSending && !email.NextAttemptAt.After(now) && email.Attempts >= email.MaxAttempts:
+			email.Status = data.OutboxDead
+			email.Version++
+		case (email.Status == data.OutboxPending || email.Status == data.OutboxSending) && !email.NextAttemptAt.After(now):
+			due = append(due, email)
+		}
+	}
+
+	sort.SliceStable(due, func(i, j int) bool { return due[i].NextAttemptAt.Before(due[j].NextAttemptAt) })
+	if len(due) > limit {
+		due = due[:limit]
+	}
+
+	claimed := make([]*data.OutboxEmail, 0, len(due))
+
+	for _, email := range due {
+		email.Status = data.OutboxSending
+		email.Attempts++
+		email.NextAttemptAt = now.Add(lease)
+		email.Version++
+		claimed = append(claimed, copyEmail(email))
+	}
+
+	return claimed, nil
+}
+
+func (m outboxStore) MarkSent(ctx context.Context, email *data.OutboxEmail) error {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	stored := m.s.email(email.ID)
+	if stored == nil || stored.Version != email.Version {
+		return data.ErrEditConflict
+	}
+
+	now := time.Now()
+	stored.Status = data.OutboxSent
+	stored.SentAt = &now
+	stored.Data = map[string]any{}
+	stored.LastError = ""
+	stored.Version++
+
+	email.Status = stored.Status
+	email.SentAt = &now
+	email.Version = stored.Version
+
+	return nil
+}
+
+func (m outboxStore) MarkFailed(ctx context.Context, email *data.OutboxEmail, sendErr error, retryAt time.Time) error {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	stored := m.s.email(email.ID)
+	if stored == nil || stored.Version != email.Version {
+		return data.ErrEditConflict
+	}
+
+	stored.Status = data.OutboxPending
+	if stored.Attempts >= stored.MaxAttempts {
+		stored.Status = data.OutboxDead
+	}
+	stored.NextAttemptAt = retryAt
+	stored.LastError = sendErr.Error()
+	stored.Version++
+
+	email.Status = stored.Status
+	email.NextAttemptAt = retryAt
+	email.LastError = stored.LastError
+	email.Version = stored.Version
+
+	return nil
+}
+
+func (m outboxStore) Requeue(ctx context.Context, email *data.OutboxEmail) error {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	stored := m.s.email(email.ID)
+	if stored == nil || stored.Version != email.Version || (stored.Status != data.OutboxPending && stored.Status != data.OutboxDead) {
+		return data.ErrEditConflict
+	}
+
+	stored.Status = data.OutboxPending
+	stored.Attempts = 0
+	stored.NextAttemptAt = time.Now()
+	stored.Version++
+
+	email.Status = stored.Status
+	email.Attempts = 0
+	email.NextAttemptAt = stored.NextAttemptAt
+	email.Version = stored.Version
+
+	return nil
+}
+
+func (m outboxStore) Get(ctx context.Context, id int64) (*data.OutboxEmail, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	email := m.s.email(id)
+	if email == nil {
+		return nil, data.ErrRecordNotFound
+	}
+
+	return copyEmail(email), nil
+}
+
+func (m outboxStore) GetAll(ctx context.Context, status string, filters data.Filters) ([]*data.OutboxEmail, error) {
+	compare, err := sorter(filters, map[string]func(a, b *data.OutboxEmail) int{
+		"id":         func(a, b *data.OutboxEmail) int { return compareInts(a.ID, b.ID) },
+		"created_at": func(a, b *data.OutboxEmail) int { return compareInts(a.CreatedAt.UnixNano(), b.CreatedAt.UnixNano()) },
+		"next_attempt_at": func(a, b *data.OutboxEmail) int {
+			return compareInts(a.NextAttemptAt.UnixNano(), b.NextAttemptAt.UnixNano())
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	emails := []*data.OutboxEmail{}
+
+	for i := range m.s.emails {
+		if status == "" || m.s.emails[i].Status == status {
+			emails = append(emails, copyEmail(&m.s.emails[i]))
+		}
+	}
+
+	return sortPage(emails, filters, compare, func(email *data.OutboxEmail) int64 { return email.ID }), nil
+}
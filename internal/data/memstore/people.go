@@ -0,0 +1,101 @@
+package memstore
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/shynggys9219/greenlight/internal/data"
+)
+
+type personStore struct {
+	s *Store
+}
+
+func (m personStore) Insert(ctx context.Context, person *data.Person) error {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	person.ID = m.s.nextID("people")
+	person.CreatedAt = time.Now()
+	person.Version = 1
+
+	stored := *person
+	m.s.people[person.ID] = &stored
+
+	return nil
+}
+
+func (m personStore) Get(ctx context.Context, id int64) (*data.Person, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	person, ok := m.s.people[id]
+	if !ok {
+		return nil, data.ErrRecordNotFound
+	}
+
+	c := *person
+	return &c, nil
+}
+
+func (m personStore) Update(ctx context.Context, person *data.Person) error {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	stored, ok := m.s.people[person.ID]
+	if !ok || stored.Version != person.Version {
+		return data.ErrEditConflict
+	}
+
+	person.Version++
+	stored.Name = person.Name
+	stored.Biography = person.Biography
+	stored.Version = person.Version
+
+	return nil
+}
+
+// Delete deletes the person with their credits, as ON DELETE CASCADE does.
+func (m personStore) Delete(ctx context.Context, id int64) error {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	if _, ok := m.s.people[id]; !ok {
+		return data.ErrRecordNotFound
+	}
+
+	delete(m.s.people, id)
+
+	for creditID, credit := range m.s.credits {
+		if credit.PersonID == id {
+			delete(m.s.credits, creditID)
+		}
+	}
+
+	return nil
+}
+
+func (m personStore) GetAll(ctx context.Context, name string, filters data.Filters) ([]*data.Person, error) {
+	compare, err := sorter(filters, map[string]func(a, b *data.Person) int{
+		"id":   func(a, b *data.Person) int { return compareInts(a.ID, b.ID) },
+		"name": func(a, b *data.Person) int { return strings.Compare(a.Name, b.Name) },
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	people := []*data.Person{}
+
+	for _, person := range m.s.people {
+		if matchesWords(person.Name, name) {
+			c := *person
+			people = append(people, &c)
+		}
+	}
+
+	return sortPage(people, filters, compare, func(person *data.Person) int64 { return person.ID }), nil
+}
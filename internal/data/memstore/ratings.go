@@ -0,0 +1,47 @@
+package memstore
+
+import (
+	"context"
+	"time"
+
+	"github.com/shynggys9219/greenlight/internal/data"
+)
+
+// ratingKey is the primary key of movie_ratings.
+type ratingKey struct {
+	userID, movieID int64
+}
+
+type ratingStore struct {
+	s *Store
+}
+
+func (m ratingStore) Upsert(ctx context.Context, rating *data.Rating) error {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	if _, ok := m.s.movies[rating.MovieID]; !ok {
+		return data.ErrRecordNotFound
+	}
+
+	rating.UpdatedAt = time.Now()
+
+	stored := *rating
+	m.s.ratings[ratingKey{rating.UserID, rating.MovieID}] = &stored
+
+	return nil
+}
+
+func (m ratingStore) Delete(ctx context.Context, userID, movieID int64) error {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	key := ratingKey{userID, movieID}
+	if _, ok := m.s.ratings[key]; !ok {
+		return data.ErrRecordNotFound
+	}
+
+	delete(m.s.ratings, key)
+
+	return nil
+}
@@ -0,0 +1,73 @@
+package memstore
+
+import (
+	"context"
+	"sort"
+
+	"github.com/shynggys9219/greenlight/internal/data"
+)
+
+type roleStore struct {
+	s *Store
+}
+
+// deleteRoles deletes every role of the user; the caller holds the lock.
+func (s *Store) deleteRoles(userID int64) {
+	kept := s.roles[:0]
+
+	for _, role := range s.roles {
+		if role.UserID != userID {
+			kept = append(kept, role)
+		}
+	}
+
+	s.roles = kept
+}
+
+func (m roleStore) Insert(ctx context.Context, role *data.Role) error {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	if _, ok := m.s.users[role.UserID]; !ok {
+		return data.ErrUnknownUser
+	}
+
+	role.ID = m.s.nextID("roles")
+
+	stored := *role
+	m.s.roles = append(m.s.roles, &stored)
+
+	return nil
+}
+
+func (m roleStore) InsertUserRole(ctx context.Context, role *data.Role) error {
+	return m.Insert(ctx, role)
+}
+
+func (m roleStore) NewRole(ctx context.Context, roleID int64, roleName string, userID int64) (*data.Role, error) {
+	role, err := data.Create(roleID, roleName, userID)
+	if err != nil {
+		return nil, err
+	}
+	err = m.Insert(ctx, role)
+	return role, err
+}
+
+func (m roleStore) GetAllForUser(ctx context.Context, userID int64) ([]string, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	seen := map[string]bool{}
+	roles := []string{}
+
+	for _, role := range m.s.roles {
+		if role.UserID == userID && !seen[role.RoleName] {
+			seen[role.RoleName] = true
+			roles = append(roles, role.RoleName)
+		}
+	}
+
+	sort.Strings(roles)
+
+	return roles, nil
+}
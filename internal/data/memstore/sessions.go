@@ -0,0 +1,262 @@
+package memstore
+
+import (
+	"context"
+	"crypto/sha256"
+	"sort"
+	"time"
+
+	"github.com/shynggys9219/greenlight/internal/data"
+)
+
+// refreshToken is a row of the refresh_tokens table.
+type refreshToken struct {
+	hash      []byte
+	sessionID int64
+	expiry    time.Time
+	used      bool
+}
+
+type sessionStore struct {
+	s *Store
+}
+
+// issueSessionTokens stores new tokens for the session like insertSessionTokens of
+// the data package; the caller holds the lock.
+func (s *Store) issueSessionTokens(session *data.Session, accessTTL, refreshTTL time.Duration) (access, refresh *data.Token, err error) {
+	if accessTTL > 0 {
+		access, err = data.GenerateToken(session.UserID, accessTTL, data.ScopeAuthentication)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		s.tokens = append(s.tokens, copyToken(access))
+		s.sessionTokens[string(access.Hash)] = session.ID
+	}
+
+	refresh, err = data.GenerateToken(session.UserID, refreshTTL, data.ScopeRefresh)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	s.refreshTokens = append(s.refreshTokens, &refreshToken{
+		hash:      append([]byte(nil), refresh.Hash...),
+		sessionID: session.ID,
+		expiry:    refresh.Expiry,
+	})
+
+	return access, refresh, nil
+}
+
+// deleteSessions deletes the sessions matching match with their access and refresh
+// tokens, as ON DELETE CASCADE does, and returns how many it deleted; the caller
+// holds the lock.
+func (s *Store) deleteSessions(match func(*data.Session) bool) int64 {
+	deleted := map[int64]bool{}
+
+	for id, session := range s.sessions {
+		if match(session) {
+			deleted[id] = true
+			delete(s.sessions, id)
+		}
+	}
+
+	if len(deleted) == 0 {
+		return 0
+	}
+
+	tokens := s.tokens[:0]
+	for _, token := range s.tokens {
+		if !deleted[s.sessionTokens[string(token.Hash)]] {
+			tokens = append(tokens, token)
+		}
+	}
+	s.tokens = tokens
+
+	for hash, sessionID := range s.sessionTokens {
+		if deleted[sessionID] {
+			delete(s.sessionTokens, hash)
+		}
+	}
+
+	refreshTokens := s.refreshTokens[:0]
+	for _, token := range s.refreshTokens {
+		if !deleted[token.sessionID] {
+			refreshTokens = append(refreshTokens, token)
+		}
+	}
+	s.refreshTokens = refreshTokens
+
+	return int64(len(deleted))
+}
+
+func (m sessionStore) New(ctx context.Context, session *data.Session, accessTTL, refreshTTL time.Duration) (access, refresh *data.Token, err error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	session.ID = m.s.nextID("sessions")
+	session.CreatedAt = time.Now()
+	session.LastSeenAt = session.CreatedAt
+
+	stored := *session
+	m.s.sessions[session.ID] = &stored
+
+	access, refresh, err = m.s.issueSessionTokens(session, accessTTL, refreshTTL)
+	if err != nil {
+		m.s.deleteSessions(func(s *data.Session) bool { return s.ID == session.ID })
+		return nil, nil, err
+	}
+
+	return access, refresh, nil
+}
+
+// Refresh does what SessionModel.Refresh does, including revoking the session when a
+// refresh token is reused.
+func (m sessionStore) Refresh(ctx context.Context, plaintext, userAgent, ip string, accessTTL, refreshTTL time.Duration) (session *data.Session, access, refresh *data.Token, err error) {
+	hash := sha256.Sum256([]byte(plaintext))
+
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	var token *refreshToken
+
+	for _, t := range m.s.refreshTokens {
+		if string(t.hash) == string(hash[:]) && t.expiry.After(time.Now()) {
+			token = t
+			break
+		}
+	}
+
+	if token == nil {
+		return nil, nil, nil, data.ErrRecordNotFound
+	}
+
+	if token.used {
+		m.s.deleteSessions(func(s *data.Session) bool { return s.ID == token.sessionID })
+		return nil, nil, nil, data.ErrTokenReused
+	}
+
+	token.used = true
+
+	stored := m.s.sessions[token.sessionID]
+	stored.LastSeenAt = time.Now()
+	stored.UserAgent = userAgent
+	stored.IP = ip
+
+	c := *stored
+	session = &c
+
+	access, refresh, err = m.s.issueSessionTokens(session, accessTTL, refreshTTL)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	return session, access, refresh, nil
+}
+
+func (m sessionStore) GetForToken(ctx context.Context, plaintext string) (*data.Session, error) {
+	hash := sha256.Sum256([]byte(plaintext))
+
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	for _, token := range m.s.tokens {
+		if string(token.Hash) != string(hash[:]) {
+			continue
+		}
+
+		session, ok := m.s.sessions[m.s.sessionTokens[string(token.Hash)]]
+		if !ok {
+			break
+		}
+
+		if now := time.Now(); now.Sub(session.LastSeenAt) > time.Minute {
+			session.LastSeenAt = now
+		}
+
+		c := *session
+		return &c, nil
+	}
+
+	return nil, data.ErrRecordNotFound
+}
+
+// GetAllForUser lists the user's sessions that can still be refreshed, most recently
+// seen first.
+func (m sessionStore) GetAllForUser(ctx context.Context, userID int64) ([]*data.Session, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	now := time.Now()
+	seen := map[int64]bool{}
+	sessions := []*data.Session{}
+
+	for _, token := range m.s.refreshTokens {
+		session := m.s.sessions[token.sessionID]
+		if session.UserID != userID || token.used || !token.expiry.After(now) || seen[session.ID] {
+			continue
+		}
+		seen[session.ID] = true
+
+		c := *session
+		sessions = append(sessions, &c)
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		if !sessions[i].LastSeenAt.Equal(sessions[j].LastSeenAt) {
+			return sessions[i].LastSeenAt.After(sessions[j].LastSeenAt)
+		}
+		return sessions[i].ID > sessions[j].ID
+	})
+
+	return sessions, nil
+}
+
+func (m sessionStore) Delete(ctx context.Context, id, userID int64) error {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	deleted := m.s.deleteSessions(func(s *data.Session) bool { return s.ID == id && s.UserID == userID })
+	if deleted == 0 {
+		return data.ErrRecordNotFound
+	}
+
+	return nil
+}
+
+func (m sessionStore) DeleteAllForUser(ctx context.Context, userID int64) error {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	m.s.deleteSessions(func(s *data.Session) bool { return s.UserID == userID })
+
+	return nil
+}
+
+// DeleteExpired removes expired refresh tokens and the sessions left without one that
+// could still be used, like SessionModel.DeleteExpired.
+func (m sessionStore) DeleteExpired(ctx context.Context) (int64, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	now := time.Now()
+	usable := map[int64]bool{}
+	kept := m.s.refreshTokens[:0]
+
+	for _, token := range m.s.refreshTokens {
+		if token.expiry.Before(now) {
+			continue
+		}
+		kept = append(kept, token)
+		if !token.used {
+			usable[token.sessionID] = true
+		}
+	}
+
+	deleted := int64(len(m.s.refreshTokens) - len(kept))
+	m.s.refreshTokens = kept
+
+	deleted += m.s.deleteSessions(func(s *data.Session) bool { return !usable[s.ID] })
+
+	return deleted, nil
+}
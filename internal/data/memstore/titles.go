@@ -0,0 +1,122 @@
+package memstore
+
+import (
+	"context"
+	"sort"
+
+	"github.com/shynggys9219/greenlight/internal/data"
+)
+
+type titleStore struct {
+	s *Store
+}
+
+// Upsert does what MovieTitleModel.Upsert does, including the check that a movie has
+// at most one original title.
+func (m titleStore) Upsert(ctx context.Context, title *data.MovieTitle) error {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	if _, ok := m.s.movies[title.MovieID]; !ok {
+		return data.ErrRecordNotFound
+	}
+
+	var stored *data.MovieTitle
+
+	for _, other := range m.s.titles {
+		if other.MovieID != title.MovieID {
+			continue
+		}
+		if other.Language == title.Language {
+			stored = other
+		} else if other.IsOriginal && title.IsOriginal {
+			return data.ErrDuplicateOriginalTitle
+		}
+	}
+
+	if stored == nil {
+		stored = &data.MovieTitle{
+			ID:       m.s.nextID("movie_titles"),
+			MovieID:  title.MovieID,
+			Language: title.Language,
+		}
+		m.s.titles[stored.ID] = stored
+	}
+
+	stored.Title = title.Title
+	stored.IsOriginal = title.IsOriginal
+	stored.Version++
+
+	title.ID = stored.ID
+	title.Version = stored.Version
+
+	return nil
+}
+
+func (m titleStore) Delete(ctx context.Context, movieID int64, language string) error {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	for id, title := range m.s.titles {
+		if title.MovieID == movieID && title.Language == language {
+			delete(m.s.titles, id)
+			return nil
+		}
+	}
+
+	return data.ErrRecordNotFound
+}
+
+func (m titleStore) GetAllForMovie(ctx context.Context, movieID int64) ([]*data.MovieTitle, error) {
+	titles, err := m.GetAllForMovies(ctx, []int64{movieID})
+	if err != nil {
+		return nil, err
+	}
+
+	if titles[movieID] == nil {
+		return []*data.MovieTitle{}, nil
+	}
+
+	return titles[movieID], nil
+}
+
+// GetAllForMovies lists each movie's original title first, then the others by language.
+func (m titleStore) GetAllForMovies(ctx context.Context, movieIDs []int64) (map[int64][]*data.MovieTitle, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	titles := make(map[int64][]*data.MovieTitle)
+
+	for _, movieID := range movieIDs {
+		for _, title := range m.s.titles {
+			if title.MovieID == movieID {
+				c := *title
+				titles[movieID] = append(titles[movieID], &c)
+			}
+		}
+	}
+
+	for _, list := range titles {
+		sort.Slice(list, func(i, j int) bool {
+			if list[i].IsOriginal != list[j].IsOriginal {
+				return list[i].IsOriginal
+			}
+			return list[i].Language < list[j].Language
+		})
+	}
+
+	return titles, nil
+}
+
+// titlesOf returns the titles of the movie; the caller holds the lock.
+func (s *Store) titlesOf(movieID int64) []string {
+	titles := []string{}
+
+	for _, title := range s.titles {
+		if title.MovieID == movieID {
+			titles = append(titles, title.Title)
+		}
+	}
+
+	return titles
+}
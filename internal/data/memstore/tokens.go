@@ -0,0 +1,82 @@
+package memstore
+
+import (
+	"context"
+	"time"
+
+	"github.com/shynggys9219/greenlight/internal/data"
+)
+
+type tokenStore struct {
+	s *Store
+}
+
+func copyToken(token *data.Token) *data.Token {
+	c := *token
+	c.Hash = append([]byte(nil), token.Hash...)
+	return &c
+}
+
+// deleteTokens deletes the user's tokens of scope, or all of them for scope "";
+// the caller holds the lock.
+func (s *Store) deleteTokens(userID int64, scope string) {
+	kept := s.tokens[:0]
+
+	for _, token := range s.tokens {
+		if token.UserID != userID || (scope != "" && token.Scope != scope) {
+			kept = append(kept, token)
+		}
+	}
+
+	s.tokens = kept
+}
+
+func (m tokenStore) New(ctx context.Context, userID int64, ttl time.Duration, scope string) (*data.Token, error) {
+	token, err := data.GenerateToken(userID, ttl, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	err = m.Insert(ctx, token)
+	return token, err
+}
+
+func (m tokenStore) Insert(ctx context.Context, token *data.Token) error {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	m.s.tokens = append(m.s.tokens, copyToken(token))
+
+	return nil
+}
+
+func (m tokenStore) DeleteAllForUser(ctx context.Context, scope string, userID int64) error {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	// An empty scope matches no token in PostgreSQL, so it mustn't delete them all here.
+	if scope != "" {
+		m.s.deleteTokens(userID, scope)
+	}
+
+	return nil
+}
+
+func (m tokenStore) DeleteExpired(ctx context.Context) (int64, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	now := time.Now()
+	kept := m.s.tokens[:0]
+
+	for _, token := range m.s.tokens {
+		if !token.Expiry.Before(now) {
+			kept = append(kept, token)
+		}
+	}
+
+	deleted := int64(len(m.s.tokens) - len(kept))
+	m.s.tokens = kept
+
+	return deleted, nil
+}
@@ -0,0 +1,66 @@
+package memstore
+
+import (
+	"context"
+	"errors"
+
+	"github.com/shynggys9219/greenlight/internal/data"
+)
+
+// The store keeps no audit log, scores no similar movies and has no two-factor
+// authentication. These repositories answer as the models would for a database where
+// none of that ever happened.
+
+var errTOTPUnsupported = errors.New("memstore: two-factor authentication is not supported")
+
+type auditStore struct{}
+
+func (auditStore) GetAll(ctx context.Context, filter data.AuditFilter, filters data.Filters) ([]*data.AuditEvent, error) {
+	return []*data.AuditEvent{}, nil
+}
+
+type similarityStore struct{}
+
+func (similarityStore) Compute(ctx context.Context, movieID int64, limit int) ([]*data.SimilarMovie, error) {
+	return []*data.SimilarMovie{}, nil
+}
+
+func (similarityStore) GetForMovie(ctx context.Context, movieID int64, limit int) ([]*data.SimilarMovie, error) {
+	return []*data.SimilarMovie{}, nil
+}
+
+func (similarityStore) Refresh(ctx context.Context, movieID int64) error {
+	return nil
+}
+
+func (similarityStore) RefreshAll(ctx context.Context) (int64, error) {
+	return 0, nil
+}
+
+// totpStore has no credentials, so no user has two-factor authentication and
+// enrolling fails.
+type totpStore struct{}
+
+func (totpStore) Get(ctx context.Context, userID int64) (*data.TOTPCredential, error) {
+	return nil, data.ErrRecordNotFound
+}
+
+func (totpStore) Enroll(ctx context.Context, userID int64) (*data.TOTPCredential, error) {
+	return nil, errTOTPUnsupported
+}
+
+func (totpStore) Enable(ctx context.Context, credential *data.TOTPCredential, code string) ([]string, bool, error) {
+	return nil, false, errTOTPUnsupported
+}
+
+func (totpStore) Verify(ctx context.Context, credential *data.TOTPCredential, code string) (bool, error) {
+	return false, nil
+}
+
+func (totpStore) UseRecoveryCode(ctx context.Context, userID int64, code string) (bool, error) {
+	return false, nil
+}
+
+func (totpStore) Disable(ctx context.Context, userID int64) error {
+	return nil
+}
@@ -0,0 +1,371 @@
+package memstore
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/shynggys9219/greenlight/internal/data"
+)
+
+type userStore struct {
+	s *Store
+}
+
+func copyUser(user *data.User) *data.User {
+	c := *user
+	if user.LockedUntil != nil {
+		until := *user.LockedUntil
+		c.LockedUntil = &until
+	}
+	return &c
+}
+
+// emailTaken reports whether another user than id has the address. Emails are
+// citext in PostgreSQL, so the comparison ignores case.
+func (m userStore) emailTaken(email string, id int64) bool {
+	for _, user := range m.s.users {
+		if user.ID != id && strings.EqualFold(user.Email, email) {
+			return true
+		}
+	}
+	return false
+}
+
+// insert stores a new user; the caller holds the lock.
+func (m userStore) insert(user *data.User) error {
+	if m.emailTaken(user.Email, 0) {
+		return data.ErrDuplicateEmail
+	}
+
+	user.ID = m.s.nextID("users")
+	user.CreatedAt = time.Now()
+	user.Version = 1
+
+	m.s.users[user.ID] = copyUser(user)
+
+	return nil
+}
+
+// issueToken creates and stores a token; the caller holds the lock.
+func (m userStore) issueToken(userID int64, ttl time.Duration, scope string) (*data.Token, error) {
+	token, err := data.GenerateToken(userID, ttl, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	m.s.tokens = append(m.s.tokens, copyToken(token))
+
+	return token, nil
+}
+
+// queueEmail queues the email in the outbox; the caller holds the lock.
+func (m userStore) queueEmail(email data.OutboxEmail) {
+	m.s.enqueueEmail(&email)
+}
+
+// deleteUser deletes the user with everything that references it, as ON DELETE
+// CASCADE does; the caller holds the lock.
+func (m userStore) deleteUser(id int64) {
+	delete(m.s.users, id)
+	m.deleteUserData(id)
+}
+
+// deleteUserData deletes the user's tokens, sessions, API keys, roles and ratings;
+// the caller holds the lock.
+func (m userStore) deleteUserData(id int64) {
+	m.s.deleteSessions(func(session *data.Session) bool { return session.UserID == id })
+	m.s.deleteTokens(id, "")
+	m.s.deleteRoles(id)
+
+	for keyID, key := range m.s.apiKeys {
+		if key.UserID == id {
+			delete(m.s.apiKeys, keyID)
+		}
+	}
+	for key := range m.s.ratings {
+		if key.userID == id {
+			delete(m.s.ratings, key)
+		}
+	}
+}
+
+func (m userStore) Insert(ctx context.Context, user *data.User) error {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	return m.insert(user)
+}
+
+func (m userStore) InsertWithActivation(ctx context.Context, user *data.User, ttl time.Duration, templateName string) (*data.Token, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	err := m.insert(user)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := m.issueToken(user.ID, ttl, data.ScopeActivation)
+	if err != nil {
+		delete(m.s.users, user.ID)
+		return nil, err
+	}
+
+	m.queueEmail(data.OutboxEmail{
+		Recipient: user.Email,
+		Locale:    user.Locale,
+		Template:  templateName,
+		Data: map[string]any{
+			"activationToken": token.Plaintext,
+			"userID":          user.ID,
+		},
+	})
+
+	return token, nil
+}
+
+func (m userStore) Get(ctx context.Context, id int64) (*data.User, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	user, ok := m.s.users[id]
+	if !ok {
+		return nil, data.ErrRecordNotFound
+	}
+
+	return copyUser(user), nil
+}
+
+func (m userStore) GetByEmain(ctx context.Context, email string) (*data.User, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	for _, user := range m.s.users {
+		if strings.EqualFold(user.Email, email) {
+			return copyUser(user), nil
+		}
+	}
+
+	return nil, data.ErrRecordNotFound
+}
+
+func (m userStore) GetForToken(ctx context.Context, tokenScope, tokenPlaintext string) (*data.User, error) {
+	tokenHash := sha256.Sum256([]byte(tokenPlaintext))
+
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	for _, token := range m.s.tokens {
+		if string(token.Hash) == string(tokenHash[:]) && token.Scope == tokenScope && token.Expiry.After(time.Now()) {
+			if user, ok := m.s.users[token.UserID]; ok {
+				return copyUser(user), nil
+			}
+		}
+	}
+
+	return nil, data.ErrRecordNotFound
+}
+
+func (m userStore) GetAll(ctx context.Context, filter data.UserFilter, filters data.Filters) ([]*data.User, error) {
+	compare, err := sorter(filters, map[string]func(a, b *data.User) int{
+		"id":         func(a, b *data.User) int { return compareInts(a.ID, b.ID) },
+		"email":      func(a, b *data.User) int { return strings.Compare(a.Email, b.Email) },
+		"name":       func(a, b *data.User) int { return strings.Compare(a.Name, b.Name) },
+		"created_at": func(a, b *data.User) int { return compareInts(a.CreatedAt.UnixNano(), b.CreatedAt.UnixNano()) },
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	users := []*data.User{}
+
+	for _, user := range m.s.users {
+		switch {
+		case filter.Activated != nil && user.Activated != *filter.Activated:
+		case !filter.CreatedAfter.IsZero() && user.CreatedAt.Before(filter.CreatedAfter):
+		case !filter.CreatedBefore.IsZero() && !user.CreatedAt.Before(filter.CreatedBefore):
+		case !strings.Contains(strings.ToLower(user.Email), strings.ToLower(filter.Email)):
+		default:
+			users = append(users, copyUser(user))
+		}
+	}
+
+	sort.Slice(users, func(i, j int) bool { return users[i].ID < users[j].ID })
+
+	return sortPage(users, filters, compare, func(user *data.User) int64 { return user.ID }), nil
+}
+
+// current returns the stored user if it still has user's version, and
+// ErrEditConflict otherwise; the caller holds the lock.
+func (m userStore) current(user *data.User) (*data.User, error) {
+	stored, ok := m.s.users[user.ID]
+	if !ok || stored.Version != user.Version {
+		return nil, data.ErrEditConflict
+	}
+	return stored, nil
+}
+
+func (m userStore) Update(ctx context.Context, user *data.User) error {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	_, err := m.current(user)
+	if err != nil {
+		return err
+	}
+
+	if m.emailTaken(user.Email, user.ID) {
+		return data.ErrDuplicateEmail
+	}
+
+	user.Version++
+	m.s.users[user.ID] = copyUser(user)
+
+	return nil
+}
+
+func (m userStore) RequestEmailChange(ctx context.Context, user *data.User, newEmail string, ttl time.Duration, templateName string) (*data.Token, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	stored, err := m.current(user)
+	if err != nil {
+		return nil, err
+	}
+
+	m.s.deleteTokens(user.ID, data.ScopeEmailChange)
+
+	token, err := m.issueToken(user.ID, ttl, data.ScopeEmailChange)
+	if err != nil {
+		return nil, err
+	}
+
+	stored.PendingEmail = newEmail
+	stored.Version++
+	user.PendingEmail = newEmail
+	user.Version = stored.Version
+
+	m.queueEmail(data.OutboxEmail{
+		Recipient: newEmail,
+		Locale:    user.Locale,
+		Template:  templateName,
+		Data: map[string]any{
+			"emailChangeToken": token.Plaintext,
+			"name":             user.Name,
+		},
+	})
+
+	return token, nil
+}
+
+// LockOut, like UserModel.LockOut, doesn't check the version.
+func (m userStore) LockOut(ctx context.Context, user *data.User, until time.Time, ttl time.Duration, templateName string) (*data.Token, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	stored, ok := m.s.users[user.ID]
+	if !ok {
+		return nil, data.ErrRecordNotFound
+	}
+
+	m.s.deleteTokens(user.ID, data.ScopeUnlock)
+
+	token, err := m.issueToken(user.ID, ttl, data.ScopeUnlock)
+	if err != nil {
+		return nil, err
+	}
+
+	stored.LockedUntil = &until
+	stored.Version++
+	user.LockedUntil = &until
+	user.Version = stored.Version
+
+	m.queueEmail(data.OutboxEmail{
+		Recipient: user.Email,
+		Locale:    user.Locale,
+		Template:  templateName,
+		Data: map[string]any{
+			"name":        user.Name,
+			"unlockToken": token.Plaintext,
+			"lockedUntil": until.UTC().Format(time.RFC1123),
+		},
+	})
+
+	return token, nil
+}
+
+// Anonymize overwrites the user like UserModel.Anonymize and removes its tokens,
+// sessions, API keys, roles and ratings; the store has no TOTP secrets.
+func (m userStore) Anonymize(ctx context.Context, user *data.User) error {
+	random := make([]byte, 32)
+	_, err := rand.Read(random)
+	if err != nil {
+		return err
+	}
+
+	anonymized := *user
+	err = anonymized.Password.Set(fmt.Sprintf("%x", random))
+	if err != nil {
+		return err
+	}
+
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	_, err = m.current(user)
+	if err != nil {
+		return err
+	}
+
+	anonymized.Name = "Deleted user"
+	anonymized.Email = fmt.Sprintf("deleted-%d@users.invalid", user.ID)
+	anonymized.Activated = false
+	anonymized.Locked = true
+	anonymized.PendingEmail = ""
+	anonymized.LockedUntil = nil
+	anonymized.Version++
+
+	*user = anonymized
+	m.s.users[user.ID] = copyUser(user)
+	m.deleteUserData(user.ID)
+
+	return nil
+}
+
+func (m userStore) Delete(ctx context.Context, user *data.User) error {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	_, err := m.current(user)
+	if err != nil {
+		return err
+	}
+
+	m.deleteUser(user.ID)
+
+	return nil
+}
+
+func (m userStore) DeleteUnactivated(ctx context.Context, registeredBefore time.Time) (int64, error) {
+	m.s.mu.Lock()
+	defer m.s.mu.Unlock()
+
+	var deleted int64
+
+	for id, user := range m.s.users {
+		if !user.Activated && !user.Locked && user.CreatedAt.Before(registeredBefore) {
+			m.deleteUser(id)
+			deleted++
+		}
+	}
+
+	return deleted, nil
+}
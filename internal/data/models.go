@@ -3,6 +3,7 @@ package data
 import (
 	"database/sql"
 	"errors"
+	"time"
 )
 
 // Define a custom ErrRecordNotFound error. We'll return this from our Get() method when
@@ -12,23 +13,79 @@ var (
 	ErrEditConflict   = errors.New("edit conflict")
 )
 
+// QueryTimeout bounds every query, on top of the deadline of the context the caller
+// passes in. main sets it from -db-query-timeout before the models are used.
+var QueryTimeout = 3 * time.Second
+
 // Create a Models struct which wraps the MovieModel
 // kind of enveloping
 type Models struct {
-	Movies    MovieModel
-	Directors DirectorModel
-	User      UserModel
-	Token     TokenModel
-	Role      RoleModel
+	Movies    MovieRepository
+	Directors DirectorRepository
+	User      UserRepository
+	Token     TokenRepository
+	Role      RoleRepository
+	People    PersonRepository
+	Credits   CreditRepository
+	Titles    MovieTitleRepository
+	Images    ImageRepository
+	Outbox    OutboxRepository
+	Logins    LoginAttemptRepository
+	TOTP      TOTPRepository
+	APIKeys   APIKeyRepository
+	Sessions  SessionRepository
+	JobRuns   JobRunRepository
+	Audit     AuditRepository
+	Ratings   RatingRepository
+	Similar   SimilarityRepository
+
+	db querier // what the models run on, for WithTx; nil without a database
 }
 
 // method which returns a Models struct containing the initialized MovieModel.
 func NewModels(db *sql.DB) Models {
 	return Models{
+		db:        db,
 		Movies:    MovieModel{DB: db},
 		Directors: DirectorModel{DB: db},
 		User:      UserModel{DB: db},
 		Token:     TokenModel{DB: db},
 		Role:      RoleModel{DB: db},
+		People:    PersonModel{DB: db},
+		Credits:   CreditModel{DB: db},
+		Titles:    MovieTitleModel{DB: db},
+		Images:    ImageModel{DB: db},
+		Outbox:    OutboxModel{DB: db},
+		Logins:    LoginAttemptModel{DB: db},
+		TOTP:      TOTPModel{DB: db},
+		APIKeys:   APIKeyModel{DB: db},
+		Sessions:  SessionModel{DB: db},
+		JobRuns:   JobRunModel{DB: db},
+		Audit:     AuditModel{DB: db},
+		Ratings:   RatingModel{DB: db},
+		Similar:   SimilarityModel{DB: db},
+	}
+}
+
+// As returns a copy of the models whose changes are attributed to actor in the audit
+// log. Changes made through the models from NewModels are attributed to the system.
+// Repositories other than the PostgreSQL models keep no audit log and stay as they are.
+func (m Models) As(actor Actor) Models {
+	if movies, ok := m.Movies.(MovieModel); ok {
+		movies.Actor = actor
+		m.Movies = movies
+	}
+	if directors, ok := m.Directors.(DirectorModel); ok {
+		directors.Actor = actor
+		m.Directors = directors
+	}
+	if users, ok := m.User.(UserModel); ok {
+		users.Actor = actor
+		m.User = users
+	}
+	if roles, ok := m.Role.(RoleModel); ok {
+		roles.Actor = actor
+		m.Role = roles
 	}
+	return m
 }
@@ -0,0 +1,207 @@
+package data
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/lib/pq"
+	"github.com/shynggys9219/greenlight/internal/validator"
+)
+
+var (
+	ErrDuplicateOriginalTitle = errors.New("duplicate original title")
+)
+
+// MovieTitle is an alternate title of a movie in one language. Language is a
+// BCP 47 style code such as "en", "ru" or "pt-BR".
+type MovieTitle struct {
+	ID         int64  `json:"id"`
+	MovieID    int64  `json:"movie_id"`
+	Language   string `json:"language"`
+	Title      string `json:"title"`
+	IsOriginal bool   `json:"is_original"`
+	Version    int32  `json:"version"`
+}
+
+// titleSearchConfigs are the text search configurations movie_title_search_config()
+// of migration 000011 picks from.
+var titleSearchConfigs = []string{
+	"simple", "danish", "dutch", "english", "finnish", "french", "german", "hungarian",
+	"italian", "norwegian", "portuguese", "romanian", "russian", "spanish", "swedish", "turkish",
+}
+
+// titleSearch is the condition on movie_titles for a search for the words in param.
+// The query is built once per search configuration instead of from the search_config
+// column of each row, which lets PostgreSQL use movie_titles_search_idx.
+func titleSearch(param string) string {
+	conditions := make([]string, 0, len(titleSearchConfigs))
+	for _, config := range titleSearchConfigs {
+		conditions = append(conditions, fmt.Sprintf("(search_config = '%[1]s' AND search_vector @@ plainto_tsquery('%[1]s', %[2]s))", config, param))
+	}
+	return strings.Join(conditions, " OR ")
+}
+
+type MovieTitleModel struct {
+	DB querier
+}
+
+func ValidateMovieTitle(v *validator.Validator, title *MovieTitle) {
+	v.Check(title.Language != "", "language", "must be provided")
+	v.Check(validator.Matches(title.Language, validator.LanguageRX), "language", "must be a valid language code")
+	v.Check(title.Title != "", "title", "must be provided")
+	v.Check(len(title.Title) <= 500, "title", "must not be more than 500 bytes long")
+}
+
+// Upsert inserts the title for (movie, language) or replaces the existing one.
+func (m MovieTitleModel) Upsert(ctx context.Context, title *MovieTitle) error {
+	query := `
+		INSERT INTO movie_titles (movie_id, language, title, is_original)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (movie_id, language) DO UPDATE
+		SET title = EXCLUDED.title, is_original = EXCLUDED.is_original, version = movie_titles.version + 1
+		RETURNING id, version`
+
+	args := []any{title.MovieID, title.Language, title.Title, title.IsOriginal}
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&title.ID, &title.Version)
+	if err != nil {
+		switch {
+		case err.Error() == `pq: duplicate key value violates unique constraint "movie_titles_original_idx"`:
+			return ErrDuplicateOriginalTitle
+		case err.Error() == `pq: insert or update on table "movie_titles" violates foreign key constraint "movie_titles_movie_id_fkey"`:
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m MovieTitleModel) Delete(ctx context.Context, movieID int64, language string) error {
+	query := `
+		DELETE FROM movie_titles
+		WHERE movie_id = $1 AND language = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, movieID, language)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+func (m MovieTitleModel) GetAllForMovie(ctx context.Context, movieID int64) ([]*MovieTitle, error) {
+	titles, err := m.GetAllForMovies(ctx, []int64{movieID})
+	if err != nil {
+		return nil, err
+	}
+
+	if titles[movieID] == nil {
+		return []*MovieTitle{}, nil
+	}
+
+	return titles[movieID], nil
+}
+
+// GetAllForMovies loads the titles of several movies in one query, keyed by movie id,
+// so a page of movies can be localized without a query per movie.
+func (m MovieTitleModel) GetAllForMovies(ctx context.Context, movieIDs []int64) (map[int64][]*MovieTitle, error) {
+	query := `
+		SELECT id, movie_id, language, title, is_original, version
+		FROM movie_titles
+		WHERE movie_id = ANY($1)
+		ORDER BY movie_id ASC, is_original DESC, language ASC`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, pq.Array(movieIDs))
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	titles := make(map[int64][]*MovieTitle)
+
+	for rows.Next() {
+		var title MovieTitle
+		err := rows.Scan(
+			&title.ID,
+			&title.MovieID,
+			&title.Language,
+			&title.Title,
+			&title.IsOriginal,
+			&title.Version,
+		)
+		if err != nil {
+			return nil, err
+		}
+		titles[title.MovieID] = append(titles[title.MovieID], &title)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return titles, nil
+}
+
+// Localize replaces movie.Title with the best match for the preferred languages (most
+// preferred first). An exact match wins over a match on the primary subtag only, so
+// "pt-BR" prefers a "pt-BR" title and falls back to "pt". When nothing matches, the
+// original title stays in place.
+func (movie *Movie) Localize(titles []*MovieTitle, languages []string) {
+	for _, title := range titles {
+		if title.IsOriginal {
+			movie.Language = title.Language
+		}
+	}
+
+	for _, language := range languages {
+		for _, exact := range []bool{true, false} {
+			for _, title := range titles {
+				if !languageMatches(title.Language, language, exact) {
+					continue
+				}
+				if title.Title != movie.Title {
+					movie.OriginalTitle = movie.Title
+					movie.Title = title.Title
+				}
+				movie.Language = title.Language
+				return
+			}
+		}
+	}
+}
+
+func languageMatches(have, want string, exact bool) bool {
+	if strings.EqualFold(have, want) {
+		return true
+	}
+	if exact {
+		return false
+	}
+	primary := func(tag string) string {
+		return strings.ToLower(strings.SplitN(tag, "-", 2)[0])
+	}
+	return primary(have) == primary(want)
+}
@@ -0,0 +1,100 @@
+package data
+
+import (
+	"io/fs"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/shynggys9219/greenlight/internal/validator"
+	"github.com/shynggys9219/greenlight/migrations"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateMovieTitle(t *testing.T) {
+	tests := []struct {
+		name   string
+		title  MovieTitle
+		errors []string
+	}{
+		{"valid", MovieTitle{Language: "ru", Title: "Чужой"}, nil},
+		{"region subtag", MovieTitle{Language: "pt-BR", Title: "Alien, o Oitavo Passageiro"}, nil},
+		{"no language", MovieTitle{Title: "Alien"}, []string{"language"}},
+		{"bad language", MovieTitle{Language: "english", Title: "Alien"}, []string{"language"}},
+		{"no title", MovieTitle{Language: "en"}, []string{"title"}},
+		{"title too long", MovieTitle{Language: "en", Title: strings.Repeat("a", 501)}, []string{"title"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := validator.New()
+			ValidateMovieTitle(v, &tt.title)
+
+			keys := []string{}
+			for key := range v.Errors {
+				keys = append(keys, key)
+			}
+			require.ElementsMatch(t, tt.errors, keys)
+		})
+	}
+}
+
+func TestLocalize(t *testing.T) {
+	titles := []*MovieTitle{
+		{Language: "en", Title: "Alien", IsOriginal: true},
+		{Language: "ru", Title: "Чужой"},
+		{Language: "pt", Title: "Alien, o Oitavo Passageiro"},
+		{Language: "pt-PT", Title: "Alien, o 8.º Passageiro"},
+	}
+
+	tests := []struct {
+		name          string
+		languages     []string
+		title         string
+		originalTitle string
+		language      string
+	}{
+		{"no preference", nil, "Alien", "", "en"},
+		{"exact match", []string{"ru"}, "Чужой", "Alien", "ru"},
+		{"case insensitive", []string{"RU"}, "Чужой", "Alien", "ru"},
+		{"primary subtag", []string{"ru-RU"}, "Чужой", "Alien", "ru"},
+		{"exact beats primary", []string{"pt-PT"}, "Alien, o 8.º Passageiro", "Alien", "pt-PT"},
+		{"primary when no exact", []string{"pt-BR"}, "Alien, o Oitavo Passageiro", "Alien", "pt"},
+		{"first preference wins", []string{"de", "ru", "pt"}, "Чужой", "Alien", "ru"},
+		{"original language", []string{"en"}, "Alien", "", "en"},
+		{"no match keeps original", []string{"de", "fr"}, "Alien", "", "en"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			movie := &Movie{Title: "Alien"}
+			movie.Localize(titles, tt.languages)
+
+			require.Equal(t, tt.title, movie.Title)
+			require.Equal(t, tt.originalTitle, movie.OriginalTitle)
+			require.Equal(t, tt.language, movie.Language)
+		})
+	}
+
+	t.Run("no titles", func(t *testing.T) {
+		movie := &Movie{Title: "Alien"}
+		movie.Localize(nil, []string{"ru"})
+		require.Equal(t, &Movie{Title: "Alien"}, movie)
+	})
+}
+
+func TestTitleSearchConfigs(t *testing.T) {
+	// GetAll only finds titles whose search configuration is in titleSearchConfigs.
+	migration, err := fs.ReadFile(migrations.FS, "000011_create_movie_titles_table.up.sql")
+	require.NoError(t, err)
+
+	configs := []string{}
+	for _, match := range regexp.MustCompile(`'(\w+)'::regconfig`).FindAllStringSubmatch(string(migration), -1) {
+		configs = append(configs, match[1])
+	}
+	require.ElementsMatch(t, configs, titleSearchConfigs)
+
+	condition := titleSearch("$1")
+	require.Contains(t, condition, "(search_config = 'russian' AND search_vector @@ plainto_tsquery('russian', $1))")
+	require.Equal(t, len(titleSearchConfigs)-1, strings.Count(condition, " OR "))
+}
@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/lib/pq"
+	"github.com/shynggys9219/greenlight/internal/validator"
 )
 
 // By default, the keys in the JSON object are equal to the field names in the struct ( ID,
@@ -21,25 +22,121 @@ type Movie struct {
 	Genres    []string  `json:"genres,omitempty"`         // Slice of genres for the movie (romance, comedy, etc.)
 	Version   int32     `json:"version"`                  // The version number starts at 1 and will be incremented each
 	// time the movie information is updated
+	OriginalTitle string   `json:"original_title,omitempty"` // Set only when Title was replaced by a localized title
+	Language      string   `json:"language,omitempty"`       // Language of Title, when known from movie_titles
+	Images        []*Image `json:"images,omitempty"`         // Posters and stills, filled in by the API
 }
 
 // Define a MovieModel struct type which wraps a sql.DB connection pool.
 type MovieModel struct {
-	DB *sql.DB
+	DB    querier
+	Actor Actor // who changes are attributed to in the audit log, see Models.As
 }
 
 // method for inserting a new record in the movies table.
-func (m MovieModel) Insert(movie *Movie) error {
+func (m MovieModel) Insert(ctx context.Context, movie *Movie) error {
 	query := `
 		INSERT INTO movies(title, year, runtime, genres)
 		VALUES ($1, $2, $3, $4)
 		RETURNING id, created_at, version`
 
-	return m.DB.QueryRow(query, &movie.Title, &movie.Year, &movie.Runtime, pq.Array(&movie.Genres)).Scan(&movie.ID, &movie.CreatedAt, &movie.Version)
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	tx, err := beginTx(ctx, m.DB)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, query, &movie.Title, &movie.Year, &movie.Runtime, pq.Array(&movie.Genres)).Scan(&movie.ID, &movie.CreatedAt, &movie.Version)
+	if err != nil {
+		return err
+	}
+
+	changes, err := diff(nil, movie)
+	if err != nil {
+		return err
+	}
+
+	err = recordEvent(ctx, tx, m.Actor, "movie.create", "movie", movie.ID, changes)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// scanMovies reads rows of the movies table's columns in the order of "SELECT *".
+func scanMovies(rows *sql.Rows) ([]*Movie, error) {
+	defer rows.Close()
+
+	movies := []*Movie{}
+
+	for rows.Next() {
+		var movie Movie
+
+		err := rows.Scan(
+			&movie.ID,
+			&movie.CreatedAt,
+			&movie.Title,
+			&movie.Year,
+			&movie.Runtime,
+			pq.Array(&movie.Genres),
+			&movie.Version,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		movies = append(movies, &movie)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return movies, nil
+}
+
+// deleteMovies deletes the movies matching where and records their deletion.
+func (m MovieModel) deleteMovies(ctx context.Context, where string, arg any) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	tx, err := beginTx(ctx, m.DB)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, `DELETE FROM movies WHERE `+where+` RETURNING *`, arg)
+	if err != nil {
+		return 0, err
+	}
+
+	deleted, err := scanMovies(rows)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, movie := range deleted {
+		changes, err := diff(movie, nil)
+		if err != nil {
+			return 0, err
+		}
+
+		err = recordEvent(ctx, tx, m.Actor, "movie.delete", "movie", movie.ID, changes)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return len(deleted), tx.Commit()
 }
 
 // method for fetching a specific record from the movies table.
-func (m MovieModel) Get(id int64) (*Movie, error) {
+func (m MovieModel) Get(ctx context.Context, id int64) (*Movie, error) {
 	if id < 1 {
 		return nil, ErrRecordNotFound
 	}
@@ -51,7 +148,10 @@ func (m MovieModel) Get(id int64) (*Movie, error) {
 
 	var movie Movie
 
-	err := m.DB.QueryRow(query, id).Scan(
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(
 		&movie.ID,
 		&movie.CreatedAt,
 		&movie.Title,
@@ -75,94 +175,178 @@ func (m MovieModel) Get(id int64) (*Movie, error) {
 }
 
 // method for updating a specific record in the movies table.
-func (m MovieModel) Update(movie *Movie) error {
-	// query := `
-	// 	UPDATE movies
-	// 	SET title = $1, year = $2, runtime = $3, genres = $4, version = version + 1
-	// 	WHERE id = $5 AND version = $6
-	// 	RETURNING version`
-
+func (m MovieModel) Update(ctx context.Context, movie *Movie) error {
 	query := `
 		UPDATE movies
-		SET title = 'TestMovie', version = version + 1
-		WHERE title = $1
-		RETURNING version`
-
-	// args := []interface{}{
-	// 	movie.Title,
-	// 	movie.Year,
-	// 	movie.Runtime,
-	// 	pq.Array(movie.Genres),
-	// 	movie.Title,
-	// 	// movie.Version,
-	// }
+		SET title = $1, year = $2, runtime = $3, genres = $4, version = version + 1
+		WHERE id = $5 AND version = $6
+		RETURNING id, created_at, title, year, runtime, genres, version`
+
+	args := []interface{}{
+		movie.Title,
+		movie.Year,
+		movie.Runtime,
+		pq.Array(movie.Genres),
+		movie.ID,
+		movie.Version,
+	}
 
-	// err := m.DB.QueryRow(query, args...).Scan(&movie.Version)
-	// if err != nil {
-	// 	switch {
-	// 	case errors.Is(err, sql.ErrNoRows):
-	// 		return ErrEditConflict
-	// 	default:
-	// 		return err
-	// 	}
-	// }
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
 
-	err := m.DB.QueryRow(query, movie.Title).Scan(&movie.Version)
+	tx, err := beginTx(ctx, m.DB)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
 
+	rows, err := tx.QueryContext(ctx, `SELECT * FROM movies WHERE id = $1 FOR UPDATE`, movie.ID)
 	if err != nil {
-		switch {
-		case errors.Is(err, sql.ErrNoRows):
-			return ErrRecordNotFound
-		default:
-			return err
-		}
+		return err
 	}
 
-	// return m.DB.QueryRow(query, args...).Scan(&movie.Version)
-	return nil
-}
+	before, err := scanMovies(rows)
+	if err != nil {
+		return err
+	}
 
-// method for deleting a specific record from the movies table.
-func (m MovieModel) Delete(id int64) error {
-	if id < 1 {
-		return ErrRecordNotFound
+	rows, err = tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
 	}
-	// Construct the SQL query to delete the record.
-	query := `
-		DELETE FROM movies
-		WHERE id = $1`
 
-	result, err := m.DB.Exec(query, id)
+	updated, err := scanMovies(rows)
+	if err != nil {
+		return err
+	}
+
+	// No row means the movie was deleted, or changed since it was read.
+	if len(before) == 0 || len(updated) == 0 {
+		return ErrEditConflict
+	}
+	movie.Version = updated[0].Version
+
+	changes, err := diff(before[0], updated[0])
 	if err != nil {
 		return err
 	}
 
-	rowsAffected, err := result.RowsAffected()
+	err = recordEvent(ctx, tx, m.Actor, "movie.update", "movie", movie.ID, changes)
 	if err != nil {
 		return err
 	}
 
-	if rowsAffected == 0 {
+	return tx.Commit()
+}
+
+// method for deleting a specific record from the movies table.
+func (m MovieModel) Delete(ctx context.Context, id int64) error {
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+	// Delete the record, and record that in the audit log.
+	deleted, err := m.deleteMovies(ctx, `id = $1`, id)
+	if err != nil {
+		return err
+	}
+
+	if deleted == 0 {
 		return ErrRecordNotFound
 	}
 
 	return nil
 }
 
-// func (m MovieModel) GetAll(title string, genres []string, filters Filters) ([]*Movie, error) {
-func (m MovieModel) GetAll(title string, genres []string, filters Filters) ([]*Movie, error) {
+// How MovieFilter.Genres match a movie's genres.
+const (
+	GenresAll  = "all"  // the movie has every genre
+	GenresAny  = "any"  // the movie has at least one of them
+	GenresNone = "none" // the movie has none of them
+)
+
+// MovieFilter narrows down GetAll. Zero values don't filter.
+type MovieFilter struct {
+	Title         string
+	Genres        []string
+	GenresMode    string // GenresAll, GenresAny or GenresNone; GenresAll when empty
+	ExcludeGenres []string
+	YearMin       int32
+	YearMax       int32
+	RuntimeMin    int32
+	RuntimeMax    int32
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+}
+
+func ValidateMovieFilter(v *validator.Validator, filter MovieFilter) {
+	v.Check(len(filter.Title) <= 500, "title", "must not be more than 500 bytes long")
+	v.Check(filter.GenresMode == "" || validator.PermittedValue(filter.GenresMode, GenresAll, GenresAny, GenresNone), "genres_mode", "must be one of all, any or none")
+	v.Check(filter.YearMin >= 0, "year_min", "must not be negative")
+	v.Check(filter.YearMax >= 0, "year_max", "must not be negative")
+	v.Check(filter.YearMin == 0 || filter.YearMax == 0 || filter.YearMin <= filter.YearMax, "year_min", "must not be greater than year_max")
+	v.Check(filter.RuntimeMin >= 0, "runtime_min", "must not be negative")
+	v.Check(filter.RuntimeMax >= 0, "runtime_max", "must not be negative")
+	v.Check(filter.RuntimeMin == 0 || filter.RuntimeMax == 0 || filter.RuntimeMin <= filter.RuntimeMax, "runtime_min", "must not be greater than runtime_max")
+	v.Check(filter.CreatedAfter.IsZero() || filter.CreatedBefore.IsZero() || filter.CreatedAfter.Before(filter.CreatedBefore), "created_after", "must be before created_before")
+}
+
+// GetAll searches movies by title, in any of their titles, and filters them by
+// genres, year, runtime and when they were added. filters.Sort can have several
+// keys, e.g. "-year,title"; ties are broken by id.
+func (m MovieModel) GetAll(ctx context.Context, filter MovieFilter, filters Filters) ([]*Movie, error) {
+	orderBy, err := filters.orderBy()
+	if err != nil {
+		return nil, err
+	}
+
 	query := fmt.Sprintf(`
 		SELECT id, created_at, title, year, runtime, genres, version
 		FROM movies
-		WHERE (to_tsvector('simple', title) @@ plainto_tsquery('simple', $1) OR $1 = '')
-		AND (genres @> $2 OR $2 = '{}')
-		ORDER BY %s %s, id ASC
-		LIMIT $3 OFFSET $4`, filters.sortColumn(), filters.sortDirection())
+		WHERE (to_tsvector('simple', title) @@ plainto_tsquery('simple', $1)
+			OR id IN (SELECT movie_id FROM movie_titles WHERE %s)
+			OR $1 = '')
+		AND ((genres @> $2 AND $3 = 'all')
+			OR (genres && $2 AND $3 = 'any')
+			OR (NOT genres && $2 AND $3 = 'none')
+			OR $2 = '{}')
+		AND NOT genres && $4
+		AND (year >= $5 OR $5 = 0)
+		AND (year <= $6 OR $6 = 0)
+		AND (runtime >= $7 OR $7 = 0)
+		AND (runtime <= $8 OR $8 = 0)
+		AND (created_at >= $9 OR $9 IS NULL)
+		AND (created_at < $10 OR $10 IS NULL)
+		ORDER BY %s, id ASC
+		LIMIT $11 OFFSET $12`, titleSearch("$1"), orderBy)
+
+	if filter.GenresMode == "" {
+		filter.GenresMode = GenresAll
+	}
+	// pq sends nil slices as NULL, which no genres would match.
+	if filter.Genres == nil {
+		filter.Genres = []string{}
+	}
+	if filter.ExcludeGenres == nil {
+		filter.ExcludeGenres = []string{}
+	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
 	defer cancel()
 
-	args := []any{title, pq.Array(genres), filters.limit(), filters.offset()}
+	args := []any{
+		filter.Title,
+		pq.Array(filter.Genres),
+		filter.GenresMode,
+		pq.Array(filter.ExcludeGenres),
+		filter.YearMin,
+		filter.YearMax,
+		filter.RuntimeMin,
+		filter.RuntimeMax,
+		sql.NullTime{Time: filter.CreatedAfter, Valid: !filter.CreatedAfter.IsZero()},
+		sql.NullTime{Time: filter.CreatedBefore, Valid: !filter.CreatedBefore.IsZero()},
+		filters.limit(),
+		filters.offset(),
+	}
 
 	rows, err := m.DB.QueryContext(ctx, query, args...)
 	if err != nil {
@@ -197,10 +381,10 @@ func (m MovieModel) GetAll(title string, genres []string, filters Filters) ([]*M
 	return movies, nil
 }
 
-func (m MovieModel) GetAllMovies() ([]*Movie, error) {
+func (m MovieModel) GetAllMovies(ctx context.Context) ([]*Movie, error) {
 	query := `SELECT * FROM movies`
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
 	defer cancel()
 
 	// args := []any{title, pq.Array(genres), filters.limit(), filters.offset()}
@@ -238,7 +422,7 @@ func (m MovieModel) GetAllMovies() ([]*Movie, error) {
 	return movies, nil
 }
 
-func (m MovieModel) GetByTitle(title string) (*Movie, error) {
+func (m MovieModel) GetByTitle(ctx context.Context, title string) (*Movie, error) {
 	// if id < 1 {
 	// 	return nil, ErrRecordNotFound
 	// }
@@ -250,7 +434,10 @@ func (m MovieModel) GetByTitle(title string) (*Movie, error) {
 
 	var movie Movie
 
-	err := m.DB.QueryRow(query, title).Scan(
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, title).Scan(
 		&movie.ID,
 		&movie.CreatedAt,
 		&movie.Title,
@@ -273,26 +460,16 @@ func (m MovieModel) GetByTitle(title string) (*Movie, error) {
 
 }
 
-func (m MovieModel) DeleteByTitle(title string) error {
+func (m MovieModel) DeleteByTitle(ctx context.Context, title string) error {
 	// if id < 1 {
 	// 	return ErrRecordNotFound
 	// }
-	// Construct the SQL query to delete the record.
-	query := `
-		DELETE FROM movies
-		WHERE title = $1`
-
-	result, err := m.DB.Exec(query, title)
-	if err != nil {
-		return err
-	}
-
-	rowsAffected, err := result.RowsAffected()
+	deleted, err := m.deleteMovies(ctx, `title = $1`, title)
 	if err != nil {
 		return err
 	}
 
-	if rowsAffected == 0 {
+	if deleted == 0 {
 		return ErrRecordNotFound
 	}
 
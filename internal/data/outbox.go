@@ -0,0 +1,338 @@
+package data
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/shynggys9219/greenlight/internal/validator"
+)
+
+const (
+	OutboxPending = "pending"
+	OutboxSending = "sending"
+	OutboxSent    = "sent"
+	OutboxDead    = "dead"
+)
+
+// OutboxEmail is a queued email. Template and Data are handed to mailer.Mailer.Send
+// when the email is delivered. Data is cleared once the email has been sent, because
+// it can hold secrets such as plaintext activation tokens.
+type OutboxEmail struct {
+	ID            int64          `json:"id"`
+	CreatedAt     time.Time      `json:"created_at"`
+	Recipient     string         `json:"recipient"`
+	Locale        string         `json:"locale"`
+	Template      string         `json:"template"`
+	Data          map[string]any `json:"-"`
+	Status        string         `json:"status"`
+	Attempts      int32          `json:"attempts"`
+	MaxAttempts   int32          `json:"max_attempts"`
+	NextAttemptAt time.Time      `json:"next_attempt_at"`
+	LastError     string         `json:"last_error,omitempty"`
+	SentAt        *time.Time     `json:"sent_at,omitempty"`
+	Version       int32          `json:"version"`
+}
+
+type OutboxModel struct {
+	DB querier
+}
+
+func ValidateOutboxStatus(v *validator.Validator, status string) {
+	v.Check(status == "" || validator.PermittedValue(status, OutboxPending, OutboxSending, OutboxSent, OutboxDead), "status", "must be one of pending, sending, sent or dead")
+}
+
+const outboxColumns = `id, created_at, recipient, locale, template, data, status, attempts, max_attempts,
+	next_attempt_at, last_error, sent_at, version`
+
+func scanOutboxEmail(row interface{ Scan(...any) error }) (*OutboxEmail, error) {
+	var email OutboxEmail
+	var data []byte
+
+	err := row.Scan(
+		&email.ID,
+		&email.CreatedAt,
+		&email.Recipient,
+		&email.Locale,
+		&email.Template,
+		&data,
+		&email.Status,
+		&email.Attempts,
+		&email.MaxAttempts,
+		&email.NextAttemptAt,
+		&email.LastError,
+		&email.SentAt,
+		&email.Version,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// Numbers are decoded as json.Number, which templates print as they were stored.
+	// As float64 a user ID like 1234567 would be printed as 1.234567e+06.
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	err = decoder.Decode(&email.Data)
+	if err != nil {
+		return nil, err
+	}
+
+	return &email, nil
+}
+
+// Enqueue queues an email outside of any other change.
+func (m OutboxModel) Enqueue(ctx context.Context, email *OutboxEmail) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	tx, err := beginTx(ctx, m.DB)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = enqueueEmail(ctx, tx, email)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// enqueueEmail inserts the email as part of the caller's transaction.
+func enqueueEmail(ctx context.Context, tx querier, email *OutboxEmail) error {
+	data, err := json.Marshal(email.Data)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO email_outbox (recipient, locale, template, data)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at, status, attempts, max_attempts, next_attempt_at, version`
+
+	if email.Locale == "" {
+		email.Locale = "en"
+	}
+
+	return tx.QueryRowContext(ctx, query, email.Recipient, email.Locale, email.Template, data).Scan(
+		&email.ID,
+		&email.CreatedAt,
+		&email.Status,
+		&email.Attempts,
+		&email.MaxAttempts,
+		&email.NextAttemptAt,
+		&email.Version,
+	)
+}
+
+// Claim leases up to limit due emails to the calling worker. Claimed rows are moved
+// to 'sending' with next_attempt_at pushed out by lease; if the worker dies before
+// reporting back, the row becomes due again when the lease expires. SKIP LOCKED
+// lets several workers (and several API instances) claim concurrently. An expired
+// lease on an email that has used all of its attempts moves it to 'dead' instead.
+func (m OutboxModel) Claim(ctx context.Context, limit int, lease time.Duration) ([]*OutboxEmail, error) {
+	bury := `
+		UPDATE email_outbox
+		SET status = 'dead', version = version + 1
+		WHERE status = 'sending' AND next_attempt_at <= NOW() AND attempts >= max_attempts`
+
+	query := fmt.Sprintf(`
+		UPDATE email_outbox
+		SET status = 'sending', attempts = attempts + 1, next_attempt_at = $2, version = version + 1
+		WHERE id IN (
+			SELECT id
+			FROM email_outbox
+			WHERE status IN ('pending', 'sending') AND next_attempt_at <= NOW() AND attempts < max_attempts
+			ORDER BY next_attempt_at ASC
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED)
+		RETURNING %s`, outboxColumns)
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, bury)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := m.DB.QueryContext(ctx, query, limit, time.Now().Add(lease))
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	emails := []*OutboxEmail{}
+
+	for rows.Next() {
+		email, err := scanOutboxEmail(rows)
+		if err != nil {
+			return nil, err
+		}
+		emails = append(emails, email)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return emails, nil
+}
+
+func (m OutboxModel) MarkSent(ctx context.Context, email *OutboxEmail) error {
+	query := `
+		UPDATE email_outbox
+		SET status = 'sent', sent_at = NOW(), data = '{}', last_error = '', version = version + 1
+		WHERE id = $1 AND version = $2
+		RETURNING status, sent_at, version`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, email.ID, email.Version).Scan(&email.Status, &email.SentAt, &email.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+// MarkFailed records a failed delivery. The email is retried at retryAt, or moved to
+// the dead-letter state once it has used all of its attempts.
+func (m OutboxModel) MarkFailed(ctx context.Context, email *OutboxEmail, sendErr error, retryAt time.Time) error {
+	query := `
+		UPDATE email_outbox
+		SET status = CASE WHEN attempts >= max_attempts THEN 'dead' ELSE 'pending' END,
+			next_attempt_at = $1, last_error = $2, version = version + 1
+		WHERE id = $3 AND version = $4
+		RETURNING status, next_attempt_at, version`
+
+	args := []any{retryAt, sendErr.Error(), email.ID, email.Version}
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&email.Status, &email.NextAttemptAt, &email.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	email.LastError = sendErr.Error()
+
+	return nil
+}
+
+// Requeue gives a dead or pending email a fresh set of attempts, starting now. An
+// email that is being sent belongs to the worker holding its lease, and one that was
+// sent is done; both give ErrEditConflict.
+func (m OutboxModel) Requeue(ctx context.Context, email *OutboxEmail) error {
+	query := `
+		UPDATE email_outbox
+		SET status = 'pending', attempts = 0, next_attempt_at = NOW(), version = version + 1
+		WHERE id = $1 AND version = $2 AND status IN ('pending', 'dead')
+		RETURNING status, attempts, next_attempt_at, version`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, email.ID, email.Version).Scan(
+		&email.Status,
+		&email.Attempts,
+		&email.NextAttemptAt,
+		&email.Version,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m OutboxModel) Get(ctx context.Context, id int64) (*OutboxEmail, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := fmt.Sprintf(`
+		SELECT %s
+		FROM email_outbox
+		WHERE id = $1`, outboxColumns)
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	email, err := scanOutboxEmail(m.DB.QueryRowContext(ctx, query, id))
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return email, nil
+}
+
+// GetAll lists queued emails, optionally only those with the given status.
+func (m OutboxModel) GetAll(ctx context.Context, status string, filters Filters) ([]*OutboxEmail, error) {
+	orderBy, err := filters.orderBy()
+	if err != nil {
+		return nil, err
+	}
+
+	query := fmt.Sprintf(`
+		SELECT %s
+		FROM email_outbox
+		WHERE (status = $1 OR $1 = '')
+		ORDER BY %s, id ASC
+		LIMIT $2 OFFSET $3`, outboxColumns, orderBy)
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, status, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	emails := []*OutboxEmail{}
+
+	for rows.Next() {
+		email, err := scanOutboxEmail(rows)
+		if err != nil {
+			return nil, err
+		}
+		emails = append(emails, email)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return emails, nil
+}
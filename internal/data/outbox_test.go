@@ -0,0 +1,35 @@
+package data_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/shynggys9219/greenlight/internal/data"
+	"github.com/shynggys9219/greenlight/internal/testdb"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOutboxClaimStopsAtMaxAttempts(t *testing.T) {
+	models := data.NewModels(testdb.Open(t))
+
+	email := &data.OutboxEmail{Recipient: "outbox@example.com", Template: "user_welcome", Data: map[string]any{}}
+	require.NoError(t, models.Outbox.Enqueue(context.Background(), email))
+
+	// A negative lease expires at once, as if every worker died mid-send.
+	for attempt := int32(1); attempt <= email.MaxAttempts; attempt++ {
+		claimed, err := models.Outbox.Claim(context.Background(), 10, -time.Second)
+		require.NoError(t, err)
+		require.Len(t, claimed, 1)
+		require.Equal(t, attempt, claimed[0].Attempts)
+	}
+
+	claimed, err := models.Outbox.Claim(context.Background(), 10, -time.Second)
+	require.NoError(t, err)
+	require.Empty(t, claimed)
+
+	email, err = models.Outbox.Get(context.Background(), email.ID)
+	require.NoError(t, err)
+	require.Equal(t, data.OutboxDead, email.Status)
+	require.Equal(t, email.MaxAttempts, email.Attempts)
+}
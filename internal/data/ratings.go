@@ -0,0 +1,75 @@
+package data
+
+import (
+	"context"
+	"time"
+
+	"github.com/shynggys9219/greenlight/internal/validator"
+)
+
+// Rating is a user's rating of a movie, from 1 to 10. Ratings feed the co-rating
+// part of movie similarity.
+type Rating struct {
+	UserID    int64     `json:"-"`
+	MovieID   int64     `json:"movie_id"`
+	Rating    int32     `json:"rating"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type RatingModel struct {
+	DB querier
+}
+
+func ValidateRating(v *validator.Validator, rating *Rating) {
+	v.Check(rating.Rating >= 1 && rating.Rating <= 10, "rating", "must be between 1 and 10")
+}
+
+// Upsert records the user's rating of the movie, replacing an earlier one.
+func (m RatingModel) Upsert(ctx context.Context, rating *Rating) error {
+	query := `
+		INSERT INTO movie_ratings (user_id, movie_id, rating)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (user_id, movie_id) DO UPDATE
+		SET rating = EXCLUDED.rating, updated_at = NOW()
+		RETURNING updated_at`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, rating.UserID, rating.MovieID, rating.Rating).Scan(&rating.UpdatedAt)
+	if err != nil {
+		switch {
+		case err.Error() == `pq: insert or update on table "movie_ratings" violates foreign key constraint "movie_ratings_movie_id_fkey"`:
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m RatingModel) Delete(ctx context.Context, userID, movieID int64) error {
+	query := `
+		DELETE FROM movie_ratings
+		WHERE user_id = $1 AND movie_id = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, userID, movieID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
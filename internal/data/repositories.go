@@ -0,0 +1,176 @@
+package data
+
+import (
+	"context"
+	"time"
+)
+
+// The repositories are what Models holds. MovieModel and the other *Model types
+// implement them on PostgreSQL; the memstore package implements them in memory for
+// tests.
+
+type MovieRepository interface {
+	Insert(ctx context.Context, movie *Movie) error
+	Get(ctx context.Context, id int64) (*Movie, error)
+	Update(ctx context.Context, movie *Movie) error
+	Delete(ctx context.Context, id int64) error
+	GetAll(ctx context.Context, filter MovieFilter, filters Filters) ([]*Movie, error)
+	GetAllMovies(ctx context.Context) ([]*Movie, error)
+	GetByTitle(ctx context.Context, title string) (*Movie, error)
+	DeleteByTitle(ctx context.Context, title string) error
+}
+
+type DirectorRepository interface {
+	Insert(ctx context.Context, director *Director) error
+	Get(ctx context.Context, id int64) (*Director, error)
+	Update(ctx context.Context, director *Director) error
+	Delete(ctx context.Context, id int64) error
+	GetAll(ctx context.Context, name string, surname string, awards []string, filters Filters) ([]*Director, error)
+	GetOneByName(ctx context.Context, name string, filters Filters) ([]*Director, error)
+}
+
+type UserRepository interface {
+	Insert(ctx context.Context, user *User) error
+	InsertWithActivation(ctx context.Context, user *User, ttl time.Duration, templateName string) (*Token, error)
+	Get(ctx context.Context, id int64) (*User, error)
+	GetByEmain(ctx context.Context, email string) (*User, error)
+	GetForToken(ctx context.Context, tokenScope, tokenPlaintext string) (*User, error)
+	GetAll(ctx context.Context, filter UserFilter, filters Filters) ([]*User, error)
+	Update(ctx context.Context, user *User) error
+	RequestEmailChange(ctx context.Context, user *User, newEmail string, ttl time.Duration, templateName string) (*Token, error)
+	LockOut(ctx context.Context, user *User, until time.Time, ttl time.Duration, templateName string) (*Token, error)
+	Anonymize(ctx context.Context, user *User) error
+	Delete(ctx context.Context, user *User) error
+	DeleteUnactivated(ctx context.Context, registeredBefore time.Time) (int64, error)
+}
+
+type TokenRepository interface {
+	New(ctx context.Context, userID int64, ttl time.Duration, scope string) (*Token, error)
+	Insert(ctx context.Context, token *Token) error
+	DeleteAllForUser(ctx context.Context, scope string, userID int64) error
+	DeleteExpired(ctx context.Context) (int64, error)
+}
+
+type RoleRepository interface {
+	Insert(ctx context.Context, role *Role) error
+	InsertUserRole(ctx context.Context, role *Role) error
+	NewRole(ctx context.Context, roleID int64, roleName string, userID int64) (*Role, error)
+	GetAllForUser(ctx context.Context, userID int64) ([]string, error)
+}
+
+type PersonRepository interface {
+	Insert(ctx context.Context, person *Person) error
+	Get(ctx context.Context, id int64) (*Person, error)
+	Update(ctx context.Context, person *Person) error
+	Delete(ctx context.Context, id int64) error
+	GetAll(ctx context.Context, name string, filters Filters) ([]*Person, error)
+}
+
+type CreditRepository interface {
+	Insert(ctx context.Context, credit *Credit) error
+	Get(ctx context.Context, movieID, id int64) (*Credit, error)
+	Update(ctx context.Context, credit *Credit) error
+	Delete(ctx context.Context, movieID, id int64) error
+	GetAllForMovie(ctx context.Context, movieID int64) ([]*Credit, error)
+	GetFilmography(ctx context.Context, personID int64) ([]*FilmographyEntry, error)
+}
+
+type MovieTitleRepository interface {
+	Upsert(ctx context.Context, title *MovieTitle) error
+	Delete(ctx context.Context, movieID int64, language string) error
+	GetAllForMovie(ctx context.Context, movieID int64) ([]*MovieTitle, error)
+	GetAllForMovies(ctx context.Context, movieIDs []int64) (map[int64][]*MovieTitle, error)
+}
+
+type ImageRepository interface {
+	Insert(ctx context.Context, image *Image) error
+	Get(ctx context.Context, movieID, id int64) (*Image, error)
+	Delete(ctx context.Context, movieID, id int64) error
+	GetAllForMovies(ctx context.Context, movieIDs []int64) (map[int64][]*Image, error)
+}
+
+type OutboxRepository interface {
+	Enqueue(ctx context.Context, email *OutboxEmail) error
+	Claim(ctx context.Context, limit int, lease time.Duration) ([]*OutboxEmail, error)
+	MarkSent(ctx context.Context, email *OutboxEmail) error
+	MarkFailed(ctx context.Context, email *OutboxEmail, sendErr error, retryAt time.Time) error
+	Requeue(ctx context.Context, email *OutboxEmail) error
+	Get(ctx context.Context, id int64) (*OutboxEmail, error)
+	GetAll(ctx context.Context, status string, filters Filters) ([]*OutboxEmail, error)
+}
+
+type LoginAttemptRepository interface {
+	Insert(ctx context.Context, attempt *LoginAttempt) error
+	FailuresForEmail(ctx context.Context, email string, since time.Time) (LoginFailures, error)
+	FailuresForIP(ctx context.Context, ip string, since time.Time) (LoginFailures, error)
+	ClearEmail(ctx context.Context, email string) error
+	ClearIP(ctx context.Context, ip string) error
+}
+
+type TOTPRepository interface {
+	Get(ctx context.Context, userID int64) (*TOTPCredential, error)
+	Enroll(ctx context.Context, userID int64) (*TOTPCredential, error)
+	Enable(ctx context.Context, credential *TOTPCredential, code string) (codes []string, ok bool, err error)
+	Verify(ctx context.Context, credential *TOTPCredential, code string) (bool, error)
+	UseRecoveryCode(ctx context.Context, userID int64, code string) (bool, error)
+	Disable(ctx context.Context, userID int64) error
+}
+
+type APIKeyRepository interface {
+	Insert(ctx context.Context, key *APIKey) error
+	GetForPlaintext(ctx context.Context, plaintext string) (*APIKey, error)
+	GetAllForUser(ctx context.Context, userID int64) ([]*APIKey, error)
+	Delete(ctx context.Context, id, userID int64) error
+}
+
+type SessionRepository interface {
+	New(ctx context.Context, session *Session, accessTTL, refreshTTL time.Duration) (access, refresh *Token, err error)
+	Refresh(ctx context.Context, plaintext, userAgent, ip string, accessTTL, refreshTTL time.Duration) (session *Session, access, refresh *Token, err error)
+	GetForToken(ctx context.Context, plaintext string) (*Session, error)
+	GetAllForUser(ctx context.Context, userID int64) ([]*Session, error)
+	Delete(ctx context.Context, id, userID int64) error
+	DeleteAllForUser(ctx context.Context, userID int64) error
+	DeleteExpired(ctx context.Context) (int64, error)
+}
+
+type JobRunRepository interface {
+	Insert(ctx context.Context, run *JobRun) error
+	GetAll(ctx context.Context, job string, filters Filters) ([]*JobRun, error)
+}
+
+type AuditRepository interface {
+	GetAll(ctx context.Context, filter AuditFilter, filters Filters) ([]*AuditEvent, error)
+}
+
+type RatingRepository interface {
+	Upsert(ctx context.Context, rating *Rating) error
+	Delete(ctx context.Context, userID, movieID int64) error
+}
+
+type SimilarityRepository interface {
+	Compute(ctx context.Context, movieID int64, limit int) ([]*SimilarMovie, error)
+	GetForMovie(ctx context.Context, movieID int64, limit int) ([]*SimilarMovie, error)
+	Refresh(ctx context.Context, movieID int64) error
+	RefreshAll(ctx context.Context) (int64, error)
+}
+
+var (
+	_ MovieRepository        = MovieModel{}
+	_ DirectorRepository     = DirectorModel{}
+	_ UserRepository         = UserModel{}
+	_ TokenRepository        = TokenModel{}
+	_ RoleRepository         = RoleModel{}
+	_ PersonRepository       = PersonModel{}
+	_ CreditRepository       = CreditModel{}
+	_ MovieTitleRepository   = MovieTitleModel{}
+	_ ImageRepository        = ImageModel{}
+	_ OutboxRepository       = OutboxModel{}
+	_ LoginAttemptRepository = LoginAttemptModel{}
+	_ TOTPRepository         = TOTPModel{}
+	_ APIKeyRepository       = APIKeyModel{}
+	_ SessionRepository      = SessionModel{}
+	_ JobRunRepository       = JobRunModel{}
+	_ AuditRepository        = AuditModel{}
+	_ RatingRepository       = RatingModel{}
+	_ SimilarityRepository   = SimilarityModel{}
+)
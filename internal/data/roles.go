@@ -1,5 +1,27 @@
 package data
 
+import (
+	"context"
+	"errors"
+
+	"github.com/shynggys9219/greenlight/internal/validator"
+)
+
+var ErrUnknownUser = errors.New("unknown user")
+
+// The role names the API checks for.
+const (
+	RoleAdmin = "admin"
+)
+
+var Roles = []string{RoleAdmin}
+
+func ValidateRole(v *validator.Validator, role *Role) {
+	v.Check(role.RoleName != "", "role_name", "must be provided")
+	v.Check(role.RoleName == "" || validator.PermittedValue(role.RoleName, Roles...), "role_name", "must be admin")
+	v.Check(role.UserID > 0, "user_id", "must be provided")
+}
+
 // type Role struct {
 // 	ID       int64  `json:"id"`
 // 	RoleName string `json:"role_name"`
@@ -11,13 +33,48 @@ package data
 // 	DB *sql.DB
 // }
 
-func (m RoleModel) Insert(role *Role) error {
+func (m RoleModel) Insert(ctx context.Context, role *Role) error {
 	query := `
 		INSERT INTO roles(role_name, user_id)
 		VALUES ($1, $2)
-		RETURNING id, created_at, version`
+		RETURNING id`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	tx, err := beginTx(ctx, m.DB)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, query, &role.RoleName, &role.UserID).Scan(&role.ID)
+	if err != nil {
+		switch {
+		case err.Error() == `pq: insert or update on table "roles" violates foreign key constraint "roles_user_id_fkey"`:
+			return ErrUnknownUser
+		default:
+			return err
+		}
+	}
+
+	err = m.recordGrant(ctx, tx, role)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
 
-	return m.DB.QueryRow(query, &role.RoleName, &role.UserID).Scan(&role.ID, &role.RoleName, &role.UserID)
+// recordGrant records in the audit log that a role was granted. The event is about
+// the role row, so it can be found by resource_type "role" and the role's id.
+func (m RoleModel) recordGrant(ctx context.Context, tx querier, role *Role) error {
+	changes, err := diff(nil, role)
+	if err != nil {
+		return err
+	}
+
+	return recordEvent(ctx, tx, m.Actor, "role.grant", "role", role.ID, changes)
 }
 
 func Create(roleID int64, roleName string, userID int64) (*Role, error) {
@@ -30,11 +87,46 @@ func Create(roleID int64, roleName string, userID int64) (*Role, error) {
 	return role, nil
 }
 
-func (m RoleModel) NewRole(roleID int64, roleName string, userID int64) (*Role, error) {
+func (m RoleModel) NewRole(ctx context.Context, roleID int64, roleName string, userID int64) (*Role, error) {
 	role, err := Create(roleID, roleName, userID)
 	if err != nil {
 		return nil, err
 	}
-	err = m.Insert(role)
+	err = m.Insert(ctx, role)
 	return role, err
 }
+
+// GetAllForUser returns the names of every role granted to the user.
+func (m RoleModel) GetAllForUser(ctx context.Context, userID int64) ([]string, error) {
+	query := `
+		SELECT DISTINCT role_name
+		FROM roles
+		WHERE user_id = $1
+		ORDER BY role_name`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	roles := []string{}
+
+	for rows.Next() {
+		var role string
+		if err := rows.Scan(&role); err != nil {
+			return nil, err
+		}
+		roles = append(roles, role)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return roles, nil
+}
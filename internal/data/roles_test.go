@@ -0,0 +1,26 @@
+package data_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/shynggys9219/greenlight/internal/data"
+	"github.com/shynggys9219/greenlight/internal/testdb"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewRole(t *testing.T) {
+	models := data.NewModels(testdb.Open(t))
+
+	user := testdb.User(t, models)
+
+	role, err := models.Role.NewRole(context.Background(), 0, data.RoleAdmin, user.ID)
+	require.NoError(t, err)
+	require.NotZero(t, role.ID)
+	require.Equal(t, data.RoleAdmin, role.RoleName)
+	require.Equal(t, user.ID, role.UserID)
+
+	roles, err := models.Role.GetAllForUser(context.Background(), user.ID)
+	require.NoError(t, err)
+	require.Equal(t, []string{data.RoleAdmin}, roles)
+}
@@ -0,0 +1,349 @@
+package data
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// ErrTokenReused is returned by SessionModel.Refresh when a refresh token is presented
+// a second time. Only one of the two parties holding it can be the legitimate
+// client, so the whole session has been revoked.
+var ErrTokenReused = errors.New("refresh token reused")
+
+// sessionLastSeenPrecision limits how often last_seen_at is written for a busy session.
+const sessionLastSeenPrecision = time.Minute
+
+// Session is one login on one device. It holds short-lived access tokens and a chain
+// of refresh tokens, each replaced by the next when used.
+type Session struct {
+	ID         int64     `json:"id"`
+	UserID     int64     `json:"-"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	UserAgent  string    `json:"user_agent"`
+	IP         string    `json:"ip"`
+}
+
+type SessionModel struct {
+	DB querier
+}
+
+// New starts a session and returns its first access and refresh token. With an
+// accessTTL of 0 no access token is stored and access is nil; that is how stateless
+// authentication works, where access tokens are signed instead.
+func (m SessionModel) New(ctx context.Context, session *Session, accessTTL, refreshTTL time.Duration) (access, refresh *Token, err error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	tx, err := beginTx(ctx, m.DB)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO sessions (user_id, user_agent, ip)
+		VALUES ($1, $2, $3)
+		RETURNING id, created_at, last_seen_at`
+
+	err = tx.QueryRowContext(ctx, query, session.UserID, session.UserAgent, session.IP).Scan(&session.ID, &session.CreatedAt, &session.LastSeenAt)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	access, refresh, err = insertSessionTokens(ctx, tx, session, accessTTL, refreshTTL)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return access, refresh, tx.Commit()
+}
+
+// Refresh exchanges a refresh token for a new access and refresh token. The old
+// refresh token is marked used; presenting it again revokes the session and returns
+// ErrTokenReused. userAgent and ip update the session's details.
+func (m SessionModel) Refresh(ctx context.Context, plaintext, userAgent, ip string, accessTTL, refreshTTL time.Duration) (session *Session, access, refresh *Token, err error) {
+	hash := sha256.Sum256([]byte(plaintext))
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	tx, err := beginTx(ctx, m.DB)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	defer tx.Rollback()
+
+	// FOR UPDATE makes a concurrent refresh with the same token wait, and then see
+	// it as used.
+	query := `
+		SELECT session_id, used
+		FROM refresh_tokens
+		WHERE hash = $1 AND expiry > $2
+		FOR UPDATE`
+
+	var sessionID int64
+	var used bool
+
+	err = tx.QueryRowContext(ctx, query, hash[:], time.Now()).Scan(&sessionID, &used)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, nil, nil, ErrRecordNotFound
+		default:
+			return nil, nil, nil, err
+		}
+	}
+
+	if used {
+		_, err = tx.ExecContext(ctx, `DELETE FROM sessions WHERE id = $1`, sessionID)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+
+		err = tx.Commit()
+		if err != nil {
+			return nil, nil, nil, err
+		}
+
+		return nil, nil, nil, ErrTokenReused
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE refresh_tokens SET used = true WHERE hash = $1`, hash[:])
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	query = `
+		UPDATE sessions
+		SET last_seen_at = NOW(), user_agent = $1, ip = $2
+		WHERE id = $3
+		RETURNING id, user_id, created_at, last_seen_at, user_agent, ip`
+
+	session = &Session{}
+
+	err = tx.QueryRowContext(ctx, query, userAgent, ip, sessionID).Scan(
+		&session.ID,
+		&session.UserID,
+		&session.CreatedAt,
+		&session.LastSeenAt,
+		&session.UserAgent,
+		&session.IP,
+	)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	access, refresh, err = insertSessionTokens(ctx, tx, session, accessTTL, refreshTTL)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	return session, access, refresh, tx.Commit()
+}
+
+func insertSessionTokens(ctx context.Context, tx querier, session *Session, accessTTL, refreshTTL time.Duration) (access, refresh *Token, err error) {
+	if accessTTL > 0 {
+		access, err = GenerateToken(session.UserID, accessTTL, ScopeAuthentication)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		query := `
+			INSERT INTO tokens (hash, user_id, expiry, scope, session_id)
+			VALUES ($1, $2, $3, $4, $5)`
+
+		_, err = tx.ExecContext(ctx, query, access.Hash, access.UserID, access.Expiry, access.Scope, session.ID)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	refresh, err = GenerateToken(session.UserID, refreshTTL, ScopeRefresh)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	query := `
+		INSERT INTO refresh_tokens (hash, session_id, expiry)
+		VALUES ($1, $2, $3)`
+
+	_, err = tx.ExecContext(ctx, query, refresh.Hash, session.ID, refresh.Expiry)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return access, refresh, nil
+}
+
+// GetForToken returns the session an access token belongs to and records that the
+// session was seen. Tokens issued outside a session give ErrRecordNotFound.
+func (m SessionModel) GetForToken(ctx context.Context, plaintext string) (*Session, error) {
+	hash := sha256.Sum256([]byte(plaintext))
+
+	query := `
+		SELECT sessions.id, sessions.user_id, sessions.created_at, sessions.last_seen_at, sessions.user_agent, sessions.ip
+		FROM sessions
+		INNER JOIN tokens
+		ON sessions.id = tokens.session_id
+		WHERE tokens.hash = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	var session Session
+
+	err := m.DB.QueryRowContext(ctx, query, hash[:]).Scan(
+		&session.ID,
+		&session.UserID,
+		&session.CreatedAt,
+		&session.LastSeenAt,
+		&session.UserAgent,
+		&session.IP,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	now := time.Now()
+	if now.Sub(session.LastSeenAt) > sessionLastSeenPrecision {
+		_, err = m.DB.ExecContext(ctx, `UPDATE sessions SET last_seen_at = $1 WHERE id = $2`, now, session.ID)
+		if err != nil {
+			return nil, err
+		}
+		session.LastSeenAt = now
+	}
+
+	return &session, nil
+}
+
+// GetAllForUser lists the user's sessions that can still be refreshed, most recently
+// seen first.
+func (m SessionModel) GetAllForUser(ctx context.Context, userID int64) ([]*Session, error) {
+	query := `
+		SELECT id, user_id, created_at, last_seen_at, user_agent, ip
+		FROM sessions
+		WHERE user_id = $1
+		AND EXISTS (
+			SELECT 1 FROM refresh_tokens
+			WHERE refresh_tokens.session_id = sessions.id AND NOT used AND expiry > $2)
+		ORDER BY last_seen_at DESC, id DESC`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, userID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []*Session{}
+
+	for rows.Next() {
+		var session Session
+
+		err := rows.Scan(
+			&session.ID,
+			&session.UserID,
+			&session.CreatedAt,
+			&session.LastSeenAt,
+			&session.UserAgent,
+			&session.IP,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		sessions = append(sessions, &session)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return sessions, nil
+}
+
+// Delete ends one of the user's sessions, revoking its tokens. Sessions of other users
+// are reported as ErrRecordNotFound.
+func (m SessionModel) Delete(ctx context.Context, id, userID int64) error {
+	query := `
+		DELETE FROM sessions
+		WHERE id = $1 AND user_id = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, id, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// DeleteAllForUser ends every session of the user.
+func (m SessionModel) DeleteAllForUser(ctx context.Context, userID int64) error {
+	query := `
+		DELETE FROM sessions
+		WHERE user_id = $1`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, userID)
+	return err
+}
+
+// DeleteExpired removes expired refresh tokens and the sessions left without one that
+// could still be used, and returns how many rows went.
+func (m SessionModel) DeleteExpired(ctx context.Context) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, `DELETE FROM refresh_tokens WHERE expiry < $1`, time.Now())
+	if err != nil {
+		return 0, err
+	}
+
+	tokens, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	query := `
+		DELETE FROM sessions
+		WHERE NOT EXISTS (
+			SELECT 1 FROM refresh_tokens
+			WHERE refresh_tokens.session_id = sessions.id AND NOT used)`
+
+	result, err = m.DB.ExecContext(ctx, query)
+	if err != nil {
+		return 0, err
+	}
+
+	sessions, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return tokens + sessions, nil
+}
@@ -0,0 +1,315 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// The weights of the parts of a similarity score. They add up to 1, so scores are
+// between 0 and 1.
+const (
+	WeightGenres    = 0.45
+	WeightDirectors = 0.25
+	WeightYear      = 0.10
+	WeightRatings   = 0.20
+)
+
+// MaxSimilarMovies is how many neighbors are kept per movie, and so the most that can
+// be asked for.
+const MaxSimilarMovies = 50
+
+const (
+	yearScale    = 10.0 // years apart at which year proximity has dropped to 1/e
+	minCoRatings = 2    // users who must have rated both movies before ratings count
+)
+
+// ScorePart is one signal of a similarity score: how similar the movies are in it,
+// from 0 to 1, and how much that adds to the score with the signal's weight.
+type ScorePart struct {
+	Similarity   float64 `json:"similarity"`
+	Weight       float64 `json:"weight"`
+	Contribution float64 `json:"contribution"`
+}
+
+// ScoreBreakdown explains a similarity score: it is the sum of the contributions.
+type ScoreBreakdown struct {
+	Genres    ScorePart `json:"genres"`    // Jaccard index of the genres
+	Directors ScorePart `json:"directors"` // Jaccard index of the credited directors
+	Year      ScorePart `json:"year"`      // exp(-years apart / 10), 0 if a year is unknown
+	Ratings   ScorePart `json:"ratings"`   // cosine similarity of the ratings, 0 with fewer than 2 co-raters
+}
+
+func newScorePart(similarity, weight float64) ScorePart {
+	return ScorePart{Similarity: similarity, Weight: weight, Contribution: similarity * weight}
+}
+
+// SimilarMovie is a movie ranked by its similarity to another one.
+type SimilarMovie struct {
+	Movie      *Movie         `json:"movie"`
+	Score      float64        `json:"score"`
+	Breakdown  ScoreBreakdown `json:"breakdown"`
+	ComputedAt *time.Time     `json:"computed_at,omitempty"` // nil when computed for the request
+}
+
+type SimilarityModel struct {
+	DB querier
+}
+
+// similarityQuery scores the movies similar to movie $1 and returns the ids of the
+// best $2 with the parts of their score. Candidates share a genre (found through the GIN
+// index on genres), a director or a rater with the movie; closeness in years alone
+// doesn't make a movie similar.
+const similarityQuery = `
+	WITH target AS (
+		SELECT id, year, genres
+		FROM movies
+		WHERE id = $1
+	),
+	target_directors AS (
+		SELECT person_id
+		FROM credits
+		WHERE movie_id = $1 AND role = 'director'
+	),
+	target_ratings AS (
+		SELECT user_id, rating
+		FROM movie_ratings
+		WHERE movie_id = $1
+	),
+	candidates AS (
+		SELECT movies.id
+		FROM movies, target
+		WHERE movies.genres && target.genres AND movies.id <> target.id
+		UNION
+		SELECT credits.movie_id
+		FROM credits
+		INNER JOIN target_directors ON target_directors.person_id = credits.person_id
+		WHERE credits.role = 'director' AND credits.movie_id <> $1
+		UNION
+		SELECT movie_ratings.movie_id
+		FROM movie_ratings
+		INNER JOIN target_ratings ON target_ratings.user_id = movie_ratings.user_id
+		WHERE movie_ratings.movie_id <> $1
+	),
+	parts AS (
+		SELECT movies.id,
+			COALESCE(
+				cardinality(ARRAY(SELECT unnest(movies.genres) INTERSECT SELECT unnest(target.genres)))::float8
+				/ NULLIF(cardinality(ARRAY(SELECT unnest(movies.genres) UNION SELECT unnest(target.genres))), 0),
+				0) AS genres,
+			COALESCE(
+				(SELECT count(*)
+					FROM credits
+					INNER JOIN target_directors ON target_directors.person_id = credits.person_id
+					WHERE credits.movie_id = movies.id AND credits.role = 'director')::float8
+				/ NULLIF((SELECT count(*) FROM (
+					SELECT person_id FROM credits WHERE movie_id = movies.id AND role = 'director'
+					UNION
+					SELECT person_id FROM target_directors) AS directors), 0),
+				0) AS directors,
+			CASE WHEN movies.year > 0 AND target.year > 0
+				THEN exp(-abs(movies.year - target.year) / $3::float8)
+				ELSE 0
+			END AS year,
+			COALESCE(
+				(SELECT sum(movie_ratings.rating * target_ratings.rating)::float8
+					/ (sqrt((SELECT sum(rating * rating) FROM movie_ratings AS other WHERE other.movie_id = movies.id)::float8)
+						* sqrt((SELECT sum(rating * rating) FROM target_ratings)::float8))
+					FROM movie_ratings
+					INNER JOIN target_ratings ON target_ratings.user_id = movie_ratings.user_id
+					WHERE movie_ratings.movie_id = movies.id
+					HAVING count(*) >= $4),
+				0) AS ratings
+		FROM movies
+		INNER JOIN candidates ON candidates.id = movies.id
+		CROSS JOIN target
+	)
+	SELECT id, genres, directors, year, ratings,
+		$5 * genres + $6 * directors + $7 * year + $8 * ratings AS score
+	FROM parts
+	ORDER BY score DESC, id ASC
+	LIMIT $2`
+
+func similarityArgs(movieID int64, limit int) []any {
+	return []any{movieID, limit, yearScale, minCoRatings, WeightGenres, WeightDirectors, WeightYear, WeightRatings}
+}
+
+// scanSimilarMovies reads rows of the movie's columns followed by genres, directors,
+// year, ratings and computed_at.
+func scanSimilarMovies(rows *sql.Rows) ([]*SimilarMovie, error) {
+	defer rows.Close()
+
+	similar := []*SimilarMovie{}
+
+	for rows.Next() {
+		var movie Movie
+		var genres, directors, year, ratings float64
+		var computedAt sql.NullTime
+
+		err := rows.Scan(
+			&movie.ID,
+			&movie.CreatedAt,
+			&movie.Title,
+			&movie.Year,
+			&movie.Runtime,
+			pq.Array(&movie.Genres),
+			&movie.Version,
+			&genres,
+			&directors,
+			&year,
+			&ratings,
+			&computedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		s := &SimilarMovie{
+			Movie: &movie,
+			Breakdown: ScoreBreakdown{
+				Genres:    newScorePart(genres, WeightGenres),
+				Directors: newScorePart(directors, WeightDirectors),
+				Year:      newScorePart(year, WeightYear),
+				Ratings:   newScorePart(ratings, WeightRatings),
+			},
+		}
+		s.Score = s.Breakdown.Genres.Contribution + s.Breakdown.Directors.Contribution +
+			s.Breakdown.Year.Contribution + s.Breakdown.Ratings.Contribution
+
+		if computedAt.Valid {
+			s.ComputedAt = &computedAt.Time
+		}
+
+		similar = append(similar, s)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return similar, nil
+}
+
+// Compute scores the movies similar to the given one now, best first.
+func (m SimilarityModel) Compute(ctx context.Context, movieID int64, limit int) ([]*SimilarMovie, error) {
+	query := `
+		SELECT movies.id, movies.created_at, movies.title, movies.year, movies.runtime, movies.genres, movies.version,
+			scored.genres, scored.directors, scored.year, scored.ratings, NULL::timestamptz
+		FROM (` + similarityQuery + `) AS scored
+		INNER JOIN movies ON movies.id = scored.id
+		ORDER BY scored.score DESC, scored.id ASC`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, similarityArgs(movieID, limit)...)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanSimilarMovies(rows)
+}
+
+// GetForMovie returns the neighbors stored by Refresh, best first. The list is empty
+// for movies that haven't been refreshed yet, and for those without neighbors.
+func (m SimilarityModel) GetForMovie(ctx context.Context, movieID int64, limit int) ([]*SimilarMovie, error) {
+	query := `
+		SELECT movies.id, movies.created_at, movies.title, movies.year, movies.runtime, movies.genres, movies.version,
+			stored.genres, stored.directors, stored.year, stored.ratings, stored.computed_at
+		FROM movie_similarities AS stored
+		INNER JOIN movies ON movies.id = stored.similar_movie_id
+		WHERE stored.movie_id = $1
+		ORDER BY stored.rank ASC
+		LIMIT $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, movieID, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanSimilarMovies(rows)
+}
+
+// Refresh replaces the stored neighbors of the movie with the best MaxSimilarMovies
+// as scored now.
+func (m SimilarityModel) Refresh(ctx context.Context, movieID int64) error {
+	query := `
+		INSERT INTO movie_similarities (movie_id, similar_movie_id, rank, score, genres, directors, year, ratings)
+		SELECT $1, id, row_number() OVER (ORDER BY score DESC, id ASC), score, genres, directors, year, ratings
+		FROM (` + similarityQuery + `) AS scored`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	tx, err := beginTx(ctx, m.DB)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM movie_similarities WHERE movie_id = $1`, movieID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, query, similarityArgs(movieID, MaxSimilarMovies)...)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// RefreshAll refreshes the neighbors of every movie and returns how many movies it
+// refreshed. Each movie has a transaction of its own, so readers never wait long and
+// a cancelled run keeps what it has done.
+func (m SimilarityModel) RefreshAll(ctx context.Context) (int64, error) {
+	ids, err := m.movieIDs(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	var refreshed int64
+
+	for _, id := range ids {
+		err := m.Refresh(ctx, id)
+		if err != nil {
+			return refreshed, err
+		}
+		refreshed++
+	}
+
+	return refreshed, nil
+}
+
+func (m SimilarityModel) movieIDs(ctx context.Context) ([]int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, `SELECT id FROM movies ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return ids, nil
+}
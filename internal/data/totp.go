@@ -0,0 +1,354 @@
+package data
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/shynggys9219/greenlight/internal/validator"
+)
+
+// TOTP as in RFC 6238 with the parameters every authenticator app supports: HMAC-SHA1,
+// six digits and 30 second steps.
+const (
+	totpDigits = 6
+	totpPeriod = 30
+	// totpSkew is how many steps before and after the current one are accepted, to
+	// allow for clock drift between the server and the user's phone.
+	totpSkew = 1
+
+	recoveryCodeCount = 10
+)
+
+var ErrTOTPEnabled = errors.New("two-factor authentication is already enabled")
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// TOTPCredential is a user's TOTP secret. It exists from the start of enrollment but
+// is only Enabled once the user proved their app has the secret.
+type TOTPCredential struct {
+	UserID    int64
+	CreatedAt time.Time
+	Secret    []byte
+	Enabled   bool
+	lastStep  int64
+}
+
+// SecretString returns the secret the way users type it into an authenticator app.
+func (c *TOTPCredential) SecretString() string {
+	return totpEncoding.EncodeToString(c.Secret)
+}
+
+// URI returns the otpauth:// URI for the secret, usually shown as a QR code.
+func (c *TOTPCredential) URI(issuer, account string) string {
+	query := url.Values{}
+	query.Set("secret", c.SecretString())
+	query.Set("issuer", issuer)
+	query.Set("algorithm", "SHA1")
+	query.Set("digits", fmt.Sprint(totpDigits))
+	query.Set("period", fmt.Sprint(totpPeriod))
+
+	uri := url.URL{
+		Scheme:   "otpauth",
+		Host:     "totp",
+		Path:     "/" + issuer + ":" + account,
+		RawQuery: query.Encode(),
+	}
+
+	return uri.String()
+}
+
+// match returns the time step code belongs to. Steps up to lastStep were used already
+// and don't match again.
+func (c *TOTPCredential) match(code string, now time.Time) (int64, bool) {
+	current := now.Unix() / totpPeriod
+
+	for step := current - totpSkew; step <= current+totpSkew; step++ {
+		if step <= c.lastStep {
+			continue
+		}
+		if subtle.ConstantTimeCompare([]byte(totpCode(c.Secret, step)), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+
+	return 0, false
+}
+
+// totpCode is the HOTP value (RFC 4226) of secret for the counter step.
+func totpCode(secret []byte, step int64) string {
+	var counter [8]byte
+	binary.BigEndian.PutUint64(counter[:], uint64(step))
+
+	mac := hmac.New(sha1.New, secret)
+	mac.Write(counter[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	return fmt.Sprintf("%0*d", totpDigits, value%1000000)
+}
+
+func ValidateTOTPCode(v *validator.Validator, code string) {
+	v.Check(code != "", "code", "must be provided")
+	v.Check(len(code) == totpDigits && strings.Trim(code, "0123456789") == "", "code", "must be 6 digits")
+}
+
+// normalizeRecoveryCode accepts recovery codes with or without the dash and in any
+// case, the way people copy them from wherever they stored them.
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	code = strings.ReplaceAll(code, "-", "")
+	return strings.ReplaceAll(code, " ", "")
+}
+
+func hashRecoveryCode(code string) []byte {
+	hash := sha256.Sum256([]byte(normalizeRecoveryCode(code)))
+	return hash[:]
+}
+
+func generateRecoveryCode() (string, error) {
+	randomBytes := make([]byte, 10)
+
+	_, err := rand.Read(randomBytes)
+	if err != nil {
+		return "", err
+	}
+
+	code := strings.ToLower(totpEncoding.EncodeToString(randomBytes))
+	return code[:8] + "-" + code[8:], nil
+}
+
+type TOTPModel struct {
+	DB querier
+}
+
+func (m TOTPModel) Get(ctx context.Context, userID int64) (*TOTPCredential, error) {
+	query := `
+		SELECT user_id, created_at, secret, enabled, last_step
+		FROM totp_credentials
+		WHERE user_id = $1`
+
+	var credential TOTPCredential
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, userID).Scan(
+		&credential.UserID,
+		&credential.CreatedAt,
+		&credential.Secret,
+		&credential.Enabled,
+		&credential.lastStep,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &credential, nil
+}
+
+// Enroll starts enrollment with a new random secret, replacing the secret of an
+// enrollment that was never confirmed. It returns ErrTOTPEnabled when the user
+// already has two-factor authentication.
+func (m TOTPModel) Enroll(ctx context.Context, userID int64) (*TOTPCredential, error) {
+	secret := make([]byte, 20)
+
+	_, err := rand.Read(secret)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		INSERT INTO totp_credentials (user_id, secret)
+		VALUES ($1, $2)
+		ON CONFLICT (user_id) DO UPDATE
+		SET secret = EXCLUDED.secret, created_at = NOW(), last_step = 0
+		WHERE NOT totp_credentials.enabled
+		RETURNING created_at`
+
+	credential := &TOTPCredential{
+		UserID: userID,
+		Secret: secret,
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	err = m.DB.QueryRowContext(ctx, query, userID, secret).Scan(&credential.CreatedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrTOTPEnabled
+		default:
+			return nil, err
+		}
+	}
+
+	return credential, nil
+}
+
+// Enable confirms enrollment with the first code from the user's app. In the same
+// transaction it replaces the recovery codes; the plaintext codes are returned once
+// and only their hashes are stored. ok is false when the code doesn't match.
+func (m TOTPModel) Enable(ctx context.Context, credential *TOTPCredential, code string) (codes []string, ok bool, err error) {
+	step, ok := credential.match(code, time.Now())
+	if !ok {
+		return nil, false, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	tx, err := beginTx(ctx, m.DB)
+	if err != nil {
+		return nil, false, err
+	}
+	defer tx.Rollback()
+
+	query := `
+		UPDATE totp_credentials
+		SET enabled = true, last_step = $1
+		WHERE user_id = $2 AND secret = $3 AND NOT enabled`
+
+	result, err := tx.ExecContext(ctx, query, step, credential.UserID, credential.Secret)
+	if err != nil {
+		return nil, false, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return nil, false, err
+	}
+	if rows == 0 {
+		return nil, false, ErrEditConflict
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM totp_recovery_codes WHERE user_id = $1`, credential.UserID)
+	if err != nil {
+		return nil, false, err
+	}
+
+	for i := 0; i < recoveryCodeCount; i++ {
+		recovery, err := generateRecoveryCode()
+		if err != nil {
+			return nil, false, err
+		}
+
+		_, err = tx.ExecContext(ctx, `INSERT INTO totp_recovery_codes (hash, user_id) VALUES ($1, $2)`, hashRecoveryCode(recovery), credential.UserID)
+		if err != nil {
+			return nil, false, err
+		}
+
+		codes = append(codes, recovery)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, false, err
+	}
+
+	credential.Enabled = true
+	credential.lastStep = step
+
+	return codes, true, nil
+}
+
+// Verify checks a code during login. An accepted code's time step is recorded so the
+// same code can't be replayed, even by two requests racing each other.
+func (m TOTPModel) Verify(ctx context.Context, credential *TOTPCredential, code string) (bool, error) {
+	step, ok := credential.match(code, time.Now())
+	if !ok {
+		return false, nil
+	}
+
+	query := `
+		UPDATE totp_credentials
+		SET last_step = $1
+		WHERE user_id = $2 AND enabled AND last_step < $1`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, step, credential.UserID)
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	if rows == 0 {
+		return false, nil
+	}
+
+	credential.lastStep = step
+
+	return true, nil
+}
+
+// UseRecoveryCode consumes one of the user's recovery codes, reporting whether it
+// was valid.
+func (m TOTPModel) UseRecoveryCode(ctx context.Context, userID int64, code string) (bool, error) {
+	query := `
+		DELETE FROM totp_recovery_codes
+		WHERE hash = $1 AND user_id = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, hashRecoveryCode(code), userID)
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rows == 1, nil
+}
+
+// Disable removes the secret and the recovery codes.
+func (m TOTPModel) Disable(ctx context.Context, userID int64) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	tx, err := beginTx(ctx, m.DB)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM totp_recovery_codes WHERE user_id = $1`, userID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM totp_credentials WHERE user_id = $1`, userID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
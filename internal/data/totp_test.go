@@ -0,0 +1,143 @@
+package data
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shynggys9219/greenlight/internal/validator"
+	"github.com/stretchr/testify/require"
+)
+
+// rfcSecret is the SHA-1 seed of the RFC 6238 test vectors.
+var rfcSecret = []byte("12345678901234567890")
+
+func TestTOTPCodeRFC6238(t *testing.T) {
+	// RFC 6238 Appendix B, SHA-1. The RFC prints eight digits; a six digit code is
+	// the same value modulo 10^6, i.e. its last six digits.
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{59, "94287082"},
+		{1111111109, "07081804"},
+		{1111111111, "14050471"},
+		{1234567890, "89005924"},
+		{2000000000, "69279037"},
+		{20000000000, "65353130"},
+	}
+
+	for _, tt := range tests {
+		require.Equal(t, tt.code[2:], totpCode(rfcSecret, tt.unix/totpPeriod), tt.unix)
+	}
+}
+
+func TestTOTPMatchSkew(t *testing.T) {
+	now := time.Unix(1111111109, 0)
+	current := now.Unix() / totpPeriod
+
+	for offset := int64(-totpSkew - 1); offset <= totpSkew+1; offset++ {
+		c := &TOTPCredential{Secret: rfcSecret}
+
+		step, ok := c.match(totpCode(rfcSecret, current+offset), now)
+
+		if offset < -totpSkew || offset > totpSkew {
+			require.False(t, ok, "offset %d", offset)
+			continue
+		}
+
+		require.True(t, ok, "offset %d", offset)
+		require.Equal(t, current+offset, step, "offset %d", offset)
+	}
+
+	c := &TOTPCredential{Secret: rfcSecret}
+	_, ok := c.match("000000", now)
+	require.False(t, ok)
+}
+
+func TestTOTPMatchReplay(t *testing.T) {
+	now := time.Unix(1111111109, 0)
+	current := now.Unix() / totpPeriod
+
+	tests := []struct {
+		name     string
+		lastStep int64
+		step     int64
+		ok       bool
+	}{
+		{"never used", 0, current, true},
+		{"previous step used", current - 1, current, true},
+		{"same step again", current, current, false},
+		{"older step after a newer one", current, current - 1, false},
+		{"next step after the current one", current, current + 1, true},
+		{"every step in the window used", current + 1, current + 1, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &TOTPCredential{Secret: rfcSecret, lastStep: tt.lastStep}
+
+			step, ok := c.match(totpCode(rfcSecret, tt.step), now)
+			require.Equal(t, tt.ok, ok)
+			if ok {
+				require.Equal(t, tt.step, step)
+			}
+		})
+	}
+}
+
+func TestValidateTOTPCode(t *testing.T) {
+	tests := []struct {
+		code  string
+		valid bool
+	}{
+		{"287082", true},
+		{"000000", true},
+		{"", false},
+		{"28708", false},
+		{"2870820", false},
+		{"28708a", false},
+		{" 28708", false},
+	}
+
+	for _, tt := range tests {
+		v := validator.New()
+		ValidateTOTPCode(v, tt.code)
+		require.Equal(t, tt.valid, v.Valid(), "%q", tt.code)
+	}
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	code, err := generateRecoveryCode()
+	require.NoError(t, err)
+	require.Regexp(t, `^[a-z2-7]{8}-[a-z2-7]{8}$`, code)
+
+	other, err := generateRecoveryCode()
+	require.NoError(t, err)
+	require.NotEqual(t, code, other)
+
+	// However the user copied it, the code hashes the same.
+	for _, typed := range []string{code, strings.ToUpper(code), code[:8] + code[9:], " " + code[:8] + " " + code[9:] + " "} {
+		require.Equal(t, hashRecoveryCode(code), hashRecoveryCode(typed), typed)
+	}
+	require.NotEqual(t, hashRecoveryCode(code), hashRecoveryCode(other))
+}
+
+func TestTOTPURI(t *testing.T) {
+	c := &TOTPCredential{Secret: rfcSecret}
+	require.Equal(t, "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", c.SecretString())
+
+	u, err := url.Parse(c.URI("Greenlight", "alice@example.com"))
+	require.NoError(t, err)
+	require.Equal(t, "otpauth", u.Scheme)
+	require.Equal(t, "totp", u.Host)
+	require.Equal(t, "/Greenlight:alice@example.com", u.Path)
+	require.Equal(t, url.Values{
+		"secret":    {"GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"},
+		"issuer":    {"Greenlight"},
+		"algorithm": {"SHA1"},
+		"digits":    {"6"},
+		"period":    {"30"},
+	}, u.Query())
+}
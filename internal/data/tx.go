@@ -0,0 +1,157 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+)
+
+// querier is what the models run their statements on: the connection pool, or the
+// transaction of Models.WithTx. Both *sql.DB and *sql.Tx satisfy it.
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// transaction is the transaction of a model method that writes several rows. Inside
+// Models.WithTx the method joins the outer transaction instead of starting its own,
+// and leaves Commit and Rollback to WithTx.
+type transaction struct {
+	*sql.Tx
+	joined bool
+}
+
+// beginTx starts a transaction on db, or joins it if db is one already.
+func beginTx(ctx context.Context, db querier) (*transaction, error) {
+	if tx, ok := db.(*sql.Tx); ok {
+		return &transaction{Tx: tx, joined: true}, nil
+	}
+
+	tx, err := db.(interface {
+		BeginTx(context.Context, *sql.TxOptions) (*sql.Tx, error)
+	}).BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &transaction{Tx: tx}, nil
+}
+
+func (t *transaction) Commit() error {
+	if t.joined {
+		return nil
+	}
+	return t.Tx.Commit()
+}
+
+// Rollback of a joined transaction does nothing: the error that caused it reaches
+// WithTx, which rolls back everything.
+func (t *transaction) Rollback() error {
+	if t.joined {
+		return nil
+	}
+	return t.Tx.Rollback()
+}
+
+// WithTx runs fn with models that share one transaction. It commits when fn returns
+// nil and rolls back otherwise, returning fn's error unchanged. Model methods with a
+// transaction of their own join this one, so fn can combine them freely. ctx bounds
+// the whole transaction; the models can't be used once fn has returned. Models
+// without a database, like those of the memstore package, have no transactions:
+// fn runs on them as they are.
+func (m Models) WithTx(ctx context.Context, fn func(Models) error) error {
+	if m.db == nil {
+		return fn(m)
+	}
+
+	tx, err := beginTx(ctx, m.db)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = fn(m.withDB(tx.Tx))
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// withDB returns a copy of the models that run their statements on db.
+func (m Models) withDB(db querier) Models {
+	m.db = db
+	if movies, ok := m.Movies.(MovieModel); ok {
+		movies.DB = db
+		m.Movies = movies
+	}
+	if directors, ok := m.Directors.(DirectorModel); ok {
+		directors.DB = db
+		m.Directors = directors
+	}
+	if users, ok := m.User.(UserModel); ok {
+		users.DB = db
+		m.User = users
+	}
+	if tokens, ok := m.Token.(TokenModel); ok {
+		tokens.DB = db
+		m.Token = tokens
+	}
+	if roles, ok := m.Role.(RoleModel); ok {
+		roles.DB = db
+		m.Role = roles
+	}
+	if people, ok := m.People.(PersonModel); ok {
+		people.DB = db
+		m.People = people
+	}
+	if credits, ok := m.Credits.(CreditModel); ok {
+		credits.DB = db
+		m.Credits = credits
+	}
+	if titles, ok := m.Titles.(MovieTitleModel); ok {
+		titles.DB = db
+		m.Titles = titles
+	}
+	if images, ok := m.Images.(ImageModel); ok {
+		images.DB = db
+		m.Images = images
+	}
+	if outbox, ok := m.Outbox.(OutboxModel); ok {
+		outbox.DB = db
+		m.Outbox = outbox
+	}
+	if logins, ok := m.Logins.(LoginAttemptModel); ok {
+		logins.DB = db
+		m.Logins = logins
+	}
+	if totp, ok := m.TOTP.(TOTPModel); ok {
+		totp.DB = db
+		m.TOTP = totp
+	}
+	if keys, ok := m.APIKeys.(APIKeyModel); ok {
+		keys.DB = db
+		m.APIKeys = keys
+	}
+	if sessions, ok := m.Sessions.(SessionModel); ok {
+		sessions.DB = db
+		m.Sessions = sessions
+	}
+	if runs, ok := m.JobRuns.(JobRunModel); ok {
+		runs.DB = db
+		m.JobRuns = runs
+	}
+	if audit, ok := m.Audit.(AuditModel); ok {
+		audit.DB = db
+		m.Audit = audit
+	}
+	if ratings, ok := m.Ratings.(RatingModel); ok {
+		ratings.DB = db
+		m.Ratings = ratings
+	}
+	if similar, ok := m.Similar.(SimilarityModel); ok {
+		similar.DB = db
+		m.Similar = similar
+	}
+	return m
+}
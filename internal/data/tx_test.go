@@ -0,0 +1,57 @@
+package data_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/shynggys9219/greenlight/internal/data"
+	"github.com/shynggys9219/greenlight/internal/testdb"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithTxRollsBack(t *testing.T) {
+	db := testdb.Open(t)
+
+	models := data.NewModels(db)
+
+	user := &data.User{
+		Name:      "Heidi",
+		Email:     fmt.Sprintf("tx-%d@example.com", time.Now().UnixNano()),
+		Activated: true,
+		Locale:    "en",
+	}
+	require.NoError(t, user.Password.Set(testdb.FixturePassword))
+
+	errAbort := errors.New("abort")
+
+	// InsertWithActivation has a transaction of its own, which joins the outer one
+	// and so is rolled back with it.
+	err := models.WithTx(context.Background(), func(m data.Models) error {
+		_, err := m.User.InsertWithActivation(context.Background(), user, time.Hour, "user_welcome")
+		if err != nil {
+			return err
+		}
+
+		_, err = m.Token.New(context.Background(), user.ID, time.Hour, data.ScopeAuthentication)
+		if err != nil {
+			return err
+		}
+
+		return errAbort
+	})
+	require.ErrorIs(t, err, errAbort)
+
+	_, err = models.User.GetByEmain(context.Background(), user.Email)
+	require.ErrorIs(t, err, data.ErrRecordNotFound)
+
+	err = models.WithTx(context.Background(), func(m data.Models) error {
+		return m.User.Insert(context.Background(), user)
+	})
+	require.NoError(t, err)
+
+	_, err = models.User.GetByEmain(context.Background(), user.Email)
+	require.NoError(t, err)
+}
@@ -2,9 +2,11 @@ package data
 
 import (
 	"context"
+	"crypto/rand"
 	"crypto/sha256"
 	"database/sql"
 	"errors"
+	"fmt"
 	"time"
 
 	"github.com/shynggys9219/greenlight/internal/validator"
@@ -16,9 +18,13 @@ var (
 )
 
 type UserModel struct {
-	DB *sql.DB
+	DB    querier
+	Actor Actor // who changes are attributed to in the audit log, see Models.As
 }
 
+// AnonymousUser represents a request without (valid) credentials.
+var AnonymousUser = &User{}
+
 type User struct {
 	ID        int64     `json:"id"`
 	CreatedAt time.Time `json:"created_at"`
@@ -26,7 +32,25 @@ type User struct {
 	Email     string    `json:"email"`
 	Password  password  `json:"-"`
 	Activated bool      `json:"activated"`
-	Version   int       `json:"-"`
+	Locked    bool      `json:"locked"`
+	Locale    string    `json:"locale"`
+	Version   int       `json:"version"`
+
+	// LockedUntil is set while the account is locked out after too many failed logins.
+	LockedUntil *time.Time `json:"locked_until,omitempty"`
+
+	// PendingEmail is a new address waiting for confirmation.
+	PendingEmail string `json:"pending_email,omitempty"`
+}
+
+// IsAnonymous reports whether the user is the AnonymousUser instance.
+func (u *User) IsAnonymous() bool {
+	return u == AnonymousUser
+}
+
+// IsLockedOut reports whether the account is in a temporary lockout.
+func (u *User) IsLockedOut() bool {
+	return u.LockedUntil != nil && time.Now().Before(*u.LockedUntil)
 }
 
 type password struct {
@@ -41,21 +65,30 @@ type Role struct {
 }
 
 type RoleModel struct {
-	DB *sql.DB
+	DB    querier
+	Actor Actor // who changes are attributed to in the audit log, see Models.As
 }
 
-func (m UserModel) Insert(user *User) error {
-	query := `
-		INSERT INTO users (name, email, password_hash, activated)
-		VALUES ($1, $2, $3, $4)
-		RETURNING id, created_at, version`
+const insertUserQuery = `
+	INSERT INTO users (name, email, password_hash, activated, locale)
+	VALUES ($1, $2, $3, $4, $5)
+	RETURNING id, created_at, version`
+
+func (m UserModel) Insert(ctx context.Context, user *User) error {
+	query := insertUserQuery
 
-	args := []any{user.Name, user.Email, user.Password.hash, user.Activated}
+	args := []any{user.Name, user.Email, user.Password.hash, user.Activated, user.Locale}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
 	defer cancel()
 
-	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&user.ID, &user.CreatedAt, &user.Version)
+	tx, err := beginTx(ctx, m.DB)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&user.ID, &user.CreatedAt, &user.Version)
 	if err != nil {
 		switch {
 		case err.Error() == `pq: duplicate key value violates unique constraint "users_email_key"`:
@@ -65,18 +98,132 @@ func (m UserModel) Insert(user *User) error {
 		}
 	}
 
-	return nil
+	err = m.recordEvent(ctx, tx, "user.create", nil, user)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
-func (m UserModel) GetByEmain(email string) (*User, error) {
+// recordEvent records a change of a user in the audit log; before or after is nil
+// when the user was created or deleted.
+func (m UserModel) recordEvent(ctx context.Context, tx querier, action string, before, after *User) error {
+	changes, err := userDiff(before, after)
+	if err != nil {
+		return err
+	}
+
+	user := before
+	if user == nil {
+		user = after
+	}
+
+	return recordEvent(ctx, tx, m.Actor, action, "user", user.ID, changes)
+}
+
+// getForUpdate reads and locks a user in tx.
+func (m UserModel) getForUpdate(ctx context.Context, tx querier, id int64) (*User, error) {
 	query := `
-		SELECT id, created_at, name, email, password_hash, activated, version
+		SELECT id, created_at, name, email, password_hash, activated, locked, locale, pending_email, locked_until, version
+		FROM users
+		WHERE id = $1
+		FOR UPDATE`
+
+	var user User
+
+	err := tx.QueryRowContext(ctx, query, id).Scan(
+		&user.ID,
+		&user.CreatedAt,
+		&user.Name,
+		&user.Email,
+		&user.Password.hash,
+		&user.Activated,
+		&user.Locked,
+		&user.Locale,
+		&user.PendingEmail,
+		&user.LockedUntil,
+		&user.Version,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &user, nil
+}
+
+// InsertWithActivation inserts a new user together with an activation token and the
+// welcome email (queued in email_outbox) in a single transaction, so a failure can
+// never leave a user behind without a way to activate the account.
+func (m UserModel) InsertWithActivation(ctx context.Context, user *User, ttl time.Duration, templateName string) (*Token, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	tx, err := beginTx(ctx, m.DB)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	args := []any{user.Name, user.Email, user.Password.hash, user.Activated, user.Locale}
+
+	err = tx.QueryRowContext(ctx, insertUserQuery, args...).Scan(&user.ID, &user.CreatedAt, &user.Version)
+	if err != nil {
+		switch {
+		case err.Error() == `pq: duplicate key value violates unique constraint "users_email_key"`:
+			return nil, ErrDuplicateEmail
+		default:
+			return nil, err
+		}
+	}
+
+	token, err := GenerateToken(user.ID, ttl, ScopeActivation)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.ExecContext(ctx, insertTokenQuery, token.Hash, token.UserID, token.Expiry, token.Scope)
+	if err != nil {
+		return nil, err
+	}
+
+	email := &OutboxEmail{
+		Recipient: user.Email,
+		Locale:    user.Locale,
+		Template:  templateName,
+		Data: map[string]any{
+			"activationToken": token.Plaintext,
+			"userID":          user.ID,
+		},
+	}
+
+	err = enqueueEmail(ctx, tx, email)
+	if err != nil {
+		return nil, err
+	}
+
+	err = m.recordEvent(ctx, tx, "user.create", nil, user)
+	if err != nil {
+		return nil, err
+	}
+
+	return token, tx.Commit()
+}
+
+func (m UserModel) GetByEmain(ctx context.Context, email string) (*User, error) {
+	query := `
+		SELECT id, created_at, name, email, password_hash, activated, locked, locale, pending_email, locked_until, version
 		FROM users
 		WHERE email = $1`
 
 	var user User
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
 	defer cancel()
 
 	err := m.DB.QueryRowContext(ctx, query, email).Scan(
@@ -86,6 +233,10 @@ func (m UserModel) GetByEmain(email string) (*User, error) {
 		&user.Email,
 		&user.Password.hash,
 		&user.Activated,
+		&user.Locked,
+		&user.Locale,
+		&user.PendingEmail,
+		&user.LockedUntil,
 		&user.Version,
 	)
 
@@ -101,11 +252,12 @@ func (m UserModel) GetByEmain(email string) (*User, error) {
 	return &user, nil
 }
 
-func (m UserModel) Update(user *User) error {
+func (m UserModel) Update(ctx context.Context, user *User) error {
 	query := `
 		UPDATE users
-		SET name = $1, email = $2, password_hash = $3, activated = $4, version = version + 1
-		WHERE id = $5 AND version = $6
+		SET name = $1, email = $2, password_hash = $3, activated = $4, locked = $5, locale = $6, pending_email = $7,
+			locked_until = $8, version = version + 1
+		WHERE id = $9 AND version = $10
 		RETURNING version`
 
 	args := []any{
@@ -113,14 +265,34 @@ func (m UserModel) Update(user *User) error {
 		user.Email,
 		user.Password.hash,
 		user.Activated,
+		user.Locked,
+		user.Locale,
+		user.PendingEmail,
+		user.LockedUntil,
 		user.ID,
 		user.Version,
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
 	defer cancel()
 
-	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&user.Version)
+	tx, err := beginTx(ctx, m.DB)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	before, err := m.getForUpdate(ctx, tx, user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrRecordNotFound):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&user.Version)
 	if err != nil {
 		switch {
 		case err.Error() == `pq: duplicate key value violates unique constraint "users_email_key"`:
@@ -132,7 +304,478 @@ func (m UserModel) Update(user *User) error {
 		}
 	}
 
-	return nil
+	err = m.recordEvent(ctx, tx, "user.update", before, user)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// RequestEmailChange records newEmail as the user's pending address and, in the same
+// transaction, creates an email_change token and queues the confirmation email to the
+// new address. Earlier, unconfirmed requests stop working.
+func (m UserModel) RequestEmailChange(ctx context.Context, user *User, newEmail string, ttl time.Duration, templateName string) (*Token, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	tx, err := beginTx(ctx, m.DB)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	before := *user
+
+	query := `
+		UPDATE users
+		SET pending_email = $1, version = version + 1
+		WHERE id = $2 AND version = $3
+		RETURNING version`
+
+	err = tx.QueryRowContext(ctx, query, newEmail, user.ID, user.Version).Scan(&user.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrEditConflict
+		default:
+			return nil, err
+		}
+	}
+	user.PendingEmail = newEmail
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM tokens WHERE scope = $1 AND user_id = $2`, ScopeEmailChange, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := GenerateToken(user.ID, ttl, ScopeEmailChange)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.ExecContext(ctx, insertTokenQuery, token.Hash, token.UserID, token.Expiry, token.Scope)
+	if err != nil {
+		return nil, err
+	}
+
+	email := &OutboxEmail{
+		Recipient: newEmail,
+		Locale:    user.Locale,
+		Template:  templateName,
+		Data: map[string]any{
+			"emailChangeToken": token.Plaintext,
+			"name":             user.Name,
+		},
+	}
+
+	err = enqueueEmail(ctx, tx, email)
+	if err != nil {
+		return nil, err
+	}
+
+	err = m.recordEvent(ctx, tx, "user.email_change_request", &before, user)
+	if err != nil {
+		return nil, err
+	}
+
+	return token, tx.Commit()
+}
+
+// LockOut locks the account until the given time after too many failed logins. In the
+// same transaction it creates an unlock token and queues the notification email, so
+// the owner can unlock the account early.
+func (m UserModel) LockOut(ctx context.Context, user *User, until time.Time, ttl time.Duration, templateName string) (*Token, error) {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	tx, err := beginTx(ctx, m.DB)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	before := *user
+
+	// No version check: a lockout must not fail because the user was edited meanwhile.
+	query := `
+		UPDATE users
+		SET locked_until = $1, version = version + 1
+		WHERE id = $2
+		RETURNING locked_until, version`
+
+	err = tx.QueryRowContext(ctx, query, until, user.ID).Scan(&user.LockedUntil, &user.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM tokens WHERE scope = $1 AND user_id = $2`, ScopeUnlock, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := GenerateToken(user.ID, ttl, ScopeUnlock)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.ExecContext(ctx, insertTokenQuery, token.Hash, token.UserID, token.Expiry, token.Scope)
+	if err != nil {
+		return nil, err
+	}
+
+	email := &OutboxEmail{
+		Recipient: user.Email,
+		Locale:    user.Locale,
+		Template:  templateName,
+		Data: map[string]any{
+			"name":        user.Name,
+			"unlockToken": token.Plaintext,
+			"lockedUntil": until.UTC().Format(time.RFC1123),
+		},
+	}
+
+	err = enqueueEmail(ctx, tx, email)
+	if err != nil {
+		return nil, err
+	}
+
+	err = m.recordEvent(ctx, tx, "user.lock_out", &before, user)
+	if err != nil {
+		return nil, err
+	}
+
+	return token, tx.Commit()
+}
+
+// Anonymize is how users delete their own account: the row stays so that nothing
+// referencing the id breaks, but the personal data is overwritten, the account is
+// locked with an unusable password, and all tokens, sessions, API keys, roles,
+// ratings and TOTP secrets are removed.
+func (m UserModel) Anonymize(ctx context.Context, user *User) error {
+	random := make([]byte, 32)
+	_, err := rand.Read(random)
+	if err != nil {
+		return err
+	}
+
+	var unusable password
+	err = unusable.Set(fmt.Sprintf("%x", random))
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	tx, err := beginTx(ctx, m.DB)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+		UPDATE users
+		SET name = 'Deleted user', email = $1, password_hash = $2, activated = false, locked = true,
+			pending_email = '', locked_until = NULL, version = version + 1
+		WHERE id = $3 AND version = $4
+		RETURNING name, email, activated, locked, pending_email, locked_until, version`
+
+	args := []any{fmt.Sprintf("deleted-%d@users.invalid", user.ID), unusable.hash, user.ID, user.Version}
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(
+		&user.Name,
+		&user.Email,
+		&user.Activated,
+		&user.Locked,
+		&user.PendingEmail,
+		&user.LockedUntil,
+		&user.Version,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+	user.Password = unusable
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM tokens WHERE user_id = $1`, user.ID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM sessions WHERE user_id = $1`, user.ID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM roles WHERE user_id = $1`, user.ID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM api_keys WHERE user_id = $1`, user.ID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM movie_ratings WHERE user_id = $1`, user.ID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM totp_recovery_codes WHERE user_id = $1`, user.ID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM totp_credentials WHERE user_id = $1`, user.ID)
+	if err != nil {
+		return err
+	}
+
+	// The audit log must not keep what was just erased, so the user's earlier events
+	// lose their details and this one has none.
+	_, err = tx.ExecContext(ctx, `UPDATE audit_events SET changes = '{}' WHERE resource_type = 'user' AND resource_id = $1`, user.ID)
+	if err != nil {
+		return err
+	}
+
+	err = recordEvent(ctx, tx, m.Actor, "user.anonymize", "user", user.ID, nil)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// DeleteUnactivated deletes accounts that were registered before the given time and
+// never activated, and returns how many. Anonymized accounts are also not activated,
+// but they are locked and have to stay.
+func (m UserModel) DeleteUnactivated(ctx context.Context, registeredBefore time.Time) (int64, error) {
+	query := `
+		DELETE FROM users
+		WHERE NOT activated AND NOT locked AND created_at < $1
+		RETURNING id, created_at, name, email, activated, locked, locale, pending_email, locked_until, version`
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	tx, err := beginTx(ctx, m.DB)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, query, registeredBefore)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	var deleted []*User
+
+	for rows.Next() {
+		var user User
+
+		err := rows.Scan(
+			&user.ID,
+			&user.CreatedAt,
+			&user.Name,
+			&user.Email,
+			&user.Activated,
+			&user.Locked,
+			&user.Locale,
+			&user.PendingEmail,
+			&user.LockedUntil,
+			&user.Version,
+		)
+		if err != nil {
+			return 0, err
+		}
+
+		deleted = append(deleted, &user)
+	}
+
+	if err = rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for _, user := range deleted {
+		err = m.recordEvent(ctx, tx, "user.delete", user, nil)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return int64(len(deleted)), tx.Commit()
+}
+
+// UserFilter narrows down GetAll. Zero values don't filter.
+type UserFilter struct {
+	Activated     *bool
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+	Email         string // case-insensitive substring of the email address
+}
+
+func ValidateUserFilter(v *validator.Validator, filter UserFilter) {
+	v.Check(filter.CreatedAfter.IsZero() || filter.CreatedBefore.IsZero() || filter.CreatedAfter.Before(filter.CreatedBefore), "created_after", "must be before created_before")
+	v.Check(len(filter.Email) <= 500, "email", "must not be more than 500 bytes long")
+}
+
+func (m UserModel) Get(ctx context.Context, id int64) (*User, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+		SELECT id, created_at, name, email, password_hash, activated, locked, locale, pending_email, locked_until, version
+		FROM users
+		WHERE id = $1`
+
+	var user User
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(
+		&user.ID,
+		&user.CreatedAt,
+		&user.Name,
+		&user.Email,
+		&user.Password.hash,
+		&user.Activated,
+		&user.Locked,
+		&user.Locale,
+		&user.PendingEmail,
+		&user.LockedUntil,
+		&user.Version,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &user, nil
+}
+
+// GetAll lists users for the admin API.
+func (m UserModel) GetAll(ctx context.Context, filter UserFilter, filters Filters) ([]*User, error) {
+	orderBy, err := filters.orderBy()
+	if err != nil {
+		return nil, err
+	}
+
+	query := fmt.Sprintf(`
+		SELECT id, created_at, name, email, password_hash, activated, locked, locale, pending_email, locked_until, version
+		FROM users
+		WHERE (activated = $1 OR $1 IS NULL)
+		AND (created_at >= $2 OR $2 IS NULL)
+		AND (created_at < $3 OR $3 IS NULL)
+		AND (strpos(lower(email::text), lower($4)) > 0 OR $4 = '')
+		ORDER BY %s, id ASC
+		LIMIT $5 OFFSET $6`, orderBy)
+
+	args := []any{
+		sql.NullBool{Bool: filter.Activated != nil && *filter.Activated, Valid: filter.Activated != nil},
+		sql.NullTime{Time: filter.CreatedAfter, Valid: !filter.CreatedAfter.IsZero()},
+		sql.NullTime{Time: filter.CreatedBefore, Valid: !filter.CreatedBefore.IsZero()},
+		filter.Email,
+		filters.limit(),
+		filters.offset(),
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	users := []*User{}
+
+	for rows.Next() {
+		var user User
+
+		err := rows.Scan(
+			&user.ID,
+			&user.CreatedAt,
+			&user.Name,
+			&user.Email,
+			&user.Password.hash,
+			&user.Activated,
+			&user.Locked,
+			&user.Locale,
+			&user.PendingEmail,
+			&user.LockedUntil,
+			&user.Version,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		users = append(users, &user)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+// Delete removes the user if it hasn't changed since it was read. Tokens and roles
+// go with it through ON DELETE CASCADE.
+func (m UserModel) Delete(ctx context.Context, user *User) error {
+	query := `
+		DELETE FROM users
+		WHERE id = $1 AND version = $2`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	tx, err := beginTx(ctx, m.DB)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, query, user.ID, user.Version)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrEditConflict
+	}
+
+	err = m.recordEvent(ctx, tx, "user.delete", user, nil)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
 func (p *password) Set(plaintextPassword string) error {
@@ -161,6 +804,16 @@ func (p *password) Matches(plaintextPassword string) (bool, error) {
 	return true, nil
 }
 
+// dummyPasswordHash has the cost of the hashes Set makes, and matches no password
+// anyone would try.
+var dummyPasswordHash = []byte("$2a$12$IFFw/.QiXNR5FLFE6rf62.4RmrhHkYop5XsG/GDD8/6hI9rqT7Sg6")
+
+// SimulatePasswordCheck takes as long as Matches, for logins with an email that has no
+// account, so the response time doesn't tell which emails have one.
+func SimulatePasswordCheck(plaintextPassword string) {
+	_ = bcrypt.CompareHashAndPassword(dummyPasswordHash, []byte(plaintextPassword))
+}
+
 func ValidateEmail(v *validator.Validator, email string) {
 	v.Check(email != "", "email", "must be provided")
 	v.Check(validator.Matches(email, validator.EmailRX), "email", "must be a valid email addres")
@@ -175,6 +828,7 @@ func ValidatePasswordPlaintext(v *validator.Validator, password string) {
 func ValidateUser(v *validator.Validator, user *User) {
 	v.Check(user.Name != "", "name", "must be provided")
 	v.Check(len(user.Name) <= 500, "name", "must not be more than 500 bytes long")
+	v.Check(validator.Matches(user.Locale, validator.LanguageRX), "locale", "must be a valid language code")
 
 	ValidateEmail(v, user.Email)
 
@@ -187,10 +841,10 @@ func ValidateUser(v *validator.Validator, user *User) {
 	}
 }
 
-func (m UserModel) GetForToken(tokenScope, tokenPlaintext string) (*User, error) {
+func (m UserModel) GetForToken(ctx context.Context, tokenScope, tokenPlaintext string) (*User, error) {
 	tokenHash := sha256.Sum256([]byte(tokenPlaintext))
 	query := `
-		SELECT users.id, users.created_at, users.name, users.email, users.password_hash, users.activated, users.version
+		SELECT users.id, users.created_at, users.name, users.email, users.password_hash, users.activated, users.locked, users.locale, users.pending_email, users.locked_until, users.version
 		FROM users
 		INNER JOIN tokens
 		ON users.id = tokens.user_id
@@ -200,7 +854,7 @@ func (m UserModel) GetForToken(tokenScope, tokenPlaintext string) (*User, error)
 
 	args := []any{tokenHash[:], tokenScope, time.Now()}
 	var user User
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
 	defer cancel()
 
 	err := m.DB.QueryRowContext(ctx, query, args...).Scan(
@@ -210,6 +864,10 @@ func (m UserModel) GetForToken(tokenScope, tokenPlaintext string) (*User, error)
 		&user.Email,
 		&user.Password.hash,
 		&user.Activated,
+		&user.Locked,
+		&user.Locale,
+		&user.PendingEmail,
+		&user.LockedUntil,
 		&user.Version,
 	)
 	if err != nil {
@@ -238,7 +896,7 @@ func (m UserModel) GetForToken(tokenScope, tokenPlaintext string) (*User, error)
 
 // 	args := []any{user.Name, user.Email, user.Password.hash, user.Activated}
 
-// 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+// 	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
 // 	defer cancel()
 
 // 	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&user.ID, &user.CreatedAt, &user.Version)
@@ -254,7 +912,7 @@ func (m UserModel) GetForToken(tokenScope, tokenPlaintext string) (*User, error)
 // 	return nil
 // }
 
-func (m RoleModel) InsertUserRole(role *Role) error {
+func (m RoleModel) InsertUserRole(ctx context.Context, role *Role) error {
 	// tokenHash := sha256.Sum256([]byte(tokenPlaintext))
 	query := `
 		INSERT INTO roles (role_name, user_id)
@@ -262,10 +920,16 @@ func (m RoleModel) InsertUserRole(role *Role) error {
 		RETURNING id`
 
 	// args := []any{tokenHash[:], tokenScope, time.Now()}
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeout)
 	defer cancel()
 
-	err := m.DB.QueryRowContext(ctx, query, &role.RoleName,
+	tx, err := beginTx(ctx, m.DB)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, query, &role.RoleName,
 		&role.UserID).Scan(
 		&role.ID)
 	if err != nil {
@@ -277,5 +941,10 @@ func (m RoleModel) InsertUserRole(role *Role) error {
 		}
 	}
 
-	return nil
+	err = m.recordGrant(ctx, tx, role)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
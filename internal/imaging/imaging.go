@@ -0,0 +1,65 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+// ThumbnailWidths are the widths (in pixels) generated for every uploaded image,
+// largest first so each thumbnail can be scaled down from the previous one.
+var ThumbnailWidths = []int{500, 185, 92}
+
+// Resize scales src down to the given width keeping the aspect ratio. Every
+// destination pixel is the average of the source pixels it covers, which gives far
+// better thumbnails than nearest-neighbour sampling. Images that are already narrow
+// enough are only copied.
+func Resize(src image.Image, width int) *image.RGBA {
+	bounds := src.Bounds()
+	if width <= 0 || width > bounds.Dx() {
+		width = bounds.Dx()
+	}
+
+	height := bounds.Dy() * width / bounds.Dx()
+	if height < 1 {
+		height = 1
+	}
+
+	rgba := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(rgba, rgba.Bounds(), src, bounds.Min, draw.Src)
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+
+	for y := 0; y < height; y++ {
+		sy0 := y * bounds.Dy() / height
+		sy1 := (y + 1) * bounds.Dy() / height
+		if sy1 == sy0 {
+			sy1++
+		}
+
+		for x := 0; x < width; x++ {
+			sx0 := x * bounds.Dx() / width
+			sx1 := (x + 1) * bounds.Dx() / width
+			if sx1 == sx0 {
+				sx1++
+			}
+
+			var r, g, b, a, n uint32
+			for sy := sy0; sy < sy1; sy++ {
+				offset := rgba.PixOffset(sx0, sy)
+				for sx := sx0; sx < sx1; sx++ {
+					r += uint32(rgba.Pix[offset])
+					g += uint32(rgba.Pix[offset+1])
+					b += uint32(rgba.Pix[offset+2])
+					a += uint32(rgba.Pix[offset+3])
+					offset += 4
+					n++
+				}
+			}
+
+			dst.SetRGBA(x, y, color.RGBA{uint8(r / n), uint8(g / n), uint8(b / n), uint8(a / n)})
+		}
+	}
+
+	return dst
+}
@@ -0,0 +1,74 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestResize(t *testing.T) {
+	tests := []struct {
+		name          string
+		width, height int
+		resize        int
+		wantW, wantH  int
+	}{
+		{"scale down", 1000, 500, 185, 185, 92},
+		{"portrait", 600, 900, 92, 92, 138},
+		{"exact width", 500, 750, 500, 500, 750},
+		{"never scales up", 80, 120, 185, 80, 120},
+		{"zero width copies", 300, 200, 0, 300, 200},
+		{"negative width copies", 300, 200, -1, 300, 200},
+		{"height at least one pixel", 1000, 2, 92, 92, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := image.NewRGBA(image.Rect(0, 0, tt.width, tt.height))
+
+			dst := Resize(src, tt.resize)
+			require.Equal(t, image.Rect(0, 0, tt.wantW, tt.wantH), dst.Bounds())
+		})
+	}
+}
+
+func TestResizeAverages(t *testing.T) {
+	// A 4x2 checkerboard of black and white pixels averages to grey.
+	src := image.NewRGBA(image.Rect(0, 0, 4, 2))
+	for y := 0; y < 2; y++ {
+		for x := 0; x < 4; x++ {
+			if (x+y)%2 == 0 {
+				src.SetRGBA(x, y, color.RGBA{255, 255, 255, 255})
+			} else {
+				src.SetRGBA(x, y, color.RGBA{0, 0, 0, 255})
+			}
+		}
+	}
+
+	dst := Resize(src, 2)
+	require.Equal(t, image.Rect(0, 0, 2, 1), dst.Bounds())
+	for x := 0; x < 2; x++ {
+		require.Equal(t, color.RGBA{127, 127, 127, 255}, dst.RGBAAt(x, 0))
+	}
+}
+
+func TestResizeSubImage(t *testing.T) {
+	// The bounds of src need not start at the origin.
+	src := image.NewRGBA(image.Rect(0, 0, 20, 20))
+	for y := 0; y < 20; y++ {
+		for x := 0; x < 20; x++ {
+			if x >= 10 {
+				src.SetRGBA(x, y, color.RGBA{255, 0, 0, 255})
+			}
+		}
+	}
+
+	sub := src.SubImage(image.Rect(10, 10, 20, 20))
+
+	dst := Resize(sub, 5)
+	require.Equal(t, image.Rect(0, 0, 5, 5), dst.Bounds())
+	require.Equal(t, color.RGBA{255, 0, 0, 255}, dst.RGBAAt(0, 0))
+	require.Equal(t, color.RGBA{255, 0, 0, 255}, dst.RGBAAt(4, 4))
+}
@@ -0,0 +1,250 @@
+// Package jwt signs and verifies the JSON Web Tokens used for stateless
+// authentication. It only implements what the API needs: EdDSA (Ed25519) signatures,
+// a fixed set of claims, and key rotation through the "kid" header.
+package jwt
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var (
+	ErrMalformed  = errors.New("jwt: malformed token")
+	ErrSignature  = errors.New("jwt: invalid signature")
+	ErrUnknownKey = errors.New("jwt: unknown signing key")
+	ErrExpired    = errors.New("jwt: token expired")
+	ErrClaims     = errors.New("jwt: wrong issuer, audience or validity period")
+)
+
+// leeway absorbs clock differences between the node that signed a token and the one
+// verifying it.
+const leeway = 30 * time.Second
+
+var encoding = base64.RawURLEncoding
+
+// Key is one Ed25519 key pair, identified by the "kid" header of the tokens it signs.
+type Key struct {
+	ID      string
+	Private ed25519.PrivateKey
+}
+
+func (k Key) public() ed25519.PublicKey {
+	return k.Private.Public().(ed25519.PublicKey)
+}
+
+// ParseKeys reads keys written as "kid:seed" pairs separated by commas, where seed is
+// the standard base64 encoding of a 32 byte Ed25519 seed, e.g. from
+// "openssl rand -base64 32".
+func ParseKeys(s string) ([]Key, error) {
+	var keys []Key
+
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		id, seed, ok := strings.Cut(part, ":")
+		if !ok || id == "" {
+			return nil, fmt.Errorf("jwt: key %q is not in kid:seed form", part)
+		}
+
+		raw, err := base64.StdEncoding.DecodeString(seed)
+		if err != nil || len(raw) != ed25519.SeedSize {
+			return nil, fmt.Errorf("jwt: seed of key %q must be %d bytes in base64", id, ed25519.SeedSize)
+		}
+
+		for _, key := range keys {
+			if key.ID == id {
+				return nil, fmt.Errorf("jwt: duplicate key id %q", id)
+			}
+		}
+
+		keys = append(keys, Key{ID: id, Private: ed25519.NewKeyFromSeed(raw)})
+	}
+
+	return keys, nil
+}
+
+// Claims are the claims of an access token.
+type Claims struct {
+	Issuer    string `json:"iss"`
+	Audience  string `json:"aud"`
+	Subject   string `json:"sub"` // the user id
+	SessionID int64  `json:"sid,omitempty"`
+	ID        string `json:"jti"`
+	IssuedAt  int64  `json:"iat"`
+	NotBefore int64  `json:"nbf"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+type header struct {
+	Algorithm string `json:"alg"`
+	Type      string `json:"typ"`
+	KeyID     string `json:"kid"`
+}
+
+// Signer issues and verifies tokens. The first key signs new tokens; all of them are
+// accepted when verifying. To rotate, put a new key first and remove the old one
+// once the tokens it signed have expired.
+type Signer struct {
+	Issuer   string
+	Audience string
+	Keys     []Key
+}
+
+func New(issuer, audience string, keys []Key) (*Signer, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("jwt: at least one key is required")
+	}
+
+	return &Signer{
+		Issuer:   issuer,
+		Audience: audience,
+		Keys:     keys,
+	}, nil
+}
+
+// Sign returns a token for subject that is valid for ttl. The issuer, audience,
+// times and a random token id are filled in.
+func (s *Signer) Sign(subject string, sessionID int64, ttl time.Duration) (token string, expiry time.Time, err error) {
+	id := make([]byte, 16)
+
+	_, err = rand.Read(id)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	now := time.Now()
+	expiry = now.Add(ttl).Truncate(time.Second)
+
+	claims := Claims{
+		Issuer:    s.Issuer,
+		Audience:  s.Audience,
+		Subject:   subject,
+		SessionID: sessionID,
+		ID:        encoding.EncodeToString(id),
+		IssuedAt:  now.Unix(),
+		NotBefore: now.Unix(),
+		ExpiresAt: expiry.Unix(),
+	}
+
+	key := s.Keys[0]
+
+	h, err := json.Marshal(header{Algorithm: "EdDSA", Type: "JWT", KeyID: key.ID})
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	c, err := json.Marshal(claims)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	signingInput := encoding.EncodeToString(h) + "." + encoding.EncodeToString(c)
+	signature := ed25519.Sign(key.Private, []byte(signingInput))
+
+	return signingInput + "." + encoding.EncodeToString(signature), expiry, nil
+}
+
+// Verify checks the signature, issuer, audience and validity period of a token and
+// returns its claims.
+func (s *Signer) Verify(token string) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrMalformed
+	}
+
+	var h header
+	if err := decodePart(parts[0], &h); err != nil {
+		return nil, err
+	}
+
+	// Only ever accept the one algorithm we sign with, whatever the header claims.
+	if h.Algorithm != "EdDSA" {
+		return nil, ErrSignature
+	}
+
+	var public ed25519.PublicKey
+	for _, key := range s.Keys {
+		if key.ID == h.KeyID {
+			public = key.public()
+		}
+	}
+	if public == nil {
+		return nil, ErrUnknownKey
+	}
+
+	signature, err := encoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrMalformed
+	}
+
+	if !ed25519.Verify(public, []byte(parts[0]+"."+parts[1]), signature) {
+		return nil, ErrSignature
+	}
+
+	var claims Claims
+	if err := decodePart(parts[1], &claims); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+
+	if now.After(time.Unix(claims.ExpiresAt, 0).Add(leeway)) {
+		return nil, ErrExpired
+	}
+
+	if claims.Issuer != s.Issuer || claims.Audience != s.Audience || now.Add(leeway).Before(time.Unix(claims.NotBefore, 0)) {
+		return nil, ErrClaims
+	}
+
+	return &claims, nil
+}
+
+func decodePart(part string, dst any) error {
+	raw, err := encoding.DecodeString(part)
+	if err != nil {
+		return ErrMalformed
+	}
+
+	if err := json.Unmarshal(raw, dst); err != nil {
+		return ErrMalformed
+	}
+
+	return nil
+}
+
+// JWK is the public half of a key as a JSON Web Key (RFC 8037).
+type JWK struct {
+	KeyType   string `json:"kty"`
+	Curve     string `json:"crv"`
+	X         string `json:"x"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+}
+
+// JWKS returns the public keys, for other services that verify our tokens.
+func (s *Signer) JWKS() []JWK {
+	jwks := make([]JWK, 0, len(s.Keys))
+
+	for _, key := range s.Keys {
+		jwks = append(jwks, JWK{
+			KeyType:   "OKP",
+			Curve:     "Ed25519",
+			X:         encoding.EncodeToString(key.public()),
+			KeyID:     key.ID,
+			Use:       "sig",
+			Algorithm: "EdDSA",
+		})
+	}
+
+	return jwks
+}
@@ -0,0 +1,284 @@
+package jwt
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func testKey(id string, seed byte) Key {
+	return Key{ID: id, Private: ed25519.NewKeyFromSeed([]byte(strings.Repeat(string(seed), ed25519.SeedSize)))}
+}
+
+func testSigner(t *testing.T, keys ...Key) *Signer {
+	t.Helper()
+
+	signer, err := New("greenlight", "greenlight-api", keys)
+	require.NoError(t, err)
+
+	return signer
+}
+
+// encode builds a token from any header and claims, signed with key's private key.
+func encode(t *testing.T, key Key, h header, claims any) string {
+	t.Helper()
+
+	hj, err := json.Marshal(h)
+	require.NoError(t, err)
+
+	cj, err := json.Marshal(claims)
+	require.NoError(t, err)
+
+	signingInput := encoding.EncodeToString(hj) + "." + encoding.EncodeToString(cj)
+
+	return signingInput + "." + encoding.EncodeToString(ed25519.Sign(key.Private, []byte(signingInput)))
+}
+
+// validClaims returns claims that the test signer accepts.
+func validClaims() Claims {
+	now := time.Now().Unix()
+
+	return Claims{
+		Issuer:    "greenlight",
+		Audience:  "greenlight-api",
+		Subject:   "42",
+		ID:        "id",
+		IssuedAt:  now,
+		NotBefore: now,
+		ExpiresAt: now + 60,
+	}
+}
+
+func TestSignVerify(t *testing.T) {
+	signer := testSigner(t, testKey("k1", 1))
+
+	token, expiry, err := signer.Sign("42", 7, 15*time.Minute)
+	require.NoError(t, err)
+	require.WithinDuration(t, time.Now().Add(15*time.Minute), expiry, 2*time.Second)
+
+	claims, err := signer.Verify(token)
+	require.NoError(t, err)
+	require.Equal(t, "42", claims.Subject)
+	require.Equal(t, int64(7), claims.SessionID)
+	require.Equal(t, "greenlight", claims.Issuer)
+	require.Equal(t, "greenlight-api", claims.Audience)
+	require.Equal(t, expiry.Unix(), claims.ExpiresAt)
+	require.NotEmpty(t, claims.ID)
+
+	other, _, err := signer.Sign("42", 7, 15*time.Minute)
+	require.NoError(t, err)
+	require.NotEqual(t, token, other, "tokens have random ids")
+}
+
+func TestVerifyRejects(t *testing.T) {
+	key := testKey("k1", 1)
+	signer := testSigner(t, key)
+
+	valid := encode(t, key, header{Algorithm: "EdDSA", Type: "JWT", KeyID: "k1"}, validClaims())
+	parts := strings.Split(valid, ".")
+
+	// An HS256 token "signed" with the public key, the classic algorithm confusion.
+	hs256 := func() string {
+		hj, _ := json.Marshal(header{Algorithm: "HS256", Type: "JWT", KeyID: "k1"})
+		input := encoding.EncodeToString(hj) + "." + parts[1]
+		mac := hmac.New(sha256.New, key.public())
+		mac.Write([]byte(input))
+		return input + "." + encoding.EncodeToString(mac.Sum(nil))
+	}
+
+	none := func() string {
+		hj, _ := json.Marshal(header{Algorithm: "none", Type: "JWT", KeyID: "k1"})
+		return encoding.EncodeToString(hj) + "." + parts[1] + "."
+	}
+
+	tampered := func() string {
+		claims := validClaims()
+		claims.Subject = "1"
+		cj, _ := json.Marshal(claims)
+		return parts[0] + "." + encoding.EncodeToString(cj) + "." + parts[2]
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		err   error
+	}{
+		{"empty", "", ErrMalformed},
+		{"two parts", parts[0] + "." + parts[1], ErrMalformed},
+		{"header not base64", "!!!." + parts[1] + "." + parts[2], ErrMalformed},
+		{"header not JSON", encoding.EncodeToString([]byte("{")) + "." + parts[1] + "." + parts[2], ErrMalformed},
+		{"signature not base64", parts[0] + "." + parts[1] + ".!!!", ErrMalformed},
+		{"alg none", none(), ErrSignature},
+		{"alg HS256", hs256(), ErrSignature},
+		{"unknown kid", encode(t, key, header{Algorithm: "EdDSA", Type: "JWT", KeyID: "k9"}, validClaims()), ErrUnknownKey},
+		{"signed by another key", encode(t, testKey("k1", 2), header{Algorithm: "EdDSA", Type: "JWT", KeyID: "k1"}, validClaims()), ErrSignature},
+		{"tampered payload", tampered(), ErrSignature},
+		{"signature of another token", parts[0] + "." + parts[1] + "." + strings.Split(encode(t, key, header{Algorithm: "EdDSA", Type: "JWT", KeyID: "k1"}, Claims{}), ".")[2], ErrSignature},
+		{"claims not JSON", encode(t, key, header{Algorithm: "EdDSA", Type: "JWT", KeyID: "k1"}, "claims"), ErrMalformed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := signer.Verify(tt.token)
+			require.ErrorIs(t, err, tt.err)
+			require.Nil(t, claims)
+		})
+	}
+
+	claims, err := signer.Verify(valid)
+	require.NoError(t, err)
+	require.Equal(t, "42", claims.Subject)
+}
+
+func TestVerifyClaims(t *testing.T) {
+	key := testKey("k1", 1)
+	signer := testSigner(t, key)
+
+	// A few seconds either side of the leeway edge, so the test doesn't race the clock.
+	const margin = 5
+	now := time.Now().Unix()
+	edge := int64(leeway / time.Second)
+
+	tests := []struct {
+		name   string
+		change func(c *Claims)
+		err    error
+	}{
+		{"valid", func(c *Claims) {}, nil},
+		{"expired within the leeway", func(c *Claims) { c.ExpiresAt = now - edge + margin }, nil},
+		{"expired beyond the leeway", func(c *Claims) { c.ExpiresAt = now - edge - margin }, ErrExpired},
+		{"not yet valid within the leeway", func(c *Claims) { c.NotBefore = now + edge - margin }, nil},
+		{"not yet valid beyond the leeway", func(c *Claims) { c.NotBefore = now + edge + margin }, ErrClaims},
+		{"wrong issuer", func(c *Claims) { c.Issuer = "someone-else" }, ErrClaims},
+		{"wrong audience", func(c *Claims) { c.Audience = "another-api" }, ErrClaims},
+		{"no audience", func(c *Claims) { c.Audience = "" }, ErrClaims},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := validClaims()
+			tt.change(&claims)
+
+			_, err := signer.Verify(encode(t, key, header{Algorithm: "EdDSA", Type: "JWT", KeyID: "k1"}, claims))
+			if tt.err == nil {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorIs(t, err, tt.err)
+		})
+	}
+
+	// A token signed for another audience doesn't verify here, even with our key.
+	other, err := New("greenlight", "another-api", []Key{key})
+	require.NoError(t, err)
+
+	token, _, err := other.Sign("42", 0, time.Minute)
+	require.NoError(t, err)
+
+	_, err = signer.Verify(token)
+	require.ErrorIs(t, err, ErrClaims)
+}
+
+func TestKeyRotation(t *testing.T) {
+	oldKey := testKey("2023", 1)
+	newKey := testKey("2024", 2)
+
+	before := testSigner(t, oldKey)
+	oldToken, _, err := before.Sign("42", 0, time.Minute)
+	require.NoError(t, err)
+
+	// The new key signs, the old one still verifies what it signed.
+	during := testSigner(t, newKey, oldKey)
+
+	newToken, _, err := during.Sign("42", 0, time.Minute)
+	require.NoError(t, err)
+	require.Contains(t, headerOf(t, newToken), `"kid":"2024"`)
+
+	for _, token := range []string{oldToken, newToken} {
+		_, err = during.Verify(token)
+		require.NoError(t, err)
+	}
+
+	// Once the old key is removed, its tokens stop working.
+	after := testSigner(t, newKey)
+
+	_, err = after.Verify(newToken)
+	require.NoError(t, err)
+
+	_, err = after.Verify(oldToken)
+	require.ErrorIs(t, err, ErrUnknownKey)
+}
+
+func headerOf(t *testing.T, token string) string {
+	t.Helper()
+
+	raw, err := encoding.DecodeString(strings.Split(token, ".")[0])
+	require.NoError(t, err)
+
+	return string(raw)
+}
+
+func TestParseKeys(t *testing.T) {
+	seed := base64.StdEncoding.EncodeToString([]byte(strings.Repeat("a", ed25519.SeedSize)))
+	short := base64.StdEncoding.EncodeToString([]byte("short"))
+
+	tests := []struct {
+		name string
+		keys string
+		ids  []string
+		err  string
+	}{
+		{"one key", "k1:" + seed, []string{"k1"}, ""},
+		{"several keys with spaces", " k2:" + seed + " , k1:" + seed + ",", []string{"k2", "k1"}, ""},
+		{"empty", "", nil, ""},
+		{"no kid", ":" + seed, nil, `jwt: key ":` + seed + `" is not in kid:seed form`},
+		{"no seed", "k1", nil, `jwt: key "k1" is not in kid:seed form`},
+		{"seed not base64", "k1:!!!", nil, `jwt: seed of key "k1" must be 32 bytes in base64`},
+		{"seed too short", "k1:" + short, nil, `jwt: seed of key "k1" must be 32 bytes in base64`},
+		{"duplicate kid", "k1:" + seed + ",k1:" + seed, nil, `jwt: duplicate key id "k1"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys, err := ParseKeys(tt.keys)
+
+			if tt.err != "" {
+				require.EqualError(t, err, tt.err)
+				return
+			}
+
+			require.NoError(t, err)
+
+			var ids []string
+			for _, key := range keys {
+				ids = append(ids, key.ID)
+			}
+			require.Equal(t, tt.ids, ids)
+		})
+	}
+
+	_, err := New("greenlight", "greenlight-api", nil)
+	require.EqualError(t, err, "jwt: at least one key is required")
+}
+
+func TestJWKS(t *testing.T) {
+	key := testKey("k1", 1)
+	jwks := testSigner(t, key).JWKS()
+
+	require.Len(t, jwks, 1)
+	require.Equal(t, "OKP", jwks[0].KeyType)
+	require.Equal(t, "Ed25519", jwks[0].Curve)
+	require.Equal(t, "k1", jwks[0].KeyID)
+	require.Equal(t, "EdDSA", jwks[0].Algorithm)
+
+	x, err := encoding.DecodeString(jwks[0].X)
+	require.NoError(t, err)
+	require.Equal(t, []byte(key.public()), x)
+}
@@ -3,64 +3,185 @@ package mailer
 import (
 	"bytes"
 	"embed"
-	"html/template"
-	"time"
-
-	"github.com/go-mail/mail/v2"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"text/template"
 )
 
+// DefaultLocale is used when a template has no variant for the requested locale.
+const DefaultLocale = "en"
+
+// templateFS holds the email templates. Every email is a file named
+// "<name>.<locale>.tmpl" defining "subject", "plainBody" and "htmlContent". Files in
+// templates/layouts are shared partials: "base.tmpl" wraps htmlContent into the
+// "htmlBody" document and "<partial>.<locale>.tmpl" files hold localized snippets.
+//
+//go:embed "templates"
 var templateFS embed.FS
 
+// Mailer renders email templates and hands the result to a Transport.
 type Mailer struct {
-	dialer *mail.Dialer
-	sender string
+	transport Transport
+	sender    string
 }
 
-func New(host string, port int, username, password, sender string) Mailer {
-	dialer := mail.NewDialer(host, port, username, password)
-	dialer.Timeout = 5 * time.Second
+// Message is a rendered email.
+type Message struct {
+	Locale    string `json:"locale"`
+	Subject   string `json:"subject"`
+	PlainBody string `json:"plain_body"`
+	HTMLBody  string `json:"html_body"`
+}
 
+func New(transport Transport, sender string) Mailer {
 	return Mailer{
-		dialer: dialer,
-		sender: sender,
+		transport: transport,
+		sender:    sender,
 	}
 }
 
-func (m Mailer) Send(recipient, templateFile string, data any) error {
-	tmpl, err := template.New("email").ParseFS(templateFS, "templates/"+templateFile)
+// Send renders the template in the recipient's locale and delivers it. templateName
+// is the template without locale and extension, e.g. "user_welcome".
+func (m Mailer) Send(recipient, locale, templateName string, data any) error {
+	rendered, err := Render(templateName, locale, data)
 	if err != nil {
 		return err
 	}
 
-	subject := new(bytes.Buffer)
-	err = tmpl.ExecuteTemplate(subject, "subject", data)
+	return m.transport.Deliver(&Email{
+		From:     m.sender,
+		To:       recipient,
+		Template: strings.TrimSuffix(templateName, ".tmpl"),
+		Message:  *rendered,
+	})
+}
+
+// Render executes the best matching locale variant of a template together with the
+// shared layouts. The subject and plain body are text, so they are rendered with
+// text/template; only htmlBody goes through html/template and its escaping.
+func Render(templateName, locale string, data any) (*Message, error) {
+	// Older callers (and queued emails) pass the file name, e.g. "user_welcome.tmpl".
+	templateName = strings.TrimSuffix(templateName, ".tmpl")
+
+	file, resolved, ok := resolve(templateName, locale)
+	if !ok {
+		return nil, fmt.Errorf("mailer: template %q not found", templateName)
+	}
+
+	files, err := layoutFiles(resolved)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	plainBody := new(bytes.Buffer)
-	err = tmpl.ExecuteTemplate(plainBody, "plainBody", data)
+	files = append(files, file)
+
+	text, err := template.New("email").ParseFS(templateFS, files...)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	htmlBody := new(bytes.Buffer)
-	err = tmpl.ExecuteTemplate(htmlBody, "htmlBody", data)
+	html, err := htmltemplate.New("email").ParseFS(templateFS, files...)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	msg := mail.NewMessage()
-	msg.SetHeader("To", recipient)
-	msg.SetHeader("From", m.sender)
-	msg.SetHeader("Subject", subject.String())
-	msg.SetBody("text/plain", plainBody.String())
-	msg.AddAlternative("text/html", htmlBody.String())
+	msg := &Message{Locale: resolved}
 
-	err = m.dialer.DialAndSend(msg)
+	parts := []struct {
+		name string
+		tmpl interface {
+			ExecuteTemplate(w io.Writer, name string, data any) error
+		}
+		dst *string
+	}{
+		{"subject", text, &msg.Subject},
+		{"plainBody", text, &msg.PlainBody},
+		{"htmlBody", html, &msg.HTMLBody},
+	}
+
+	for _, part := range parts {
+		buf := new(bytes.Buffer)
+		err = part.tmpl.ExecuteTemplate(buf, part.name, data)
+		if err != nil {
+			return nil, err
+		}
+		*part.dst = buf.String()
+	}
+
+	return msg, nil
+}
+
+// Templates returns the names of all email templates, e.g. ["user_welcome"].
+func Templates() []string {
+	entries, err := fs.ReadDir(templateFS, "templates")
 	if err != nil {
-		return err
+		return nil
+	}
+
+	seen := make(map[string]bool)
+	names := []string{}
+
+	for _, entry := range entries {
+		name := strings.SplitN(entry.Name(), ".", 2)[0]
+		if entry.IsDir() || seen[name] {
+			continue
+		}
+		seen[name] = true
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// resolve finds the file for name in the closest available locale: the exact tag,
+// then its primary language ("pt-BR" -> "pt"), then DefaultLocale.
+func resolve(name, locale string) (file, resolved string, ok bool) {
+	candidates := []string{locale, strings.SplitN(locale, "-", 2)[0], DefaultLocale}
+
+	for _, candidate := range candidates {
+		if candidate == "" {
+			continue
+		}
+
+		file = path.Join("templates", fmt.Sprintf("%s.%s.tmpl", name, candidate))
+		if _, err := fs.Stat(templateFS, file); err == nil {
+			return file, candidate, true
+		}
+	}
+
+	return "", "", false
+}
+
+// layoutFiles returns the shared partials for a locale: every unlocalized layout and
+// the best locale variant of each localized one.
+func layoutFiles(locale string) ([]string, error) {
+	entries, err := fs.ReadDir(templateFS, "templates/layouts")
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	files := []string{}
+
+	for _, entry := range entries {
+		parts := strings.Split(entry.Name(), ".")
+		switch {
+		case len(parts) == 2:
+			files = append(files, path.Join("templates/layouts", entry.Name()))
+		case len(parts) == 3 && !seen[parts[0]]:
+			seen[parts[0]] = true
+			if file, _, ok := resolve(path.Join("layouts", parts[0]), locale); ok {
+				files = append(files, file)
+			}
+		}
 	}
 
-	return nil
+	return files, nil
 }
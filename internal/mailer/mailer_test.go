@@ -0,0 +1,108 @@
+package mailer
+
+import (
+	"bytes"
+	"log"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// sampleData has every value any template uses.
+var sampleData = map[string]any{
+	"userID":           123,
+	"activationToken":  "Y3QMGX3PJ3WLRL2YRTQGQ6KRHU",
+	"name":             "Alice",
+	"emailChangeToken": "P4B3URJZJ2NO5NRPZLT6WD2K3Q",
+	"unlockToken":      "P4B3URJZJ2NO5NRPZLT6WD2K3Q",
+	"lockedUntil":      "Mon, 02 Jan 2006 15:04:05 UTC",
+}
+
+func TestRenderEveryTemplate(t *testing.T) {
+	require.Equal(t, []string{"account_locked", "email_change", "user_welcome"}, Templates())
+
+	for _, name := range Templates() {
+		for _, locale := range []string{"en", "ru"} {
+			t.Run(name+"."+locale, func(t *testing.T) {
+				msg, err := Render(name, locale, sampleData)
+				require.NoError(t, err)
+
+				require.Equal(t, locale, msg.Locale)
+				require.NotEmpty(t, msg.Subject)
+				require.NotContains(t, msg.Subject, "\n")
+				require.NotEmpty(t, strings.TrimSpace(msg.PlainBody))
+				require.Contains(t, msg.HTMLBody, "<!doctype html>")
+				require.NotContains(t, msg.PlainBody+msg.HTMLBody, "<no value>")
+			})
+		}
+	}
+}
+
+func TestRenderLocaleFallback(t *testing.T) {
+	tests := []struct {
+		locale string
+		want   string
+	}{
+		{"en", "en"},
+		{"ru", "ru"},
+		{"ru-RU", "ru"},
+		{"de", DefaultLocale},
+		{"de-AT", DefaultLocale},
+		{"", DefaultLocale},
+	}
+
+	for _, tt := range tests {
+		msg, err := Render("user_welcome", tt.locale, sampleData)
+		require.NoError(t, err, tt.locale)
+		require.Equal(t, tt.want, msg.Locale, tt.locale)
+	}
+
+	en, err := Render("user_welcome", "en", sampleData)
+	require.NoError(t, err)
+
+	de, err := Render("user_welcome.tmpl", "de", sampleData)
+	require.NoError(t, err)
+	require.Equal(t, en, de)
+}
+
+func TestRenderEscapesOnlyHTML(t *testing.T) {
+	data := map[string]any{"name": `O'Brien <b>`, "emailChangeToken": "P4B3URJZJ2NO5NRPZLT6WD2K3Q"}
+
+	msg, err := Render("email_change", "en", data)
+	require.NoError(t, err)
+
+	require.Contains(t, msg.PlainBody, `Hi O'Brien <b>,`)
+	require.Contains(t, msg.PlainBody, `{"token": "P4B3URJZJ2NO5NRPZLT6WD2K3Q"}`)
+	require.Contains(t, msg.HTMLBody, `Hi O&#39;Brien &lt;b&gt;,`)
+}
+
+func TestRenderUnknownTemplate(t *testing.T) {
+	_, err := Render("no_such_email", "en", sampleData)
+	require.EqualError(t, err, `mailer: template "no_such_email" not found`)
+}
+
+func TestMailerSend(t *testing.T) {
+	transport := NewMemory()
+
+	err := New(transport, "Greenlight <no-reply@greenlight.example.com>").Send("alice@example.com", "ru", "user_welcome", sampleData)
+	require.NoError(t, err)
+
+	emails := transport.Emails()
+	require.Len(t, emails, 1)
+	require.Equal(t, "alice@example.com", emails[0].To)
+	require.Equal(t, "Greenlight <no-reply@greenlight.example.com>", emails[0].From)
+	require.Equal(t, "user_welcome", emails[0].Template)
+	require.Equal(t, "ru", emails[0].Locale)
+}
+
+func TestLogLeavesOutTheBody(t *testing.T) {
+	var buf bytes.Buffer
+
+	err := New(NewLog(log.New(&buf, "", 0)), "Greenlight <no-reply@greenlight.example.com>").Send("alice@example.com", "en", "user_welcome", sampleData)
+	require.NoError(t, err)
+
+	require.Contains(t, buf.String(), "alice@example.com")
+	require.Contains(t, buf.String(), "user_welcome")
+	require.NotContains(t, buf.String(), sampleData["activationToken"])
+}
@@ -0,0 +1,150 @@
+package mailer
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-mail/mail/v2"
+)
+
+// Email is a rendered message ready to be delivered.
+type Email struct {
+	From     string
+	To       string
+	Template string
+	Message
+}
+
+// Transport delivers rendered emails. Implementations must be safe for concurrent use,
+// the outbox workers share a single Mailer.
+type Transport interface {
+	Deliver(email *Email) error
+}
+
+// mailMessage converts the email into a go-mail message with plain and HTML parts.
+func (e *Email) mailMessage() *mail.Message {
+	msg := mail.NewMessage()
+	msg.SetHeader("To", e.To)
+	msg.SetHeader("From", e.From)
+	msg.SetHeader("Subject", e.Subject)
+	msg.SetHeader("Content-Language", e.Locale)
+	msg.SetBody("text/plain", e.PlainBody)
+	msg.AddAlternative("text/html", e.HTMLBody)
+	return msg
+}
+
+// SMTP delivers emails through an SMTP server.
+type SMTP struct {
+	dialer *mail.Dialer
+}
+
+func NewSMTP(host string, port int, username, password string) *SMTP {
+	dialer := mail.NewDialer(host, port, username, password)
+	dialer.Timeout = 5 * time.Second
+
+	return &SMTP{dialer: dialer}
+}
+
+func (t *SMTP) Deliver(email *Email) error {
+	return t.dialer.DialAndSend(email.mailMessage())
+}
+
+// Maildir writes every email as a file into a maildir (dir/tmp, dir/new, dir/cur), so
+// it can be opened with any mail client that understands the format.
+type Maildir struct {
+	dir     string
+	counter uint64
+}
+
+func NewMaildir(dir string) (*Maildir, error) {
+	for _, sub := range []string{"tmp", "new", "cur"} {
+		err := os.MkdirAll(filepath.Join(dir, sub), 0o700)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &Maildir{dir: dir}, nil
+}
+
+// Deliver follows the maildir protocol: write into tmp/ under a unique name, then
+// rename into new/.
+func (t *Maildir) Deliver(email *Email) error {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "localhost"
+	}
+
+	name := fmt.Sprintf("%d.%d_%d.%s", time.Now().UnixNano(), os.Getpid(), atomic.AddUint64(&t.counter, 1), hostname)
+
+	var buf bytes.Buffer
+	_, err = email.mailMessage().WriteTo(&buf)
+	if err != nil {
+		return err
+	}
+
+	tmp := filepath.Join(t.dir, "tmp", name)
+
+	err = os.WriteFile(tmp, buf.Bytes(), 0o600)
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, filepath.Join(t.dir, "new", name))
+}
+
+// Memory records delivered emails instead of sending them. It is meant for tests,
+// which can assert on Emails() after exercising the API.
+type Memory struct {
+	mu     sync.Mutex
+	emails []Email
+}
+
+func NewMemory() *Memory {
+	return &Memory{}
+}
+
+func (t *Memory) Deliver(email *Email) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.emails = append(t.emails, *email)
+	return nil
+}
+
+// Emails returns a copy of everything delivered so far, oldest first.
+func (t *Memory) Emails() []Email {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return append([]Email(nil), t.emails...)
+}
+
+// Reset forgets all recorded emails.
+func (t *Memory) Reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.emails = nil
+}
+
+// Log only writes the recipient, template and subject to a logger. The body is left
+// out, it holds activation and reset tokens that must not end up in the logs.
+type Log struct {
+	logger *log.Logger
+}
+
+func NewLog(logger *log.Logger) *Log {
+	return &Log{logger: logger}
+}
+
+func (t *Log) Deliver(email *Email) error {
+	t.logger.Printf("email to %s (%s, %s): %s", email.To, email.Template, email.Locale, email.Subject)
+	return nil
+}
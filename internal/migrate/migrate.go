@@ -0,0 +1,376 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+var (
+	ErrDirty          = errors.New("migrate: database is dirty, a migration failed; fix it by hand and then force a version")
+	ErrUnknownVersion = errors.New("migrate: no migration with that version")
+)
+
+// lockID identifies the advisory lock held while migrating, so that several API
+// instances starting with -db-automigrate don't apply the same migration twice.
+const lockID = 7131923871
+
+var filenameRX = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// Migration is one numbered step. Versions don't have to be consecutive.
+type Migration struct {
+	Version int64
+	Name    string
+	up      string
+	down    string
+}
+
+// Migrator applies the migrations found in FS. Progress is recorded in the
+// schema_migrations table, which has the same layout golang-migrate uses, so
+// databases migrated with that tool are picked up where they left off.
+type Migrator struct {
+	DB     *sql.DB
+	FS     fs.FS
+	Logger *log.Logger
+}
+
+func New(db *sql.DB, fsys fs.FS, logger *log.Logger) *Migrator {
+	return &Migrator{
+		DB:     db,
+		FS:     fsys,
+		Logger: logger,
+	}
+}
+
+// Migrations returns the available migrations ordered by version.
+func (m *Migrator) Migrations() ([]*Migration, error) {
+	entries, err := fs.ReadDir(m.FS, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int64]*Migration)
+
+	for _, entry := range entries {
+		match := filenameRX.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			continue
+		}
+
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil || version < 1 {
+			return nil, fmt.Errorf("migrate: invalid version in %s", entry.Name())
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		}
+		if migration.Name != match[2] {
+			return nil, fmt.Errorf("migrate: version %d is used by both %s and %s", version, migration.Name, match[2])
+		}
+
+		if match[3] == "up" {
+			migration.up = entry.Name()
+		} else {
+			migration.down = entry.Name()
+		}
+	}
+
+	migrations := make([]*Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.up == "" || migration.down == "" {
+			return nil, fmt.Errorf("migrate: version %d needs both an up and a down file", migration.Version)
+		}
+		migrations = append(migrations, migration)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+// Version returns the current version, 0 if no migration has been applied yet.
+func (m *Migrator) Version() (version int64, dirty bool, err error) {
+	err = m.withLock(func(ctx context.Context, conn *sql.Conn) error {
+		version, dirty, err = currentVersion(ctx, conn)
+		return err
+	})
+
+	return version, dirty, err
+}
+
+// Up applies every pending migration.
+func (m *Migrator) Up() error {
+	migrations, err := m.Migrations()
+	if err != nil {
+		return err
+	}
+	if len(migrations) == 0 {
+		return nil
+	}
+
+	return m.Goto(migrations[len(migrations)-1].Version)
+}
+
+// Down reverts the last n applied migrations.
+func (m *Migrator) Down(n int) error {
+	migrations, err := m.Migrations()
+	if err != nil {
+		return err
+	}
+
+	return m.withLock(func(ctx context.Context, conn *sql.Conn) error {
+		current, _, err := currentVersion(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		i, err := indexOf(migrations, current)
+		if err != nil {
+			return err
+		}
+
+		target := int64(0)
+		if i-n >= 0 {
+			target = migrations[i-n].Version
+		}
+
+		return m.migrate(ctx, conn, migrations, target)
+	})
+}
+
+// Goto migrates up or down until version is the current one. Version 0 reverts
+// everything.
+func (m *Migrator) Goto(version int64) error {
+	migrations, err := m.Migrations()
+	if err != nil {
+		return err
+	}
+
+	if _, err := indexOf(migrations, version); err != nil {
+		return err
+	}
+
+	return m.withLock(func(ctx context.Context, conn *sql.Conn) error {
+		return m.migrate(ctx, conn, migrations, version)
+	})
+}
+
+// Force records version as the current, clean version without running anything. It
+// is the way out after a migration failed halfway and was fixed by hand.
+func (m *Migrator) Force(version int64) error {
+	migrations, err := m.Migrations()
+	if err != nil {
+		return err
+	}
+
+	if _, err := indexOf(migrations, version); err != nil {
+		return err
+	}
+
+	return m.withLock(func(ctx context.Context, conn *sql.Conn) error {
+		tx, err := conn.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		err = setVersion(ctx, tx, version, false)
+		if err != nil {
+			return err
+		}
+
+		return tx.Commit()
+	})
+}
+
+// migrate steps from the current version to target. Each migration runs in its own
+// transaction together with the version update. A failing migration is rolled back
+// and the database is marked dirty at the version of that migration, so that nothing
+// else runs until someone has looked at it and forced a version.
+func (m *Migrator) migrate(ctx context.Context, conn *sql.Conn, migrations []*Migration, target int64) error {
+	current, dirty, err := currentVersion(ctx, conn)
+	if err != nil {
+		return err
+	}
+	if dirty {
+		return ErrDirty
+	}
+
+	i, err := indexOf(migrations, current)
+	if err != nil {
+		return err
+	}
+
+	for current < target {
+		i++
+		err = m.apply(ctx, conn, migrations[i].up, migrations[i].Version)
+		if err != nil {
+			return markDirty(ctx, conn, migrations[i].Version, err)
+		}
+		current = migrations[i].Version
+	}
+
+	for current > target {
+		previous := int64(0)
+		if i > 0 {
+			previous = migrations[i-1].Version
+		}
+
+		err = m.apply(ctx, conn, migrations[i].down, previous)
+		if err != nil {
+			return markDirty(ctx, conn, migrations[i].Version, err)
+		}
+		current = previous
+		i--
+	}
+
+	return nil
+}
+
+func (m *Migrator) apply(ctx context.Context, conn *sql.Conn, file string, version int64) error {
+	query, err := fs.ReadFile(m.FS, file)
+	if err != nil {
+		return err
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Without arguments lib/pq sends the file as a simple query, which may hold
+	// several statements.
+	_, err = tx.ExecContext(ctx, string(query))
+	if err != nil {
+		return fmt.Errorf("migrate: %s: %w", file, err)
+	}
+
+	err = setVersion(ctx, tx, version, false)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	if m.Logger != nil {
+		m.Logger.Printf("applied migration %s", file)
+	}
+
+	return nil
+}
+
+// withLock runs fn on a single connection holding the migration advisory lock.
+// Session-level advisory locks belong to a connection, which is why everything
+// goes through conn rather than the pool.
+func (m *Migrator) withLock(fn func(ctx context.Context, conn *sql.Conn) error) error {
+	// Migrations can take a while, so there's no deadline here.
+	ctx := context.Background()
+
+	conn, err := m.DB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_, err = conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, lockID)
+	if err != nil {
+		return err
+	}
+	defer conn.ExecContext(ctx, `SELECT pg_advisory_unlock($1)`, lockID)
+
+	query := `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version bigint NOT NULL PRIMARY KEY,
+			dirty boolean NOT NULL
+		)`
+
+	_, err = conn.ExecContext(ctx, query)
+	if err != nil {
+		return err
+	}
+
+	return fn(ctx, conn)
+}
+
+func currentVersion(ctx context.Context, conn *sql.Conn) (int64, bool, error) {
+	var version int64
+	var dirty bool
+
+	err := conn.QueryRowContext(ctx, `SELECT version, dirty FROM schema_migrations LIMIT 1`).Scan(&version, &dirty)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return 0, false, nil
+		default:
+			return 0, false, err
+		}
+	}
+
+	return version, dirty, nil
+}
+
+// markDirty records version as dirty after its migration failed with migrateErr,
+// which is returned.
+func markDirty(ctx context.Context, conn *sql.Conn, version int64, migrateErr error) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("%w (marking version %d dirty: %s)", migrateErr, version, err)
+	}
+	defer tx.Rollback()
+
+	err = setVersion(ctx, tx, version, true)
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		return fmt.Errorf("%w (marking version %d dirty: %s)", migrateErr, version, err)
+	}
+
+	return migrateErr
+}
+
+// setVersion replaces the single schema_migrations row; version 0 leaves the table
+// empty.
+func setVersion(ctx context.Context, tx *sql.Tx, version int64, dirty bool) error {
+	_, err := tx.ExecContext(ctx, `DELETE FROM schema_migrations`)
+	if err != nil {
+		return err
+	}
+
+	if version == 0 {
+		return nil
+	}
+
+	_, err = tx.ExecContext(ctx, `INSERT INTO schema_migrations (version, dirty) VALUES ($1, $2)`, version, dirty)
+	return err
+}
+
+// indexOf returns the position of version in migrations, -1 for version 0.
+func indexOf(migrations []*Migration, version int64) (int, error) {
+	if version == 0 {
+		return -1, nil
+	}
+
+	for i, migration := range migrations {
+		if migration.Version == version {
+			return i, nil
+		}
+	}
+
+	return 0, fmt.Errorf("%w: %d", ErrUnknownVersion, version)
+}
@@ -0,0 +1,228 @@
+package migrate_test
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"testing/fstest"
+
+	"github.com/shynggys9219/greenlight/internal/migrate"
+	"github.com/shynggys9219/greenlight/internal/testdb"
+	"github.com/shynggys9219/greenlight/migrations"
+	"github.com/stretchr/testify/require"
+)
+
+func file(sql string) *fstest.MapFile {
+	return &fstest.MapFile{Data: []byte(sql)}
+}
+
+// testFS has three migrations with a gap in the versions.
+func testFS() fstest.MapFS {
+	return fstest.MapFS{
+		"000001_create_a.up.sql":   file(`CREATE TABLE a (id int)`),
+		"000001_create_a.down.sql": file(`DROP TABLE a`),
+		"000002_create_b.up.sql":   file(`CREATE TABLE b (id int); INSERT INTO b VALUES (1)`),
+		"000002_create_b.down.sql": file(`DROP TABLE b`),
+		"000004_create_c.up.sql":   file(`CREATE TABLE c (id int)`),
+		"000004_create_c.down.sql": file(`DROP TABLE c`),
+		"README.md":                file(`not a migration`),
+	}
+}
+
+func TestMigrations(t *testing.T) {
+	tests := []struct {
+		name     string
+		fsys     fstest.MapFS
+		versions []int64
+		err      string
+	}{
+		{
+			name:     "ordered by version",
+			fsys:     testFS(),
+			versions: []int64{1, 2, 4},
+		},
+		{
+			name:     "no migrations",
+			fsys:     fstest.MapFS{},
+			versions: []int64{},
+		},
+		{
+			name: "missing down file",
+			fsys: fstest.MapFS{"000001_create_a.up.sql": file(``)},
+			err:  "migrate: version 1 needs both an up and a down file",
+		},
+		{
+			name: "version used twice",
+			fsys: fstest.MapFS{
+				"000001_create_a.up.sql":   file(``),
+				"000001_create_a.down.sql": file(``),
+				"000001_create_b.up.sql":   file(``),
+			},
+			err: "migrate: version 1 is used by both create_a and create_b",
+		},
+		{
+			name: "version zero",
+			fsys: fstest.MapFS{"000000_create_a.up.sql": file(``)},
+			err:  "migrate: invalid version in 000000_create_a.up.sql",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			list, err := migrate.New(nil, tt.fsys, nil).Migrations()
+
+			if tt.err != "" {
+				require.EqualError(t, err, tt.err)
+				return
+			}
+
+			require.NoError(t, err)
+
+			versions := []int64{}
+			for _, migration := range list {
+				versions = append(versions, migration.Version)
+			}
+			require.Equal(t, tt.versions, versions)
+		})
+	}
+}
+
+// tables returns which of a, b, c and d exist in the test's schema.
+func tables(t *testing.T, db *sql.DB) []string {
+	t.Helper()
+
+	rows, err := db.QueryContext(context.Background(), `
+		SELECT table_name
+		FROM information_schema.tables
+		WHERE table_schema = current_schema() AND table_name IN ('a', 'b', 'c', 'd')
+		ORDER BY table_name`)
+	require.NoError(t, err)
+	defer rows.Close()
+
+	names := []string{}
+	for rows.Next() {
+		var name string
+		require.NoError(t, rows.Scan(&name))
+		names = append(names, name)
+	}
+	require.NoError(t, rows.Err())
+
+	return names
+}
+
+func requireVersion(t *testing.T, m *migrate.Migrator, version int64, dirty bool) {
+	t.Helper()
+
+	v, d, err := m.Version()
+	require.NoError(t, err)
+	require.Equal(t, version, v, "version")
+	require.Equal(t, dirty, d, "dirty")
+}
+
+func TestUpDownGoto(t *testing.T) {
+	db := testdb.OpenEmpty(t)
+	m := migrate.New(db, testFS(), nil)
+
+	requireVersion(t, m, 0, false)
+
+	require.NoError(t, m.Up())
+	requireVersion(t, m, 4, false)
+	require.Equal(t, []string{"a", "b", "c"}, tables(t, db))
+
+	// Up with nothing pending changes nothing.
+	require.NoError(t, m.Up())
+	requireVersion(t, m, 4, false)
+
+	require.NoError(t, m.Down(1))
+	requireVersion(t, m, 2, false)
+	require.Equal(t, []string{"a", "b"}, tables(t, db))
+
+	require.NoError(t, m.Goto(4))
+	requireVersion(t, m, 4, false)
+
+	require.NoError(t, m.Goto(1))
+	requireVersion(t, m, 1, false)
+	require.Equal(t, []string{"a"}, tables(t, db))
+
+	require.ErrorIs(t, m.Goto(3), migrate.ErrUnknownVersion)
+	requireVersion(t, m, 1, false)
+
+	require.NoError(t, m.Goto(4))
+	require.NoError(t, m.Down(10))
+	requireVersion(t, m, 0, false)
+	require.Empty(t, tables(t, db))
+}
+
+func TestFailedMigrationIsDirty(t *testing.T) {
+	db := testdb.OpenEmpty(t)
+
+	fsys := testFS()
+	fsys["000005_create_d.up.sql"] = file(`CREATE TABLE d (id int); SELECT no_such_column FROM a`)
+	fsys["000005_create_d.down.sql"] = file(`DROP TABLE d`)
+
+	m := migrate.New(db, fsys, nil)
+
+	err := m.Up()
+	require.ErrorContains(t, err, "migrate: 000005_create_d.up.sql")
+	require.NotErrorIs(t, err, migrate.ErrDirty)
+
+	// The failed migration was rolled back as a whole, but the database is dirty.
+	requireVersion(t, m, 5, true)
+	require.Equal(t, []string{"a", "b", "c"}, tables(t, db))
+
+	require.ErrorIs(t, m.Up(), migrate.ErrDirty)
+	require.ErrorIs(t, m.Down(1), migrate.ErrDirty)
+	require.ErrorIs(t, m.Goto(1), migrate.ErrDirty)
+
+	require.ErrorIs(t, m.Force(3), migrate.ErrUnknownVersion)
+	requireVersion(t, m, 5, true)
+
+	// Forcing the version the schema is really at clears the flag.
+	require.NoError(t, m.Force(4))
+	requireVersion(t, m, 4, false)
+
+	fsys["000005_create_d.up.sql"] = file(`CREATE TABLE d (id int)`)
+
+	require.NoError(t, m.Up())
+	requireVersion(t, m, 5, false)
+	require.Equal(t, []string{"a", "b", "c", "d"}, tables(t, db))
+}
+
+func TestFailedDownMigrationIsDirty(t *testing.T) {
+	db := testdb.OpenEmpty(t)
+
+	fsys := testFS()
+	fsys["000004_create_c.down.sql"] = file(`DROP TABLE no_such_table`)
+
+	m := migrate.New(db, fsys, nil)
+
+	require.NoError(t, m.Up())
+
+	err := m.Goto(1)
+	require.ErrorContains(t, err, "migrate: 000004_create_c.down.sql")
+
+	requireVersion(t, m, 4, true)
+	require.Equal(t, []string{"a", "b", "c"}, tables(t, db))
+
+	require.NoError(t, m.Force(4))
+	requireVersion(t, m, 4, false)
+}
+
+// TestMigrationsReversible reverts the shipped migrations and applies them again. It
+// stops above the citext extension, which is shared by every test schema.
+func TestMigrationsReversible(t *testing.T) {
+	db := testdb.Open(t)
+	m := migrate.New(db, migrations.FS, nil)
+
+	list, err := m.Migrations()
+	require.NoError(t, err)
+	latest := list[len(list)-1].Version
+
+	requireVersion(t, m, latest, false)
+
+	require.NoError(t, m.Goto(3))
+	requireVersion(t, m, 3, false)
+
+	require.NoError(t, m.Up())
+	requireVersion(t, m, latest, false)
+}
@@ -0,0 +1,127 @@
+package storage
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	ErrNotFound   = errors.New("storage: object not found")
+	ErrInvalidKey = errors.New("storage: invalid key")
+)
+
+// Storage is a minimal blob store. Keys are slash separated relative paths such as
+// "movies/12/3f9c1a.jpg"; implementations decide where the bytes actually live.
+type Storage interface {
+	Put(key string, r io.Reader) error
+	Open(key string) (io.ReadCloser, error)
+	Delete(key string) error
+	URL(key string) string
+}
+
+// Filesystem stores blobs as files below a root directory and serves them from baseURL.
+type Filesystem struct {
+	root    string
+	baseURL string
+}
+
+func NewFilesystem(root, baseURL string) (*Filesystem, error) {
+	err := os.MkdirAll(root, 0o755)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Filesystem{
+		root:    root,
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+	}, nil
+}
+
+// Put writes to a temporary file first and renames it into place, so readers never
+// see a partially written blob.
+func (fs *Filesystem) Put(key string, r io.Reader) error {
+	name, err := fs.path(key)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(name), 0o755)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(name), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = io.Copy(tmp, r)
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+
+	err = tmp.Close()
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), name)
+}
+
+func (fs *Filesystem) Open(key string) (io.ReadCloser, error) {
+	name, err := fs.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(name)
+	if err != nil {
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return f, nil
+}
+
+func (fs *Filesystem) Delete(key string) error {
+	name, err := fs.path(key)
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(name)
+	if err != nil {
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+			return ErrNotFound
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (fs *Filesystem) URL(key string) string {
+	return fs.baseURL + "/" + key
+}
+
+// path maps a key onto a file below root and rejects keys that would escape it.
+func (fs *Filesystem) path(key string) (string, error) {
+	clean := path.Clean("/" + key)
+	if key == "" || clean == "/" || clean != "/"+key {
+		return "", ErrInvalidKey
+	}
+
+	return filepath.Join(fs.root, filepath.FromSlash(clean)), nil
+}
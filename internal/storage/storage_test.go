@@ -0,0 +1,81 @@
+package storage
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFilesystem(t *testing.T) {
+	fs, err := NewFilesystem(filepath.Join(t.TempDir(), "uploads"), "/v1/images/")
+	require.NoError(t, err)
+
+	key := "movies/12/3f9c1a.jpg"
+	require.Equal(t, "/v1/images/movies/12/3f9c1a.jpg", fs.URL(key))
+
+	_, err = fs.Open(key)
+	require.ErrorIs(t, err, ErrNotFound)
+
+	require.NoError(t, fs.Put(key, strings.NewReader("first")))
+	require.NoError(t, fs.Put(key, strings.NewReader("second")))
+
+	f, err := fs.Open(key)
+	require.NoError(t, err)
+	content, err := io.ReadAll(f)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	require.Equal(t, "second", string(content))
+
+	// No temporary files are left behind next to the blob.
+	entries, err := os.ReadDir(filepath.Join(fs.root, "movies", "12"))
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+
+	require.NoError(t, fs.Delete(key))
+	require.ErrorIs(t, fs.Delete(key), ErrNotFound)
+
+	_, err = fs.Open(key)
+	require.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestFilesystemRejectsInvalidKeys(t *testing.T) {
+	dir := t.TempDir()
+	fs, err := NewFilesystem(filepath.Join(dir, "uploads"), "/v1/images")
+	require.NoError(t, err)
+
+	secret := filepath.Join(dir, "secret")
+	require.NoError(t, os.WriteFile(secret, []byte("secret"), 0o600))
+
+	keys := []string{
+		"",
+		"/",
+		".",
+		"..",
+		"../secret",
+		"movies/../../secret",
+		"movies/../movies/1.jpg",
+		"/movies/1.jpg",
+		"movies//1.jpg",
+		"movies/./1.jpg",
+		"movies/1/",
+	}
+
+	for _, key := range keys {
+		t.Run(key, func(t *testing.T) {
+			require.ErrorIs(t, fs.Put(key, strings.NewReader("overwritten")), ErrInvalidKey)
+
+			_, err := fs.Open(key)
+			require.ErrorIs(t, err, ErrInvalidKey)
+
+			require.ErrorIs(t, fs.Delete(key), ErrInvalidKey)
+		})
+	}
+
+	content, err := os.ReadFile(secret)
+	require.NoError(t, err)
+	require.Equal(t, "secret", string(content))
+}
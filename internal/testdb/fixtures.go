@@ -0,0 +1,84 @@
+package testdb
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/shynggys9219/greenlight/internal/data"
+)
+
+// FixturePassword is the password of every user made by User.
+const FixturePassword = "pa55word1234"
+
+var fixtureSeq atomic.Int64
+
+// The fixture builders insert a valid record through models, which can be on Open's
+// database or on a memstore, and fail the test on error. Options change the record
+// before it is inserted.
+
+// Movie inserts a movie.
+func Movie(t testing.TB, models data.Models, options ...func(*data.Movie)) *data.Movie {
+	t.Helper()
+
+	movie := &data.Movie{
+		Title:   fmt.Sprintf("Movie %d", fixtureSeq.Add(1)),
+		Year:    2001,
+		Runtime: 102,
+		Genres:  []string{"drama"},
+	}
+
+	for _, option := range options {
+		option(movie)
+	}
+
+	err := models.Movies.Insert(context.Background(), movie)
+	if err != nil {
+		t.Fatalf("testdb: inserting movie: %s", err)
+	}
+
+	return movie
+}
+
+// User inserts an activated user with FixturePassword and a unique email address.
+func User(t testing.TB, models data.Models, options ...func(*data.User)) *data.User {
+	t.Helper()
+
+	user := &data.User{
+		Name:      "Test User",
+		Email:     fmt.Sprintf("user-%d-%d@example.com", fixtureSeq.Add(1), time.Now().UnixNano()),
+		Activated: true,
+		Locale:    "en",
+	}
+
+	err := user.Password.Set(FixturePassword)
+	if err != nil {
+		t.Fatalf("testdb: setting password: %s", err)
+	}
+
+	for _, option := range options {
+		option(user)
+	}
+
+	err = models.User.Insert(context.Background(), user)
+	if err != nil {
+		t.Fatalf("testdb: inserting user: %s", err)
+	}
+
+	return user
+}
+
+// Token inserts a token of scope for the user, valid for an hour, and returns its
+// plaintext.
+func Token(t testing.TB, models data.Models, user *data.User, scope string) string {
+	t.Helper()
+
+	token, err := models.Token.New(context.Background(), user.ID, time.Hour, scope)
+	if err != nil {
+		t.Fatalf("testdb: inserting %s token: %s", scope, err)
+	}
+
+	return token.Plaintext
+}
@@ -0,0 +1,3 @@
+-- users.email uses the case-insensitive citext type, which ships as an extension.
+-- It has to exist before 000007 creates the users table.
+CREATE EXTENSION IF NOT EXISTS citext;
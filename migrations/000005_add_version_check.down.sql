@@ -1 +1 @@
-DROP CONSTRAINT IF EXISTS version_check;
\ No newline at end of file
+ALTER TABLE movies DROP CONSTRAINT IF EXISTS version_check;
\ No newline at end of file
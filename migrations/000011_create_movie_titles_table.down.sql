@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS movie_titles;
+DROP FUNCTION IF EXISTS movie_title_search_config(text);
@@ -0,0 +1,38 @@
+-- Maps a language code onto the text search configuration used for titles in
+-- that language. Unknown languages fall back to 'simple', same as movies.title.
+CREATE OR REPLACE FUNCTION movie_title_search_config(language text) RETURNS regconfig AS $$
+    SELECT CASE split_part(lower(language), '-', 1)
+        WHEN 'da' THEN 'danish'::regconfig
+        WHEN 'de' THEN 'german'::regconfig
+        WHEN 'en' THEN 'english'::regconfig
+        WHEN 'es' THEN 'spanish'::regconfig
+        WHEN 'fi' THEN 'finnish'::regconfig
+        WHEN 'fr' THEN 'french'::regconfig
+        WHEN 'hu' THEN 'hungarian'::regconfig
+        WHEN 'it' THEN 'italian'::regconfig
+        WHEN 'nl' THEN 'dutch'::regconfig
+        WHEN 'no' THEN 'norwegian'::regconfig
+        WHEN 'pt' THEN 'portuguese'::regconfig
+        WHEN 'ro' THEN 'romanian'::regconfig
+        WHEN 'ru' THEN 'russian'::regconfig
+        WHEN 'sv' THEN 'swedish'::regconfig
+        WHEN 'tr' THEN 'turkish'::regconfig
+        ELSE 'simple'::regconfig
+    END
+$$ LANGUAGE sql IMMUTABLE;
+
+CREATE TABLE IF NOT EXISTS movie_titles (
+    id bigserial PRIMARY KEY,
+    movie_id bigint NOT NULL REFERENCES movies ON DELETE CASCADE,
+    language text NOT NULL,
+    title text NOT NULL,
+    is_original bool NOT NULL DEFAULT false,
+    search_config regconfig GENERATED ALWAYS AS (movie_title_search_config(language)) STORED,
+    search_vector tsvector GENERATED ALWAYS AS (to_tsvector(movie_title_search_config(language), title)) STORED,
+    version integer NOT NULL DEFAULT 1,
+    UNIQUE (movie_id, language)
+);
+
+-- a movie has at most one original title
+CREATE UNIQUE INDEX IF NOT EXISTS movie_titles_original_idx ON movie_titles (movie_id) WHERE is_original;
+CREATE INDEX IF NOT EXISTS movie_titles_search_idx ON movie_titles USING GIN (search_vector);
@@ -0,0 +1,16 @@
+-- storage_key points at the original file in blob storage; thumbnails are stored
+-- next to it under keys derived from the key and the widths listed here.
+CREATE TABLE IF NOT EXISTS images (
+    id bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    movie_id bigint NOT NULL REFERENCES movies ON DELETE CASCADE,
+    kind text NOT NULL CHECK (kind IN ('poster', 'still')),
+    storage_key text UNIQUE NOT NULL,
+    content_type text NOT NULL,
+    width integer NOT NULL CHECK (width > 0),
+    height integer NOT NULL CHECK (height > 0),
+    size_bytes bigint NOT NULL CHECK (size_bytes > 0),
+    thumbnail_widths integer[] NOT NULL DEFAULT '{}'
+);
+
+CREATE INDEX IF NOT EXISTS images_movie_id_idx ON images (movie_id);
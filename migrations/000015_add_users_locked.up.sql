@@ -0,0 +1,4 @@
+-- locked accounts keep their data but can't sign in; set and cleared by admins.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS locked bool NOT NULL DEFAULT false;
+
+CREATE INDEX IF NOT EXISTS users_created_at_idx ON users (created_at);
@@ -0,0 +1,3 @@
+-- pending_email holds a requested new address until it is confirmed with an
+-- email_change token; the email column only changes after confirmation.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS pending_email citext NOT NULL DEFAULT '';
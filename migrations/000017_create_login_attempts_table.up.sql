@@ -0,0 +1,16 @@
+-- Every credential check is recorded so failures can be counted per account (email)
+-- and per client address. Failures for an email only count after its last success.
+CREATE TABLE IF NOT EXISTS login_attempts (
+    id bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    email citext NOT NULL,
+    ip text NOT NULL,
+    succeeded bool NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS login_attempts_email_idx ON login_attempts (email, created_at);
+CREATE INDEX IF NOT EXISTS login_attempts_ip_idx ON login_attempts (ip, created_at);
+
+-- locked_until is the temporary lockout after too many failures, independent of the
+-- locked flag admins set.
+ALTER TABLE users ADD COLUMN IF NOT EXISTS locked_until timestamp(0) with time zone;
@@ -0,0 +1,17 @@
+-- A row exists from the moment enrollment starts; enabled only flips to true once the
+-- user confirmed the secret with a first code. last_step is the time step of the last
+-- accepted code, so a code can't be used twice.
+CREATE TABLE IF NOT EXISTS totp_credentials (
+    user_id bigint PRIMARY KEY REFERENCES users ON DELETE CASCADE,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    secret bytea NOT NULL,
+    enabled bool NOT NULL DEFAULT false,
+    last_step bigint NOT NULL DEFAULT 0
+);
+
+CREATE TABLE IF NOT EXISTS totp_recovery_codes (
+    hash bytea PRIMARY KEY,
+    user_id bigint NOT NULL REFERENCES users ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS totp_recovery_codes_user_id_idx ON totp_recovery_codes (user_id);
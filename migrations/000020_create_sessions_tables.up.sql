@@ -0,0 +1,25 @@
+-- A session is one login on one device. Its access tokens live in the tokens table
+-- and its refresh tokens here; deleting the session revokes both.
+CREATE TABLE IF NOT EXISTS sessions (
+    id bigserial PRIMARY KEY,
+    user_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    last_seen_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    user_agent text NOT NULL,
+    ip text NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS sessions_user_id_idx ON sessions (user_id);
+
+-- Refresh tokens are used once. A used token is kept until it expires, so that
+-- presenting it again can be recognized as a stolen token being replayed.
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+    hash bytea PRIMARY KEY,
+    session_id bigint NOT NULL REFERENCES sessions ON DELETE CASCADE,
+    expiry timestamp(0) with time zone NOT NULL,
+    used bool NOT NULL DEFAULT false
+);
+
+CREATE INDEX IF NOT EXISTS refresh_tokens_session_id_idx ON refresh_tokens (session_id);
+
+ALTER TABLE tokens ADD COLUMN IF NOT EXISTS session_id bigint REFERENCES sessions ON DELETE CASCADE;
@@ -0,0 +1,11 @@
+-- One row per run of a scheduled background job, kept as its history.
+CREATE TABLE IF NOT EXISTS job_runs (
+    id bigserial PRIMARY KEY,
+    job text NOT NULL,
+    started_at timestamp(0) with time zone NOT NULL,
+    finished_at timestamp(0) with time zone NOT NULL,
+    affected bigint NOT NULL DEFAULT 0,
+    error text NOT NULL DEFAULT ''
+);
+
+CREATE INDEX IF NOT EXISTS job_runs_job_idx ON job_runs (job, id);
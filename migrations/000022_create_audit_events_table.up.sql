@@ -0,0 +1,16 @@
+-- Who changed what, written in the same transaction as the change. actor_id has no
+-- foreign key so the history survives the deletion of the acting user.
+CREATE TABLE IF NOT EXISTS audit_events (
+    id bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    actor_id bigint,
+    action text NOT NULL,
+    resource_type text NOT NULL,
+    resource_id bigint NOT NULL,
+    changes jsonb NOT NULL DEFAULT '{}',
+    ip text NOT NULL DEFAULT '',
+    request_id text NOT NULL DEFAULT ''
+);
+
+CREATE INDEX IF NOT EXISTS audit_events_resource_idx ON audit_events (resource_type, resource_id, id);
+CREATE INDEX IF NOT EXISTS audit_events_actor_idx ON audit_events (actor_id, id);
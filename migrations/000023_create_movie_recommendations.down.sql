@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS movie_similarities;
+DROP TABLE IF EXISTS movie_ratings;
+
+DELETE FROM credits WHERE role = 'director';
+ALTER TABLE credits DROP CONSTRAINT IF EXISTS credits_role_check;
+ALTER TABLE credits ADD CONSTRAINT credits_role_check CHECK (role IN ('actor', 'writer', 'composer', 'producer'));
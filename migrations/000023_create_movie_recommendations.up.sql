@@ -0,0 +1,28 @@
+-- Directors can be credited like everyone else, so movies can share them.
+ALTER TABLE credits DROP CONSTRAINT IF EXISTS credits_role_check;
+ALTER TABLE credits ADD CONSTRAINT credits_role_check CHECK (role IN ('actor', 'director', 'writer', 'composer', 'producer'));
+
+-- One rating per user and movie, from 1 to 10.
+CREATE TABLE IF NOT EXISTS movie_ratings (
+    user_id bigint NOT NULL REFERENCES users ON DELETE CASCADE,
+    movie_id bigint NOT NULL REFERENCES movies ON DELETE CASCADE,
+    rating integer NOT NULL CHECK (rating BETWEEN 1 AND 10),
+    updated_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, movie_id)
+);
+
+CREATE INDEX IF NOT EXISTS movie_ratings_movie_id_idx ON movie_ratings (movie_id);
+
+-- The precomputed neighbors of each movie, best first, with the parts of the score.
+CREATE TABLE IF NOT EXISTS movie_similarities (
+    movie_id bigint NOT NULL REFERENCES movies ON DELETE CASCADE,
+    similar_movie_id bigint NOT NULL REFERENCES movies ON DELETE CASCADE,
+    rank integer NOT NULL,
+    score double precision NOT NULL,
+    genres double precision NOT NULL,
+    directors double precision NOT NULL,
+    year double precision NOT NULL,
+    ratings double precision NOT NULL,
+    computed_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (movie_id, similar_movie_id)
+);
@@ -0,0 +1,10 @@
+// Package migrations embeds the SQL migration files so the API binary can apply
+// them itself (see internal/migrate).
+package migrations
+
+import "embed"
+
+// FS holds every "<version>_<name>.up.sql" and "<version>_<name>.down.sql" file.
+//
+//go:embed *.sql
+var FS embed.FS